@@ -0,0 +1,479 @@
+package apdu
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/mniak/apdu/internal/ber"
+)
+
+var (
+	ErrInvalidATR  = errors.New("invalid ATR")
+	ErrATRChecksum = errors.New("ATR check byte (TCK) does not match")
+)
+
+// Convention is the encoding of the characters, indicated by TS.
+type Convention byte
+
+const (
+	DirectConvention  Convention = 0x3B
+	InverseConvention Convention = 0x3F
+)
+
+type interfaceBytes struct {
+	// present has the bits b1 to b4 set for TA, TB, TC and TD, as in the
+	// high nibble of T0 and TD
+	present byte
+	values  [4]byte
+}
+
+func (g interfaceBytes) get(index int) (byte, bool) {
+	return g.values[index], g.present&(1<<index) != 0
+}
+
+// ATR is the Answer To Reset of a card, as defined in ISO/IEC 7816-3,
+// section 8.
+type ATR struct {
+	Raw             []byte
+	Convention      Convention
+	HistoricalBytes []byte
+	groups          []interfaceBytes
+}
+
+// ParseATR decodes the ATR, which must be complete: the interface bytes,
+// the historical bytes and TCK, when present, are checked against T0 and TD.
+func ParseATR(data []byte) (ATR, error) {
+	if len(data) < 2 {
+		return ATR{}, fmt.Errorf("%w: too short", ErrInvalidATR)
+	}
+	result := ATR{
+		Raw:        data,
+		Convention: Convention(data[0]),
+	}
+	if result.Convention != DirectConvention && result.Convention != InverseConvention {
+		return result, fmt.Errorf("%w: TS=%02X", ErrInvalidATR, data[0])
+	}
+
+	y := data[1] >> 4
+	historicalLength := int(data[1] & 0x0F)
+	checksumPresent := false
+	i := 2
+	for {
+		group := interfaceBytes{present: y}
+		for index := 0; index < 4; index++ {
+			if y&(1<<index) == 0 {
+				continue
+			}
+			if i >= len(data) {
+				return result, fmt.Errorf("%w: missing interface bytes", ErrInvalidATR)
+			}
+			group.values[index] = data[i]
+			i++
+		}
+		result.groups = append(result.groups, group)
+
+		td, ok := group.get(3)
+		if !ok {
+			break
+		}
+		if td&0x0F != 0 {
+			checksumPresent = true
+		}
+		y = td >> 4
+	}
+
+	if i+historicalLength > len(data) {
+		return result, fmt.Errorf("%w: missing historical bytes", ErrInvalidATR)
+	}
+	result.HistoricalBytes = data[i : i+historicalLength]
+	i += historicalLength
+
+	if checksumPresent {
+		if i >= len(data) {
+			return result, fmt.Errorf("%w: missing TCK", ErrInvalidATR)
+		}
+		var check byte
+		for _, b := range data[1 : i+1] {
+			check ^= b
+		}
+		if check != 0 {
+			return result, ErrATRChecksum
+		}
+		i++
+	}
+	if i != len(data) {
+		return result, fmt.Errorf("%w: %d unexpected bytes after the ATR", ErrInvalidATR, len(data)-i)
+	}
+	return result, nil
+}
+
+// TA returns the interface byte TAi, counting from 1 as in the standard.
+func (a ATR) TA(i int) (byte, bool) {
+	return a.interfaceByte(i, 0)
+}
+
+func (a ATR) TB(i int) (byte, bool) {
+	return a.interfaceByte(i, 1)
+}
+
+func (a ATR) TC(i int) (byte, bool) {
+	return a.interfaceByte(i, 2)
+}
+
+func (a ATR) TD(i int) (byte, bool) {
+	return a.interfaceByte(i, 3)
+}
+
+func (a ATR) interfaceByte(i, index int) (byte, bool) {
+	if i < 1 || i > len(a.groups) {
+		return 0, false
+	}
+	return a.groups[i-1].get(index)
+}
+
+// Protocols returns the transmission protocols offered by the card, in the
+// order indicated. T=15 only qualifies global interface bytes and is not
+// included. When no protocol is indicated, the card uses T=0.
+func (a ATR) Protocols() []int {
+	var result []int
+	seen := make(map[int]bool)
+	for i := 1; ; i++ {
+		td, ok := a.TD(i)
+		if !ok {
+			break
+		}
+		protocol := int(td & 0x0F)
+		if protocol != 15 && !seen[protocol] {
+			seen[protocol] = true
+			result = append(result, protocol)
+		}
+	}
+	if len(result) == 0 {
+		return []int{0}
+	}
+	return result
+}
+
+// specificInterfaceByte returns the first interface byte from the third
+// group on that is specific to the protocol, as the IFSC of T=1 in TA3.
+func (a ATR) specificInterfaceByte(protocol, index int) (byte, bool) {
+	for i := 3; i <= len(a.groups); i++ {
+		td, _ := a.TD(i - 1)
+		if int(td&0x0F) != protocol {
+			continue
+		}
+		if b, ok := a.interfaceByte(i, index); ok {
+			return b, true
+		}
+	}
+	return 0, false
+}
+
+var (
+	clockRateConversionFactors = [16]int{372, 372, 558, 744, 1116, 1488, 1860, 0, 0, 512, 768, 1024, 1536, 2048, 0, 0}
+	baudRateAdjustmentFactors  = [16]int{0, 1, 2, 4, 8, 16, 32, 64, 12, 20, 0, 0, 0, 0, 0, 0}
+)
+
+// FiDi returns the value of TA1, or the default 11 when it is absent.
+func (a ATR) FiDi() byte {
+	if ta1, ok := a.TA(1); ok {
+		return ta1
+	}
+	return 0x11
+}
+
+// ClockRateConversion returns the factor Fi indicated in the high nibble
+// of TA1 or zero when it is reserved for future use.
+func ClockRateConversion(fidi byte) int {
+	return clockRateConversionFactors[fidi>>4]
+}
+
+// BaudRateAdjustment returns the factor Di indicated in the low nibble of
+// TA1 or zero when it is reserved for future use.
+func BaudRateAdjustment(fidi byte) int {
+	return baudRateAdjustmentFactors[fidi&0x0F]
+}
+
+// SpecificMode returns the protocol imposed by TA2, when the card is in
+// specific mode and does not accept PPS.
+func (a ATR) SpecificMode() (protocol int, ok bool) {
+	ta2, ok := a.TA(2)
+	return int(ta2 & 0x0F), ok
+}
+
+// ExtraGuardTime is N, the number of extra ETUs between characters sent to
+// the card, indicated by TC1.
+func (a ATR) ExtraGuardTime() int {
+	tc1, _ := a.TC(1)
+	return int(tc1)
+}
+
+// WaitingTimeInteger is WI of T=0, indicated by TC2, which defaults to 10.
+func (a ATR) WaitingTimeInteger() int {
+	if tc2, ok := a.TC(2); ok {
+		return int(tc2)
+	}
+	return 10
+}
+
+// T1Parameters are the parameters of the protocol T=1 indicated in the
+// specific interface bytes, or their default values.
+type T1Parameters struct {
+	// IFSC is the maximum information field size of the card
+	IFSC int
+	// BWI and CWI are the block and character waiting time integers
+	BWI int
+	CWI int
+	// CRC tells whether the error detection code is a CRC instead of a LRC
+	CRC bool
+}
+
+func (a ATR) T1Parameters() T1Parameters {
+	result := T1Parameters{
+		IFSC: 32,
+		BWI:  4,
+		CWI:  13,
+	}
+	if ta, ok := a.specificInterfaceByte(1, 0); ok {
+		result.IFSC = int(ta)
+	}
+	if tb, ok := a.specificInterfaceByte(1, 1); ok {
+		result.BWI = int(tb >> 4)
+		result.CWI = int(tb & 0x0F)
+	}
+	if tc, ok := a.specificInterfaceByte(1, 2); ok {
+		result.CRC = tc&0x01 != 0
+	}
+	return result
+}
+
+// CompactTLV is a data object of the historical bytes, with the tag in the
+// high nibble and the length in the low nibble of the first byte.
+type CompactTLV struct {
+	Tag   byte
+	Value []byte
+}
+
+// Tags of the COMPACT-TLV data objects of the historical bytes
+const (
+	CompactTagCountryCode         byte = 0x1
+	CompactTagIssuerIdentifier    byte = 0x2
+	CompactTagCardServiceData     byte = 0x3
+	CompactTagInitialAccessData   byte = 0x4
+	CompactTagCardIssuerData      byte = 0x5
+	CompactTagPreIssuingData      byte = 0x6
+	CompactTagCardCapabilities    byte = 0x7
+	CompactTagStatusIndicator     byte = 0x8
+	CompactTagApplicationIdentity byte = 0xF
+)
+
+// HistoricalBytes are the historical bytes of the ATR, decoded according to
+// ISO/IEC 7816-4, section 12.1.1.
+type HistoricalBytes struct {
+	CategoryIndicator byte
+	// Objects are only decoded for the category indicators 00 and 80
+	Objects []CompactTLV
+	// Status is the card life cycle status and the status word, which are
+	// the last three bytes when the category indicator is 00
+	Status []byte
+}
+
+func parseCompactTLV(data []byte) ([]CompactTLV, error) {
+	var result []CompactTLV
+	for len(data) > 0 {
+		tag := data[0] >> 4
+		length := int(data[0] & 0x0F)
+		if len(data) < 1+length {
+			return nil, fmt.Errorf("COMPACT-TLV data object %X needs %d bytes but only %d remain", tag, length, len(data)-1)
+		}
+		result = append(result, CompactTLV{Tag: tag, Value: data[1 : 1+length]})
+		data = data[1+length:]
+	}
+	return result, nil
+}
+
+func ParseHistoricalBytes(data []byte) (HistoricalBytes, error) {
+	if len(data) == 0 {
+		return HistoricalBytes{}, nil
+	}
+	result := HistoricalBytes{CategoryIndicator: data[0]}
+	var err error
+	switch data[0] {
+	case 0x00:
+		if len(data) < 4 {
+			return result, errors.New("historical bytes are missing the status indicator")
+		}
+		result.Status = data[len(data)-3:]
+		result.Objects, err = parseCompactTLV(data[1 : len(data)-3])
+	case 0x80:
+		result.Objects, err = parseCompactTLV(data[1:])
+		if status, ok := result.Find(CompactTagStatusIndicator); ok {
+			result.Status = status
+		}
+	}
+	return result, err
+}
+
+// Find returns the value of the first data object with the tag.
+func (h HistoricalBytes) Find(tag byte) ([]byte, bool) {
+	for _, obj := range h.Objects {
+		if obj.Tag == tag {
+			return obj.Value, true
+		}
+	}
+	return nil, false
+}
+
+// CardCapabilities are the three software function tables of the card
+// capabilities data object. Missing tables are zero.
+type CardCapabilities struct {
+	SelectionMethods byte
+	DataCoding       byte
+	Features         byte
+}
+
+func ParseCardCapabilities(data []byte) CardCapabilities {
+	var tables [3]byte
+	copy(tables[:], data)
+	return CardCapabilities{
+		SelectionMethods: tables[0],
+		DataCoding:       tables[1],
+		Features:         tables[2],
+	}
+}
+
+// CardCapabilities returns the card capabilities data object, when present.
+func (h HistoricalBytes) CardCapabilities() (CardCapabilities, bool) {
+	value, ok := h.Find(CompactTagCardCapabilities)
+	if !ok {
+		return CardCapabilities{}, false
+	}
+	return ParseCardCapabilities(value), true
+}
+
+func (c CardCapabilities) CommandChaining() bool {
+	return c.Features&0b1000_0000 != 0
+}
+
+func (c CardCapabilities) ExtendedLength() bool {
+	return c.Features&0b0100_0000 != 0
+}
+
+// LogicalChannels returns the maximum number of logical channels, including
+// the basic channel. Eight means eight or more.
+func (c CardCapabilities) LogicalChannels() int {
+	if c.Features&0b0001_1000 == 0 {
+		return 1
+	}
+	return int(c.Features&0b0000_0111) + 1
+}
+
+// SelectionMethod is a bit of the first software function table, which
+// tells how the card selects DFs and EFs.
+type SelectionMethod byte
+
+const (
+	SelectionByFullDFName       SelectionMethod = 0b1000_0000
+	SelectionByPartialDFName    SelectionMethod = 0b0100_0000
+	SelectionByPath             SelectionMethod = 0b0010_0000
+	SelectionByFileIdentifier   SelectionMethod = 0b0001_0000
+	SelectionImplicitDF         SelectionMethod = 0b0000_1000
+	SelectionShortEFIdentifier  SelectionMethod = 0b0000_0100
+	SelectionByRecordNumber     SelectionMethod = 0b0000_0010
+	SelectionByRecordIdentifier SelectionMethod = 0b0000_0001
+)
+
+func (c CardCapabilities) Supports(method SelectionMethod) bool {
+	return c.SelectionMethods&byte(method) == byte(method)
+}
+
+// WriteBehaviour is the behaviour of the write functions, indicated in the
+// data coding byte.
+type WriteBehaviour byte
+
+const (
+	WriteOneTime     WriteBehaviour = 0b00
+	WriteProprietary WriteBehaviour = 0b01
+	WriteOR          WriteBehaviour = 0b10
+	WriteAND         WriteBehaviour = 0b11
+)
+
+// TLVFiles tells whether the EFs of TLV structure are supported.
+func (c CardCapabilities) TLVFiles() bool {
+	return c.DataCoding&0b1000_0000 != 0
+}
+
+func (c CardCapabilities) WriteBehaviour() WriteBehaviour {
+	return WriteBehaviour(c.DataCoding >> 5 & 0b11)
+}
+
+// FFTagValid tells whether FF is a valid first byte of BER-TLV tags, instead
+// of padding.
+func (c CardCapabilities) FFTagValid() bool {
+	return c.DataCoding&0b0001_0000 != 0
+}
+
+// DataUnitQuartets returns the size of the data units in quartets, which is
+// two for one byte.
+func (c CardCapabilities) DataUnitQuartets() int {
+	return 1 << (c.DataCoding & 0x0F)
+}
+
+// TagCardCapabilities is the tag of the card capabilities data object when it
+// is encoded in BER-TLV, as in EF.ATR/INFO.
+const TagCardCapabilities Tag = 0x47
+
+// FileIdentifierATRInfo is the identifier of EF.ATR/INFO, under the MF.
+var FileIdentifierATRInfo = []byte{0x2F, 0x01}
+
+var ErrCardCapabilitiesNotFound = errors.New("card capabilities not found")
+
+// ReadCardCapabilities reads the card capabilities data object from
+// EF.ATR/INFO, for the cards that do not have it in the historical bytes.
+func ReadCardCapabilities(client RawClient) (CardCapabilities, error) {
+	resp, err := client.SendCommand(Command{
+		Class:       0x00,
+		Instruction: InstructionA4_Select,
+		Parameters: Parameters{
+			P1: 0x00, // Select MF, DF or EF by file identifier
+			P2: 0x0C, // No response data
+		},
+		Data: FileIdentifierATRInfo,
+	})
+	if err != nil {
+		return CardCapabilities{}, err
+	}
+	if err := resp.Trailer.GetError(); err != nil {
+		return CardCapabilities{}, err
+	}
+
+	resp, err = client.SendCommand(Command{
+		Class:       0x00,
+		Instruction: InstructionB0_ReadBinary,
+	})
+	if err != nil {
+		return CardCapabilities{}, err
+	}
+	// 6282 means that the file is shorter than the expected length
+	if resp.Trailer != 0x6282 {
+		if err := resp.Trailer.GetError(); err != nil {
+			return CardCapabilities{}, err
+		}
+	}
+	return ParseATRInfo(resp.Data)
+}
+
+// ParseATRInfo finds the card capabilities among the BER-TLV data objects of
+// EF.ATR/INFO.
+func ParseATRInfo(data []byte) (CardCapabilities, error) {
+	tlvs, err := ber.Parse(data)
+	if err != nil {
+		return CardCapabilities{}, err
+	}
+	for _, t := range tlvs {
+		if t.Tag == TagCardCapabilities {
+			return ParseCardCapabilities(t.Value), nil
+		}
+	}
+	return CardCapabilities{}, ErrCardCapabilitiesNotFound
+}
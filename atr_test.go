@@ -0,0 +1,225 @@
+package apdu
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/mniak/apdu/internal/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// withTCK appends the check byte to the ATR.
+func withTCK(t *testing.T, hex string) []byte {
+	atr := test.MustParseHex(t, hex)
+	var tck byte
+	for _, b := range atr[1:] {
+		tck ^= b
+	}
+	return append(atr, tck)
+}
+
+func TestParseATR(t *testing.T) {
+	t.Run("JCOP", func(t *testing.T) {
+		atr, err := ParseATR(test.MustParseHex(t, "3BF81300008131FE454A434F5076323431B7"))
+		require.NoError(t, err)
+		assert.Equal(t, DirectConvention, atr.Convention)
+		assert.Equal(t, []int{1}, atr.Protocols())
+		assert.Equal(t, byte(0x13), atr.FiDi())
+		assert.Equal(t, 372, ClockRateConversion(atr.FiDi()))
+		assert.Equal(t, 4, BaudRateAdjustment(atr.FiDi()))
+		assert.Equal(t, 0, atr.ExtraGuardTime())
+		assert.Equal(t, T1Parameters{IFSC: 254, BWI: 4, CWI: 5}, atr.T1Parameters())
+		assert.Equal(t, "JCOPv241", string(atr.HistoricalBytes))
+		_, specific := atr.SpecificMode()
+		assert.False(t, specific)
+
+		tb3, ok := atr.TB(3)
+		assert.True(t, ok)
+		assert.Equal(t, byte(0x45), tb3)
+		_, ok = atr.TC(3)
+		assert.False(t, ok)
+	})
+	t.Run("T=0 without TCK", func(t *testing.T) {
+		atr, err := ParseATR(test.MustParseHex(t, "3B021450"))
+		require.NoError(t, err)
+		assert.Equal(t, []int{0}, atr.Protocols())
+		assert.Equal(t, byte(0x11), atr.FiDi())
+		assert.Equal(t, 10, atr.WaitingTimeInteger())
+		test.AssertBytesEqual(t, "1450", atr.HistoricalBytes)
+	})
+	t.Run("T=0 and T=1 with specific mode", func(t *testing.T) {
+		atr, err := ParseATR(withTCK(t, "3F B0 96 00 90 01 01"))
+		require.NoError(t, err)
+		assert.Equal(t, InverseConvention, atr.Convention)
+		assert.Equal(t, []int{0, 1}, atr.Protocols())
+		assert.Equal(t, byte(0x96), atr.FiDi())
+		assert.Equal(t, 512, ClockRateConversion(atr.FiDi()))
+		assert.Equal(t, 32, BaudRateAdjustment(atr.FiDi()))
+		assert.Equal(t, 10, atr.WaitingTimeInteger())
+		protocol, specific := atr.SpecificMode()
+		assert.True(t, specific)
+		assert.Equal(t, 1, protocol)
+		assert.Equal(t, T1Parameters{IFSC: 32, BWI: 4, CWI: 13}, atr.T1Parameters())
+	})
+	t.Run("Errors", func(t *testing.T) {
+		for name, data := range map[string][]byte{
+			"Invalid TS":                test.MustParseHex(t, "3A00"),
+			"Missing interface bytes":   test.MustParseHex(t, "3BF813"),
+			"Missing historical bytes":  test.MustParseHex(t, "3B0414"),
+			"Missing TCK":               test.MustParseHex(t, "3B8001"),
+			"Unexpected trailing bytes": test.MustParseHex(t, "3B001234"),
+		} {
+			t.Run(name, func(t *testing.T) {
+				_, err := ParseATR(data)
+				assert.ErrorIs(t, err, ErrInvalidATR)
+			})
+		}
+		_, err := ParseATR(test.MustParseHex(t, "3BF81300008131FE454A434F5076323431B8"))
+		assert.ErrorIs(t, err, ErrATRChecksum)
+	})
+}
+
+func TestCardCapabilities(t *testing.T) {
+	capabilities := ParseCardCapabilities(test.MustParseHex(t, "F4 B1 D3"))
+	assert.True(t, capabilities.Supports(SelectionByFullDFName))
+	assert.True(t, capabilities.Supports(SelectionByFileIdentifier))
+	assert.True(t, capabilities.Supports(SelectionShortEFIdentifier))
+	assert.False(t, capabilities.Supports(SelectionImplicitDF))
+	assert.True(t, capabilities.TLVFiles())
+	assert.Equal(t, WriteProprietary, capabilities.WriteBehaviour())
+	assert.True(t, capabilities.FFTagValid())
+	assert.Equal(t, 2, capabilities.DataUnitQuartets())
+	assert.True(t, capabilities.CommandChaining())
+	assert.True(t, capabilities.ExtendedLength())
+	assert.Equal(t, 4, capabilities.LogicalChannels())
+}
+
+func TestParseATRInfo(t *testing.T) {
+	capabilities, err := ParseATRInfo(test.MustParseHex(t, "4F07A0000000041010 4703 F4B1D3 0000"))
+	require.NoError(t, err)
+	assert.Equal(t, CardCapabilities{SelectionMethods: 0xF4, DataCoding: 0xB1, Features: 0xD3}, capabilities)
+
+	_, err = ParseATRInfo(test.MustParseHex(t, "4F07A0000000041010"))
+	assert.ErrorIs(t, err, ErrCardCapabilitiesNotFound)
+}
+
+func TestParseHistoricalBytes(t *testing.T) {
+	t.Run("Category 80", func(t *testing.T) {
+		historical, err := ParseHistoricalBytes(test.MustParseHex(t, "80 120276 7300A1D3 829000"))
+		require.NoError(t, err)
+		assert.Equal(t, byte(0x80), historical.CategoryIndicator)
+		require.Len(t, historical.Objects, 3)
+		test.AssertBytesEqual(t, "9000", historical.Status)
+
+		capabilities, ok := historical.CardCapabilities()
+		require.True(t, ok)
+		assert.True(t, capabilities.CommandChaining())
+		assert.True(t, capabilities.ExtendedLength())
+		assert.Equal(t, 4, capabilities.LogicalChannels())
+		assert.Equal(t, byte(0xA1), capabilities.DataCoding)
+	})
+	t.Run("Category 00", func(t *testing.T) {
+		historical, err := ParseHistoricalBytes(test.MustParseHex(t, "00 7180 05 9000"))
+		require.NoError(t, err)
+		test.AssertBytesEqual(t, "059000", historical.Status)
+		capabilities, ok := historical.CardCapabilities()
+		require.True(t, ok)
+		assert.Equal(t, byte(0x80), capabilities.SelectionMethods)
+		assert.False(t, capabilities.CommandChaining())
+		assert.Equal(t, 1, capabilities.LogicalChannels())
+	})
+	t.Run("Proprietary", func(t *testing.T) {
+		historical, err := ParseHistoricalBytes([]byte("JCOPv241"))
+		require.NoError(t, err)
+		assert.Empty(t, historical.Objects)
+		_, ok := historical.CardCapabilities()
+		assert.False(t, ok)
+	})
+	t.Run("Truncated", func(t *testing.T) {
+		_, err := ParseHistoricalBytes(test.MustParseHex(t, "80 7300"))
+		assert.Error(t, err)
+	})
+}
+
+// atrDriver answers 9000 to every command, or the response registered for
+// the instruction, recording the bytes sent.
+type atrDriver struct {
+	atr       []byte
+	responses map[Instruction][]byte
+	sent      [][]byte
+}
+
+func (d *atrDriver) ATR() ([]byte, error) {
+	return d.atr, nil
+}
+
+func (d *atrDriver) SendBytes(b []byte) ([]byte, error) {
+	d.sent = append(d.sent, b)
+	if resp, ok := d.responses[Instruction(b[1])]; ok {
+		return resp, nil
+	}
+	return []byte{0x90, 0x00}, nil
+}
+
+func TestRawClient_ConfiguredByATR(t *testing.T) {
+	data := bytes.Repeat([]byte{0xAB}, 300)
+	cmd := Command{Class: 0x00, Instruction: 0xD6, Data: data}
+
+	t.Run("Extended length", func(t *testing.T) {
+		driver := &atrDriver{atr: withTCK(t, "3B 88 01 80 7300 00 40 829000")}
+		resp, err := NewRawClient(driver).SendCommand(cmd)
+		require.NoError(t, err)
+		assert.Equal(t, Trailer(0x9000), resp.Trailer)
+
+		require.Len(t, driver.sent, 1)
+		expected := append(test.MustParseHex(t, "00D60000 00012C"), data...)
+		assert.Equal(t, append(expected, 0x00, 0x00), driver.sent[0])
+	})
+	t.Run("Command chaining", func(t *testing.T) {
+		driver := &atrDriver{atr: withTCK(t, "3B 88 01 80 7300 00 80 829000")}
+		_, err := NewRawClient(driver).SendCommand(cmd)
+		require.NoError(t, err)
+
+		require.Len(t, driver.sent, 2)
+		assert.Equal(t, test.MustParseHex(t, "10D60000FF"), driver.sent[0][:5])
+		assert.Len(t, driver.sent[0], 5+255+1)
+		assert.Equal(t, test.MustParseHex(t, "00D600002D"), driver.sent[1][:5])
+		assert.Len(t, driver.sent[1], 5+45+1)
+	})
+	t.Run("Capabilities in EF.ATR/INFO", func(t *testing.T) {
+		driver := &atrDriver{
+			atr: withTCK(t, "3B 84 01 80 3110 00"),
+			responses: map[Instruction][]byte{
+				InstructionB0_ReadBinary: test.MustParseHex(t, "4703 F4B1C3 9000"),
+			},
+		}
+		client := NewRawClient(driver)
+		assert.Empty(t, driver.sent)
+
+		require.NoError(t, client.DetectCapabilities())
+		require.Len(t, driver.sent, 2)
+		test.AssertBytesEqual(t, "00A4000C022F0100", driver.sent[0])
+		test.AssertBytesEqual(t, "00B0000000", driver.sent[1])
+
+		_, err := client.SendCommand(cmd)
+		require.NoError(t, err)
+		require.Len(t, driver.sent, 3)
+		assert.Len(t, driver.sent[2], 4+3+300+2)
+	})
+	t.Run("Capabilities set", func(t *testing.T) {
+		driver := &atrDriver{atr: test.MustParseHex(t, "3B021450")}
+		capabilities := ParseCardCapabilities([]byte{0x00, 0x00, 0x40})
+		_, err := NewRawClient(driver).WithCardCapabilities(capabilities).SendCommand(cmd)
+		require.NoError(t, err)
+		require.Len(t, driver.sent, 1)
+	})
+	t.Run("No capabilities", func(t *testing.T) {
+		driver := &atrDriver{atr: test.MustParseHex(t, "3B021450")}
+		client := NewRawClient(driver)
+		assert.ErrorIs(t, client.DetectCapabilities(), ErrCardCapabilitiesNotFound)
+		_, err := client.SendCommand(cmd)
+		assert.Error(t, err)
+		assert.Empty(t, driver.sent)
+	})
+}
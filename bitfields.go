@@ -0,0 +1,590 @@
+package apdu
+
+import (
+	"encoding/hex"
+	"fmt"
+)
+
+// The bit types below identify a bit by the byte number (starting at 1) in
+// the high byte and the bit mask in the low byte, like TVRBit, so that the
+// generic helpers below implement Has, Bits and String for all of them.
+
+type namedBit[B ~uint16] struct {
+	bit  B
+	name string
+}
+
+func hasBit[B ~uint16](data []byte, bit B) bool {
+	index := int(bit>>8) - 1
+	return index >= 0 && index < len(data) && data[index]&byte(bit) == byte(bit)
+}
+
+func setBits[B ~uint16](data []byte, names []namedBit[B]) []B {
+	var result []B
+	for _, n := range names {
+		if hasBit(data, n.bit) {
+			result = append(result, n.bit)
+		}
+	}
+	return result
+}
+
+func bitName[B ~uint16](names []namedBit[B], bit B, prefix string) string {
+	for _, n := range names {
+		if n.bit == bit {
+			return n.name
+		}
+	}
+	return fmt.Sprintf("%s byte %d mask %02X", prefix, bit>>8, byte(bit))
+}
+
+// AIPBit is a bit of the Application Interchange Profile (82).
+type AIPBit uint16
+
+const (
+	AIPSDASupported                            AIPBit = 0x0140
+	AIPDDASupported                            AIPBit = 0x0120
+	AIPCardholderVerificationSupported         AIPBit = 0x0110
+	AIPTerminalRiskManagementToBePerformed     AIPBit = 0x0108
+	AIPIssuerAuthenticationSupported           AIPBit = 0x0104
+	AIPOnDeviceCardholderVerificationSupported AIPBit = 0x0102
+	AIPCDASupported                            AIPBit = 0x0101
+	AIPEMVModeSupported                        AIPBit = 0x0280
+	AIPMagStripeModeSupported                  AIPBit = 0x0240
+	AIPRelayResistanceProtocolSupported        AIPBit = 0x0201
+)
+
+var aipBitNames = []namedBit[AIPBit]{
+	{AIPSDASupported, "SDA supported"},
+	{AIPDDASupported, "DDA supported"},
+	{AIPCardholderVerificationSupported, "Cardholder verification is supported"},
+	{AIPTerminalRiskManagementToBePerformed, "Terminal risk management is to be performed"},
+	{AIPIssuerAuthenticationSupported, "Issuer authentication is supported"},
+	{AIPOnDeviceCardholderVerificationSupported, "On device cardholder verification is supported"},
+	{AIPCDASupported, "CDA supported"},
+	{AIPEMVModeSupported, "EMV mode is supported"},
+	{AIPMagStripeModeSupported, "Mag-stripe mode is supported"},
+	{AIPRelayResistanceProtocolSupported, "Relay resistance protocol is supported"},
+}
+
+func (b AIPBit) String() string {
+	return bitName(aipBitNames, b, "AIP")
+}
+
+func (aip AIP) Has(bit AIPBit) bool {
+	return hasBit(aip, bit)
+}
+
+func (aip AIP) Bits() []AIPBit {
+	return setBits(aip, aipBitNames)
+}
+
+func (aip AIP) SDASupported() bool {
+	return aip.Has(AIPSDASupported)
+}
+
+func (aip AIP) DDASupported() bool {
+	return aip.Has(AIPDDASupported)
+}
+
+func (aip AIP) CDASupported() bool {
+	return aip.Has(AIPCDASupported)
+}
+
+func (aip AIP) CardholderVerificationSupported() bool {
+	return aip.Has(AIPCardholderVerificationSupported)
+}
+
+func (aip AIP) TerminalRiskManagementToBePerformed() bool {
+	return aip.Has(AIPTerminalRiskManagementToBePerformed)
+}
+
+func (aip AIP) IssuerAuthenticationSupported() bool {
+	return aip.Has(AIPIssuerAuthenticationSupported)
+}
+
+func (aip AIP) OnDeviceCardholderVerificationSupported() bool {
+	return aip.Has(AIPOnDeviceCardholderVerificationSupported)
+}
+
+// EMVModeSupported tells whether the contactless EMV mode is supported.
+func (aip AIP) EMVModeSupported() bool {
+	return aip.Has(AIPEMVModeSupported)
+}
+
+func (aip AIP) String() string {
+	return fmt.Sprintf("%02X", []byte(aip))
+}
+
+func (aip AIP) GoString() string {
+	return describeBits(aip.String(), aip.Bits())
+}
+
+// ApplicationUsageControl (9F07) indicates the restrictions of the issuer on
+// the geographic usage and services allowed for the application.
+type ApplicationUsageControl [2]byte
+
+// AUCBit is a bit of the Application Usage Control.
+type AUCBit uint16
+
+const (
+	AUCValidForDomesticCash          AUCBit = 0x0180
+	AUCValidForInternationalCash     AUCBit = 0x0140
+	AUCValidForDomesticGoods         AUCBit = 0x0120
+	AUCValidForInternationalGoods    AUCBit = 0x0110
+	AUCValidForDomesticServices      AUCBit = 0x0108
+	AUCValidForInternationalServices AUCBit = 0x0104
+	AUCValidAtATMs                   AUCBit = 0x0102
+	AUCValidAtTerminalsOtherThanATMs AUCBit = 0x0101
+	AUCDomesticCashbackAllowed       AUCBit = 0x0280
+	AUCInternationalCashbackAllowed  AUCBit = 0x0240
+)
+
+var aucBitNames = []namedBit[AUCBit]{
+	{AUCValidForDomesticCash, "Valid for domestic cash transactions"},
+	{AUCValidForInternationalCash, "Valid for international cash transactions"},
+	{AUCValidForDomesticGoods, "Valid for domestic goods"},
+	{AUCValidForInternationalGoods, "Valid for international goods"},
+	{AUCValidForDomesticServices, "Valid for domestic services"},
+	{AUCValidForInternationalServices, "Valid for international services"},
+	{AUCValidAtATMs, "Valid at ATMs"},
+	{AUCValidAtTerminalsOtherThanATMs, "Valid at terminals other than ATMs"},
+	{AUCDomesticCashbackAllowed, "Domestic cashback allowed"},
+	{AUCInternationalCashbackAllowed, "International cashback allowed"},
+}
+
+func (b AUCBit) String() string {
+	return bitName(aucBitNames, b, "AUC")
+}
+
+// ParseApplicationUsageControl reads the AUC from the value of the tag 9F07.
+func ParseApplicationUsageControl(data []byte) (ApplicationUsageControl, error) {
+	var auc ApplicationUsageControl
+	if len(data) != len(auc) {
+		return auc, fmt.Errorf("application usage control should have %d bytes but has %d", len(auc), len(data))
+	}
+	copy(auc[:], data)
+	return auc, nil
+}
+
+func (auc ApplicationUsageControl) Has(bit AUCBit) bool {
+	return hasBit(auc[:], bit)
+}
+
+func (auc ApplicationUsageControl) Bits() []AUCBit {
+	return setBits(auc[:], aucBitNames)
+}
+
+func (auc ApplicationUsageControl) ValidForCash(domestic bool) bool {
+	if domestic {
+		return auc.Has(AUCValidForDomesticCash)
+	}
+	return auc.Has(AUCValidForInternationalCash)
+}
+
+func (auc ApplicationUsageControl) ValidForGoods(domestic bool) bool {
+	if domestic {
+		return auc.Has(AUCValidForDomesticGoods)
+	}
+	return auc.Has(AUCValidForInternationalGoods)
+}
+
+func (auc ApplicationUsageControl) ValidForServices(domestic bool) bool {
+	if domestic {
+		return auc.Has(AUCValidForDomesticServices)
+	}
+	return auc.Has(AUCValidForInternationalServices)
+}
+
+func (auc ApplicationUsageControl) CashbackAllowed(domestic bool) bool {
+	if domestic {
+		return auc.Has(AUCDomesticCashbackAllowed)
+	}
+	return auc.Has(AUCInternationalCashbackAllowed)
+}
+
+func (auc ApplicationUsageControl) ValidAtATMs() bool {
+	return auc.Has(AUCValidAtATMs)
+}
+
+func (auc ApplicationUsageControl) ValidAtTerminalsOtherThanATMs() bool {
+	return auc.Has(AUCValidAtTerminalsOtherThanATMs)
+}
+
+func (auc ApplicationUsageControl) String() string {
+	return fmt.Sprintf("%02X", auc[:])
+}
+
+func (auc ApplicationUsageControl) GoString() string {
+	return describeBits(auc.String(), auc.Bits())
+}
+
+// ApplicationUsageControl decodes the UsageControl field.
+func (et EMVProprietaryTemplate) ApplicationUsageControl() (ApplicationUsageControl, error) {
+	data, err := hex.DecodeString(et.UsageControl)
+	if err != nil {
+		return ApplicationUsageControl{}, fmt.Errorf("invalid application usage control: %w", err)
+	}
+	return ParseApplicationUsageControl(data)
+}
+
+// CIDReasonCode is the reason/advice code of the Cryptogram Information Data.
+type CIDReasonCode byte
+
+const (
+	CIDNoInformationGiven         CIDReasonCode = 0b000
+	CIDServiceNotAllowed          CIDReasonCode = 0b001
+	CIDPINTryLimitExceeded        CIDReasonCode = 0b010
+	CIDIssuerAuthenticationFailed CIDReasonCode = 0b011
+)
+
+func (rc CIDReasonCode) String() string {
+	switch rc {
+	case CIDNoInformationGiven:
+		return "No information given"
+	case CIDServiceNotAllowed:
+		return "Service not allowed"
+	case CIDPINTryLimitExceeded:
+		return "PIN Try Limit exceeded"
+	case CIDIssuerAuthenticationFailed:
+		return "Issuer authentication failed"
+	default:
+		return fmt.Sprintf("RFU (%d)", byte(rc))
+	}
+}
+
+func (cid CryptogramInformationData) CryptogramType() ApplicationCryptogramType {
+	return ApplicationCryptogramType(cid >> 6)
+}
+
+// AdviceRequired tells whether the card requested an advice message.
+func (cid CryptogramInformationData) AdviceRequired() bool {
+	return cid&0b0000_1000 != 0
+}
+
+func (cid CryptogramInformationData) ReasonCode() CIDReasonCode {
+	return CIDReasonCode(cid & 0b0000_0111)
+}
+
+func (cid CryptogramInformationData) GoString() string {
+	var advice string
+	if cid.AdviceRequired() {
+		advice = ", advice required"
+	}
+	return fmt.Sprintf("%s [%s%s, %s]", cid, cid.CryptogramType(), advice, cid.ReasonCode())
+}
+
+func (t ApplicationCryptogramType) String() string {
+	switch t {
+	case AAC:
+		return "AAC"
+	case TC:
+		return "TC"
+	case ARQC:
+		return "ARQC"
+	default:
+		return "RFU"
+	}
+}
+
+// TerminalCapabilities (9F33) indicates the card data input, CVM, and
+// security capabilities of the terminal.
+type TerminalCapabilities [3]byte
+
+// TerminalCapability is a bit of the Terminal Capabilities.
+type TerminalCapability uint16
+
+const (
+	TerminalCapabilityManualKeyEntry                      TerminalCapability = 0x0180
+	TerminalCapabilityMagneticStripe                      TerminalCapability = 0x0140
+	TerminalCapabilityICWithContacts                      TerminalCapability = 0x0120
+	TerminalCapabilityPlaintextPINForICCVerification      TerminalCapability = 0x0280
+	TerminalCapabilityEncipheredPINForOnlineVerification  TerminalCapability = 0x0240
+	TerminalCapabilitySignature                           TerminalCapability = 0x0220
+	TerminalCapabilityEncipheredPINForOfflineVerification TerminalCapability = 0x0210
+	TerminalCapabilityNoCVMRequired                       TerminalCapability = 0x0208
+	TerminalCapabilitySDA                                 TerminalCapability = 0x0380
+	TerminalCapabilityDDA                                 TerminalCapability = 0x0340
+	TerminalCapabilityCardCapture                         TerminalCapability = 0x0320
+	TerminalCapabilityCDA                                 TerminalCapability = 0x0308
+)
+
+var terminalCapabilityNames = []namedBit[TerminalCapability]{
+	{TerminalCapabilityManualKeyEntry, "Manual key entry"},
+	{TerminalCapabilityMagneticStripe, "Magnetic stripe"},
+	{TerminalCapabilityICWithContacts, "IC with contacts"},
+	{TerminalCapabilityPlaintextPINForICCVerification, "Plaintext PIN for ICC verification"},
+	{TerminalCapabilityEncipheredPINForOnlineVerification, "Enciphered PIN for online verification"},
+	{TerminalCapabilitySignature, "Signature (paper)"},
+	{TerminalCapabilityEncipheredPINForOfflineVerification, "Enciphered PIN for offline verification"},
+	{TerminalCapabilityNoCVMRequired, "No CVM required"},
+	{TerminalCapabilitySDA, "SDA"},
+	{TerminalCapabilityDDA, "DDA"},
+	{TerminalCapabilityCardCapture, "Card capture"},
+	{TerminalCapabilityCDA, "CDA"},
+}
+
+func (b TerminalCapability) String() string {
+	return bitName(terminalCapabilityNames, b, "Terminal Capabilities")
+}
+
+// ParseTerminalCapabilities reads the value of the tag 9F33. Missing bytes
+// are considered zero.
+func ParseTerminalCapabilities(data []byte) TerminalCapabilities {
+	var tc TerminalCapabilities
+	copy(tc[:], data)
+	return tc
+}
+
+func (tc *TerminalCapabilities) Set(bits ...TerminalCapability) {
+	for _, b := range bits {
+		tc[int(b>>8)-1] |= byte(b)
+	}
+}
+
+func (tc TerminalCapabilities) Has(bit TerminalCapability) bool {
+	return hasBit(tc[:], bit)
+}
+
+func (tc TerminalCapabilities) Bits() []TerminalCapability {
+	return setBits(tc[:], terminalCapabilityNames)
+}
+
+func (tc TerminalCapabilities) String() string {
+	return fmt.Sprintf("%02X", tc[:])
+}
+
+func (tc TerminalCapabilities) GoString() string {
+	return describeBits(tc.String(), tc.Bits())
+}
+
+// AdditionalTerminalCapabilities (9F40) indicates the transaction types,
+// data input and data output capabilities of the terminal.
+type AdditionalTerminalCapabilities [5]byte
+
+// AdditionalTerminalCapability is a bit of the Additional Terminal Capabilities.
+type AdditionalTerminalCapability uint16
+
+const (
+	AdditionalTerminalCapabilityCash              AdditionalTerminalCapability = 0x0180
+	AdditionalTerminalCapabilityGoods             AdditionalTerminalCapability = 0x0140
+	AdditionalTerminalCapabilityServices          AdditionalTerminalCapability = 0x0120
+	AdditionalTerminalCapabilityCashback          AdditionalTerminalCapability = 0x0110
+	AdditionalTerminalCapabilityInquiry           AdditionalTerminalCapability = 0x0108
+	AdditionalTerminalCapabilityTransfer          AdditionalTerminalCapability = 0x0104
+	AdditionalTerminalCapabilityPayment           AdditionalTerminalCapability = 0x0102
+	AdditionalTerminalCapabilityAdministrative    AdditionalTerminalCapability = 0x0101
+	AdditionalTerminalCapabilityCashDeposit       AdditionalTerminalCapability = 0x0280
+	AdditionalTerminalCapabilityNumericKeys       AdditionalTerminalCapability = 0x0380
+	AdditionalTerminalCapabilityAlphabeticKeys    AdditionalTerminalCapability = 0x0340
+	AdditionalTerminalCapabilityCommandKeys       AdditionalTerminalCapability = 0x0320
+	AdditionalTerminalCapabilityFunctionKeys      AdditionalTerminalCapability = 0x0310
+	AdditionalTerminalCapabilityPrintAttendant    AdditionalTerminalCapability = 0x0480
+	AdditionalTerminalCapabilityPrintCardholder   AdditionalTerminalCapability = 0x0440
+	AdditionalTerminalCapabilityDisplayAttendant  AdditionalTerminalCapability = 0x0420
+	AdditionalTerminalCapabilityDisplayCardholder AdditionalTerminalCapability = 0x0410
+	AdditionalTerminalCapabilityCodeTable10       AdditionalTerminalCapability = 0x0402
+	AdditionalTerminalCapabilityCodeTable9        AdditionalTerminalCapability = 0x0401
+	AdditionalTerminalCapabilityCodeTable8        AdditionalTerminalCapability = 0x0580
+	AdditionalTerminalCapabilityCodeTable7        AdditionalTerminalCapability = 0x0540
+	AdditionalTerminalCapabilityCodeTable6        AdditionalTerminalCapability = 0x0520
+	AdditionalTerminalCapabilityCodeTable5        AdditionalTerminalCapability = 0x0510
+	AdditionalTerminalCapabilityCodeTable4        AdditionalTerminalCapability = 0x0508
+	AdditionalTerminalCapabilityCodeTable3        AdditionalTerminalCapability = 0x0504
+	AdditionalTerminalCapabilityCodeTable2        AdditionalTerminalCapability = 0x0502
+	AdditionalTerminalCapabilityCodeTable1        AdditionalTerminalCapability = 0x0501
+)
+
+var additionalTerminalCapabilityNames = []namedBit[AdditionalTerminalCapability]{
+	{AdditionalTerminalCapabilityCash, "Cash"},
+	{AdditionalTerminalCapabilityGoods, "Goods"},
+	{AdditionalTerminalCapabilityServices, "Services"},
+	{AdditionalTerminalCapabilityCashback, "Cashback"},
+	{AdditionalTerminalCapabilityInquiry, "Inquiry"},
+	{AdditionalTerminalCapabilityTransfer, "Transfer"},
+	{AdditionalTerminalCapabilityPayment, "Payment"},
+	{AdditionalTerminalCapabilityAdministrative, "Administrative"},
+	{AdditionalTerminalCapabilityCashDeposit, "Cash deposit"},
+	{AdditionalTerminalCapabilityNumericKeys, "Numeric keys"},
+	{AdditionalTerminalCapabilityAlphabeticKeys, "Alphabetic and special characters keys"},
+	{AdditionalTerminalCapabilityCommandKeys, "Command keys"},
+	{AdditionalTerminalCapabilityFunctionKeys, "Function keys"},
+	{AdditionalTerminalCapabilityPrintAttendant, "Print, attendant"},
+	{AdditionalTerminalCapabilityPrintCardholder, "Print, cardholder"},
+	{AdditionalTerminalCapabilityDisplayAttendant, "Display, attendant"},
+	{AdditionalTerminalCapabilityDisplayCardholder, "Display, cardholder"},
+	{AdditionalTerminalCapabilityCodeTable10, "Code table 10"},
+	{AdditionalTerminalCapabilityCodeTable9, "Code table 9"},
+	{AdditionalTerminalCapabilityCodeTable8, "Code table 8"},
+	{AdditionalTerminalCapabilityCodeTable7, "Code table 7"},
+	{AdditionalTerminalCapabilityCodeTable6, "Code table 6"},
+	{AdditionalTerminalCapabilityCodeTable5, "Code table 5"},
+	{AdditionalTerminalCapabilityCodeTable4, "Code table 4"},
+	{AdditionalTerminalCapabilityCodeTable3, "Code table 3"},
+	{AdditionalTerminalCapabilityCodeTable2, "Code table 2"},
+	{AdditionalTerminalCapabilityCodeTable1, "Code table 1"},
+}
+
+func (b AdditionalTerminalCapability) String() string {
+	return bitName(additionalTerminalCapabilityNames, b, "Additional Terminal Capabilities")
+}
+
+// ParseAdditionalTerminalCapabilities reads the value of the tag 9F40.
+// Missing bytes are considered zero.
+func ParseAdditionalTerminalCapabilities(data []byte) AdditionalTerminalCapabilities {
+	var atc AdditionalTerminalCapabilities
+	copy(atc[:], data)
+	return atc
+}
+
+func (atc *AdditionalTerminalCapabilities) Set(bits ...AdditionalTerminalCapability) {
+	for _, b := range bits {
+		atc[int(b>>8)-1] |= byte(b)
+	}
+}
+
+func (atc AdditionalTerminalCapabilities) Has(bit AdditionalTerminalCapability) bool {
+	return hasBit(atc[:], bit)
+}
+
+func (atc AdditionalTerminalCapabilities) Bits() []AdditionalTerminalCapability {
+	return setBits(atc[:], additionalTerminalCapabilityNames)
+}
+
+func (atc AdditionalTerminalCapabilities) String() string {
+	return fmt.Sprintf("%02X", atc[:])
+}
+
+func (atc AdditionalTerminalCapabilities) GoString() string {
+	return describeBits(atc.String(), atc.Bits())
+}
+
+// TerminalTransactionQualifiers (9F66) indicates the contactless capabilities
+// of the reader and the requirements for the transaction (EMV Book C-3).
+type TerminalTransactionQualifiers [4]byte
+
+// TTQBit is a bit of the Terminal Transaction Qualifiers.
+type TTQBit uint16
+
+const (
+	TTQMagStripeModeSupported             TTQBit = 0x0180
+	TTQEMVModeSupported                   TTQBit = 0x0120
+	TTQEMVContactChipSupported            TTQBit = 0x0110
+	TTQOfflineOnlyReader                  TTQBit = 0x0108
+	TTQOnlinePINSupported                 TTQBit = 0x0104
+	TTQSignatureSupported                 TTQBit = 0x0102
+	TTQOfflineDataAuthenticationForOnline TTQBit = 0x0101
+	TTQOnlineCryptogramRequired           TTQBit = 0x0280
+	TTQCVMRequired                        TTQBit = 0x0240
+	TTQContactChipOfflinePINSupported     TTQBit = 0x0220
+	TTQIssuerUpdateProcessingSupported    TTQBit = 0x0380
+	TTQConsumerDeviceCVMSupported         TTQBit = 0x0340
+)
+
+var ttqBitNames = []namedBit[TTQBit]{
+	{TTQMagStripeModeSupported, "Mag-stripe mode supported"},
+	{TTQEMVModeSupported, "EMV mode supported"},
+	{TTQEMVContactChipSupported, "EMV contact chip supported"},
+	{TTQOfflineOnlyReader, "Offline-only reader"},
+	{TTQOnlinePINSupported, "Online PIN supported"},
+	{TTQSignatureSupported, "Signature supported"},
+	{TTQOfflineDataAuthenticationForOnline, "Offline data authentication for online authorizations supported"},
+	{TTQOnlineCryptogramRequired, "Online cryptogram required"},
+	{TTQCVMRequired, "CVM required"},
+	{TTQContactChipOfflinePINSupported, "(Contact chip) Offline PIN supported"},
+	{TTQIssuerUpdateProcessingSupported, "Issuer update processing supported"},
+	{TTQConsumerDeviceCVMSupported, "Consumer device CVM supported"},
+}
+
+func (b TTQBit) String() string {
+	return bitName(ttqBitNames, b, "TTQ")
+}
+
+// ParseTerminalTransactionQualifiers reads the value of the tag 9F66.
+// Missing bytes are considered zero.
+func ParseTerminalTransactionQualifiers(data []byte) TerminalTransactionQualifiers {
+	var ttq TerminalTransactionQualifiers
+	copy(ttq[:], data)
+	return ttq
+}
+
+func (ttq *TerminalTransactionQualifiers) Set(bits ...TTQBit) {
+	for _, b := range bits {
+		ttq[int(b>>8)-1] |= byte(b)
+	}
+}
+
+func (ttq TerminalTransactionQualifiers) Has(bit TTQBit) bool {
+	return hasBit(ttq[:], bit)
+}
+
+func (ttq TerminalTransactionQualifiers) Bits() []TTQBit {
+	return setBits(ttq[:], ttqBitNames)
+}
+
+func (ttq TerminalTransactionQualifiers) String() string {
+	return fmt.Sprintf("%02X", ttq[:])
+}
+
+func (ttq TerminalTransactionQualifiers) GoString() string {
+	return describeBits(ttq.String(), ttq.Bits())
+}
+
+// CardTransactionQualifiers (9F6C) indicates the CVM and the interface
+// preferences of the card for contactless transactions (EMV Book C-3).
+type CardTransactionQualifiers [2]byte
+
+// CTQBit is a bit of the Card Transaction Qualifiers.
+type CTQBit uint16
+
+const (
+	CTQOnlinePINRequired                     CTQBit = 0x0180
+	CTQSignatureRequired                     CTQBit = 0x0140
+	CTQGoOnlineIfOfflineDataAuthFails        CTQBit = 0x0120
+	CTQSwitchInterfaceIfOfflineDataAuthFails CTQBit = 0x0110
+	CTQGoOnlineIfApplicationExpired          CTQBit = 0x0108
+	CTQSwitchInterfaceForCash                CTQBit = 0x0104
+	CTQSwitchInterfaceForCashback            CTQBit = 0x0102
+	CTQConsumerDeviceCVMPerformed            CTQBit = 0x0280
+	CTQIssuerUpdateProcessingSupported       CTQBit = 0x0240
+)
+
+var ctqBitNames = []namedBit[CTQBit]{
+	{CTQOnlinePINRequired, "Online PIN required"},
+	{CTQSignatureRequired, "Signature required"},
+	{CTQGoOnlineIfOfflineDataAuthFails, "Go online if offline data authentication fails and reader is online capable"},
+	{CTQSwitchInterfaceIfOfflineDataAuthFails, "Switch interface if offline data authentication fails and reader supports contact chip"},
+	{CTQGoOnlineIfApplicationExpired, "Go online if application expired"},
+	{CTQSwitchInterfaceForCash, "Switch interface for cash transactions"},
+	{CTQSwitchInterfaceForCashback, "Switch interface for cashback transactions"},
+	{CTQConsumerDeviceCVMPerformed, "Consumer device CVM performed"},
+	{CTQIssuerUpdateProcessingSupported, "Card supports issuer update processing at the POS"},
+}
+
+func (b CTQBit) String() string {
+	return bitName(ctqBitNames, b, "CTQ")
+}
+
+// ParseCardTransactionQualifiers reads the value of the tag 9F6C.
+// Missing bytes are considered zero.
+func ParseCardTransactionQualifiers(data []byte) CardTransactionQualifiers {
+	var ctq CardTransactionQualifiers
+	copy(ctq[:], data)
+	return ctq
+}
+
+func (ctq CardTransactionQualifiers) Has(bit CTQBit) bool {
+	return hasBit(ctq[:], bit)
+}
+
+func (ctq CardTransactionQualifiers) Bits() []CTQBit {
+	return setBits(ctq[:], ctqBitNames)
+}
+
+func (ctq CardTransactionQualifiers) String() string {
+	return fmt.Sprintf("%02X", ctq[:])
+}
+
+func (ctq CardTransactionQualifiers) GoString() string {
+	return describeBits(ctq.String(), ctq.Bits())
+}
@@ -0,0 +1,112 @@
+package apdu
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAIP(t *testing.T) {
+	aip := AIP{0x39, 0x80}
+
+	assert.False(t, aip.SDASupported())
+	assert.True(t, aip.DDASupported())
+	assert.True(t, aip.CardholderVerificationSupported())
+	assert.True(t, aip.TerminalRiskManagementToBePerformed())
+	assert.False(t, aip.IssuerAuthenticationSupported())
+	assert.True(t, aip.CDASupported())
+	assert.True(t, aip.EMVModeSupported())
+	assert.Equal(t, "3980", aip.String())
+	assert.Equal(t, []AIPBit{
+		AIPDDASupported,
+		AIPCardholderVerificationSupported,
+		AIPTerminalRiskManagementToBePerformed,
+		AIPCDASupported,
+		AIPEMVModeSupported,
+	}, aip.Bits())
+
+	assert.False(t, AIP{}.CDASupported())
+}
+
+func TestApplicationUsageControl(t *testing.T) {
+	auc, err := EMVProprietaryTemplate{UsageControl: "AB80"}.ApplicationUsageControl()
+	require.NoError(t, err)
+
+	assert.True(t, auc.ValidForCash(true))
+	assert.False(t, auc.ValidForCash(false))
+	assert.True(t, auc.ValidForGoods(true))
+	assert.False(t, auc.ValidForGoods(false))
+	assert.True(t, auc.ValidForServices(true))
+	assert.False(t, auc.ValidForServices(false))
+	assert.True(t, auc.ValidAtATMs())
+	assert.True(t, auc.ValidAtTerminalsOtherThanATMs())
+	assert.True(t, auc.CashbackAllowed(true))
+	assert.False(t, auc.CashbackAllowed(false))
+
+	_, err = EMVProprietaryTemplate{UsageControl: "AB"}.ApplicationUsageControl()
+	assert.Error(t, err)
+}
+
+func TestCryptogramInformationData(t *testing.T) {
+	cid := CryptogramInformationData(0x8A)
+
+	assert.Equal(t, ARQC, cid.CryptogramType())
+	assert.True(t, cid.AdviceRequired())
+	assert.Equal(t, CIDPINTryLimitExceeded, cid.ReasonCode())
+	assert.Equal(t, "8A [ARQC, advice required, PIN Try Limit exceeded]", fmt.Sprintf("%#v", cid))
+}
+
+func TestTerminalCapabilities(t *testing.T) {
+	tc := ParseTerminalCapabilities([]byte{0xE0, 0xF8, 0xC8})
+
+	assert.True(t, tc.Has(TerminalCapabilityICWithContacts))
+	assert.True(t, tc.Has(TerminalCapabilityNoCVMRequired))
+	assert.False(t, tc.Has(TerminalCapabilityCardCapture))
+	assert.True(t, tc.Has(TerminalCapabilityCDA))
+	assert.Len(t, tc.Bits(), 11)
+
+	var built TerminalCapabilities
+	built.Set(tc.Bits()...)
+	assert.Equal(t, tc, built)
+	assert.Equal(t, "E0F8C8", built.String())
+}
+
+func TestAdditionalTerminalCapabilities(t *testing.T) {
+	atc := ParseAdditionalTerminalCapabilities([]byte{0x60, 0x00, 0xF0, 0xA0, 0x01})
+
+	assert.Equal(t, []AdditionalTerminalCapability{
+		AdditionalTerminalCapabilityGoods,
+		AdditionalTerminalCapabilityServices,
+		AdditionalTerminalCapabilityNumericKeys,
+		AdditionalTerminalCapabilityAlphabeticKeys,
+		AdditionalTerminalCapabilityCommandKeys,
+		AdditionalTerminalCapabilityFunctionKeys,
+		AdditionalTerminalCapabilityPrintAttendant,
+		AdditionalTerminalCapabilityDisplayAttendant,
+		AdditionalTerminalCapabilityCodeTable1,
+	}, atc.Bits())
+	assert.Equal(t, "Code table 1", AdditionalTerminalCapabilityCodeTable1.String())
+}
+
+func TestTerminalTransactionQualifiers(t *testing.T) {
+	var ttq TerminalTransactionQualifiers
+	ttq.Set(TTQEMVModeSupported, TTQOnlinePINSupported, TTQSignatureSupported, TTQCVMRequired)
+
+	assert.Equal(t, "26400000", ttq.String())
+	assert.True(t, ttq.Has(TTQCVMRequired))
+	assert.False(t, ttq.Has(TTQOnlineCryptogramRequired))
+	assert.Equal(t, ttq, ParseTerminalTransactionQualifiers([]byte{0x26, 0x40, 0x00, 0x00}))
+}
+
+func TestCardTransactionQualifiers(t *testing.T) {
+	ctq := ParseCardTransactionQualifiers([]byte{0x28, 0x80})
+
+	assert.Equal(t, []CTQBit{
+		CTQGoOnlineIfOfflineDataAuthFails,
+		CTQGoOnlineIfApplicationExpired,
+		CTQConsumerDeviceCVMPerformed,
+	}, ctq.Bits())
+	assert.Equal(t, "Consumer device CVM performed", CTQConsumerDeviceCVMPerformed.String())
+}
@@ -0,0 +1,265 @@
+package apdu
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mniak/apdu/internal/ber"
+)
+
+var ErrDuplicateDataObject = errors.New("data object read more than once")
+
+// DuplicateDataObjectError is returned when a primitive data object is read
+// more than once from the card. EMV Book 3, section 10.2, requires the
+// transaction to be terminated in that case.
+type DuplicateDataObjectError struct {
+	Tag Tag
+	// Conflicting tells that the values are different.
+	Conflicting bool
+}
+
+func (e DuplicateDataObjectError) Error() string {
+	if e.Conflicting {
+		return fmt.Sprintf("%s: %s with conflicting values", ErrDuplicateDataObject, e.Tag)
+	}
+	return fmt.Sprintf("%s: %s", ErrDuplicateDataObject, e.Tag)
+}
+
+func (e DuplicateDataObjectError) Is(target error) bool {
+	return target == ErrDuplicateDataObject
+}
+
+// CardData accumulates the primitive data objects read from the card during
+// application selection, GET PROCESSING OPTIONS and READ RECORD, indexed by tag.
+type CardData struct {
+	values map[Tag][]byte
+	order  []Tag
+}
+
+func NewCardData() *CardData {
+	return &CardData{
+		values: make(map[Tag][]byte),
+	}
+}
+
+// Add stores a data object, failing if the tag was already stored.
+func (cd *CardData) Add(tag Tag, value []byte) error {
+	if existing, found := cd.values[tag]; found {
+		return DuplicateDataObjectError{
+			Tag:         tag,
+			Conflicting: !bytes.Equal(existing, value),
+		}
+	}
+	cd.Set(tag, value)
+	return nil
+}
+
+// Set stores a data object replacing any previous value.
+func (cd *CardData) Set(tag Tag, value []byte) {
+	if _, found := cd.values[tag]; !found {
+		cd.order = append(cd.order, tag)
+	}
+	cd.values[tag] = append([]byte{}, value...)
+}
+
+// AddTLV stores all the primitive data objects found in BER-TLV data,
+// looking inside the templates. Application templates (61) are skipped:
+// they are directory entries describing other applications, like in a PSE
+// record or a PPSE FCI, and would repeat the same tags for each entry.
+func (cd *CardData) AddTLV(data []byte) error {
+	tlvs, err := ber.Parse(data)
+	if err != nil {
+		return err
+	}
+	return cd.addTLVs(tlvs)
+}
+
+func (cd *CardData) addTLVs(tlvs []ber.TLV) error {
+	for _, t := range tlvs {
+		if !t.Tag.Constructed() {
+			if err := cd.Add(t.Tag, t.Value); err != nil {
+				return err
+			}
+			continue
+		}
+		if t.Tag == 0x61 {
+			continue
+		}
+		children, err := t.Children()
+		if err != nil {
+			return fmt.Errorf("failed to parse %s: %w", t.Tag, err)
+		}
+		if err := cd.addTLVs(children); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AddGetProcessingOptionsResponse stores the AIP and the AFL of a GET
+// PROCESSING OPTIONS response in either format.
+func (cd *CardData) AddGetProcessingOptionsResponse(data []byte) error {
+	t, _, err := ber.ParseOne(data)
+	if err != nil {
+		return err
+	}
+	if t.Tag != 0x80 {
+		return cd.AddTLV(data)
+	}
+	if len(t.Value) < 2 {
+		return errors.New("GET PROCESSING OPTIONS response format 1 is too short")
+	}
+	if err := cd.Add(0x82, t.Value[:2]); err != nil {
+		return err
+	}
+	return cd.Add(0x94, t.Value[2:])
+}
+
+func (cd *CardData) Get(tag Tag) ([]byte, bool) {
+	value, found := cd.values[tag]
+	return value, found
+}
+
+func (cd *CardData) Has(tag Tag) bool {
+	_, found := cd.values[tag]
+	return found
+}
+
+// Tags returns the tags stored in the order they were first read.
+func (cd *CardData) Tags() []Tag {
+	return append([]Tag{}, cd.order...)
+}
+
+// Values returns a copy of the data objects, which can be used to build DOLs.
+func (cd *CardData) Values() map[Tag][]byte {
+	result := make(map[Tag][]byte, len(cd.values))
+	for tag, value := range cd.values {
+		result[tag] = value
+	}
+	return result
+}
+
+// BuildDOL builds the data of a DOL using the card data and the terminal
+// data. Terminal data takes precedence for tags present in both.
+func (cd *CardData) BuildDOL(dol DataObjectList, terminalData map[Tag][]byte) []byte {
+	values := cd.Values()
+	for tag, value := range terminalData {
+		values[tag] = value
+	}
+	return dol.Build(values)
+}
+
+// Text returns a value of format a, an or ans.
+func (cd *CardData) Text(tag Tag) string {
+	return string(cd.values[tag])
+}
+
+// Numeric decodes a value of format n.
+func (cd *CardData) Numeric(tag Tag) (uint64, error) {
+	value, found := cd.values[tag]
+	if !found {
+		return 0, fmt.Errorf("data object %s not found", tag)
+	}
+	var result uint64
+	for _, b := range value {
+		high, low := b>>4, b&0x0F
+		if high > 9 || low > 9 {
+			return 0, fmt.Errorf("data object %s is not numeric", tag)
+		}
+		result = result*100 + uint64(high)*10 + uint64(low)
+	}
+	return result, nil
+}
+
+// Uint decodes a binary value as a big-endian unsigned integer.
+func (cd *CardData) Uint(tag Tag) (uint64, error) {
+	value, found := cd.values[tag]
+	if !found {
+		return 0, fmt.Errorf("data object %s not found", tag)
+	}
+	if len(value) > 8 {
+		return 0, fmt.Errorf("data object %s is too long for an integer", tag)
+	}
+	var result uint64
+	for _, b := range value {
+		result = result<<8 | uint64(b)
+	}
+	return result, nil
+}
+
+func (cd *CardData) dol(tag Tag) (DataObjectList, error) {
+	value, found := cd.values[tag]
+	if !found {
+		return nil, nil
+	}
+	return ParseDOL(value)
+}
+
+func (cd *CardData) AIP() AIP {
+	return cd.values[0x82]
+}
+
+func (cd *CardData) AFL() AFL {
+	return cd.values[0x94]
+}
+
+func (cd *CardData) PDOL() (DataObjectList, error) {
+	return cd.dol(0x9F38)
+}
+
+func (cd *CardData) CDOL1() (DataObjectList, error) {
+	return cd.dol(0x8C)
+}
+
+func (cd *CardData) CDOL2() (DataObjectList, error) {
+	return cd.dol(0x8D)
+}
+
+func (cd *CardData) DDOL() (DataObjectList, error) {
+	return cd.dol(0x9F49)
+}
+
+func (cd *CardData) PAN() (PAN, error) {
+	return ParsePAN(fmt.Sprintf("%X", cd.values[0x5A]))
+}
+
+func (cd *CardData) Track2() (Track2, error) {
+	return ParseTrack2(cd.values[0x57])
+}
+
+func (cd *CardData) ApplicationExpirationDate() (time.Time, error) {
+	return ParseDate(fmt.Sprintf("%X", cd.values[0x5F24]))
+}
+
+func (cd *CardData) ApplicationEffectiveDate() (time.Time, error) {
+	return ParseDate(fmt.Sprintf("%X", cd.values[0x5F25]))
+}
+
+func (cd *CardData) ApplicationUsageControl() (ApplicationUsageControl, error) {
+	return ParseApplicationUsageControl(cd.values[0x9F07])
+}
+
+func (cd *CardData) CVMList() (CVMList, error) {
+	var list CVMList
+	err := list.Unmarshal(cd.values[0x8E])
+	return list, err
+}
+
+// Template returns the data as an EMVProprietaryTemplate for the code that
+// still depends on it.
+func (cd *CardData) Template() (EMVProprietaryTemplate, error) {
+	var record []byte
+	for _, tag := range cd.order {
+		record = append(record, ber.Encode(tag, cd.values[tag])...)
+	}
+	rt, err := unmarshal[RecordTemplate](ber.Encode(0x70, record), nil)
+	if err != nil {
+		return EMVProprietaryTemplate{}, err
+	}
+	if len(rt.EMVProprietaryTemplates) == 0 {
+		return EMVProprietaryTemplate{}, nil
+	}
+	return rt.EMVProprietaryTemplates[0], nil
+}
@@ -0,0 +1,95 @@
+package apdu
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mniak/apdu/internal/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCardData(t *testing.T) {
+	cd := NewCardData()
+
+	require.NoError(t, cd.AddTLV(test.MustParseHex(t, "6F1A8407A0000000041010A50F500A4D415354455243415244870101")))
+	require.NoError(t, cd.AddGetProcessingOptionsResponse(test.MustParseHex(t, "800A19800801010010010101")))
+	require.NoError(t, cd.AddTLV(test.MustParseHex(t, "701F5A0854133300890200115F24032512318C089F02069A039F37049F0702FF00")))
+
+	assert.Equal(t, []Tag{0x84, 0x50, 0x87, 0x82, 0x94, 0x5A, 0x5F24, 0x8C, 0x9F07}, cd.Tags())
+	assert.Equal(t, "MASTERCARD", cd.Text(0x50))
+	assert.Equal(t, AIP{0x19, 0x80}, cd.AIP())
+	assert.Equal(t, AFL(test.MustParseHex(t, "0801010010010101")), cd.AFL())
+
+	pan, err := cd.PAN()
+	require.NoError(t, err)
+	assert.Equal(t, PAN("5413330089020011"), pan)
+
+	expiration, err := cd.ApplicationExpirationDate()
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2025, 12, 31, 0, 0, 0, 0, time.UTC), expiration)
+
+	auc, err := cd.ApplicationUsageControl()
+	require.NoError(t, err)
+	assert.True(t, auc.ValidAtATMs())
+
+	priority, err := cd.Uint(0x87)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), priority)
+
+	cdol1, err := cd.CDOL1()
+	require.NoError(t, err)
+	transactionData := cd.BuildDOL(cdol1, map[Tag][]byte{
+		0x9F02: test.MustParseHex(t, "000000001000"),
+		0x9A:   test.MustParseHex(t, "261019"),
+		0x9F37: test.MustParseHex(t, "01020304"),
+	})
+	test.AssertBytesEqual(t, "00000000100026101901020304", transactionData)
+}
+
+func TestCardData_Duplicates(t *testing.T) {
+	cd := NewCardData()
+	require.NoError(t, cd.AddTLV(test.MustParseHex(t, "700A5A085413330089020011")))
+
+	err := cd.AddTLV(test.MustParseHex(t, "700A5A085413330089020011"))
+	require.ErrorIs(t, err, ErrDuplicateDataObject)
+	var duplicate DuplicateDataObjectError
+	require.True(t, errors.As(err, &duplicate))
+	assert.Equal(t, Tag(0x5A), duplicate.Tag)
+	assert.False(t, duplicate.Conflicting)
+
+	err = cd.AddTLV(test.MustParseHex(t, "700A5A085413330089020029"))
+	require.True(t, errors.As(err, &duplicate))
+	assert.True(t, duplicate.Conflicting)
+
+	cd.Set(0x5A, test.MustParseHex(t, "5413330089020029"))
+	value, found := cd.Get(0x5A)
+	require.True(t, found)
+	test.AssertBytesEqual(t, "5413330089020029", value)
+}
+
+func TestCardData_DirectoryEntries(t *testing.T) {
+	cd := NewCardData()
+
+	require.NoError(t, cd.AddTLV(test.MustParseHex(t, ""+
+		"70 34"+
+		"61 18 4F07A0000000041010 500A4D415354455243415244 870101"+
+		"61 18 4F07A0000000043060 500A4D41455354524F202020 870102")))
+	assert.Empty(t, cd.Tags())
+}
+
+func TestCardData_Numeric(t *testing.T) {
+	cd := NewCardData()
+	cd.Set(0x9F02, test.MustParseHex(t, "000000012345"))
+	cd.Set(0x9F03, test.MustParseHex(t, "00000001234A"))
+
+	amount, err := cd.Numeric(0x9F02)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(12345), amount)
+
+	_, err = cd.Numeric(0x9F03)
+	assert.Error(t, err)
+	_, err = cd.Numeric(0x9F04)
+	assert.Error(t, err)
+}
@@ -22,18 +22,50 @@ func (c Class) LastInChain() bool {
 	return !c.Proprietary() && c&0b0001_0000 == 0
 }
 
-// func (c Class) SecureMessagingIndication() SecureMessagingIndication {
-// 	if c.Proprietary() {
-// 		return NoSMOrNoIndication
-// 	}
-// 	return NoSMOrNoIndication
-// }
+// SecureMessagingIndication decodes the bits b4 and b3 of first interindustry
+// classes and the bit b6 of further interindustry classes. Proprietary classes
+// give no indication.
+func (c Class) SecureMessagingIndication() SecureMessagingIndication {
+	switch {
+	case c.Proprietary():
+		return NoSMOrNoIndication
+	case c&0b1110_0000 == 0b0000_0000:
+		switch c & 0b0000_1100 {
+		case 0b0000_0100:
+			return ProprietarySMFormat
+		case 0b0000_1000:
+			return SMAccordingToSection6_NotProcessed
+		case 0b0000_1100:
+			return SMAccordingToSection6_Authenticated
+		}
+	case c&0b1100_0000 == 0b0100_0000:
+		if c&0b0010_0000 != 0 {
+			return SMAccordingToSection6_NotProcessed
+		}
+	}
+	return NoSMOrNoIndication
+}
+
+// WithSecureMessaging returns the class indicating that the command is
+// protected with secure messaging and the header is authenticated. Further
+// interindustry classes can't indicate header authentication, and proprietary
+// classes follow the usual EMV and GlobalPlatform convention of setting b3.
+func (c Class) WithSecureMessaging() Class {
+	switch {
+	case c.Proprietary():
+		return c | 0b0000_0100
+	case c&0b1110_0000 == 0b0000_0000:
+		return c | 0b0000_1100
+	default:
+		return c | 0b0010_0000
+	}
+}
 
 type SecureMessagingIndication string
 
 const (
-	NoSMOrNoIndication                  = "No SM or no indication"
-	ProprietarySMFormat                 = "Proprietary SM format"
-	SMAccordingToSection6_NotProcessed  = "SM according to section 6, command header not processed according to 6.2.3.1"
-	SMAccordingToSection6_Authenticated = "SM according to section 6, command header authenticated according to 6.2.3.1"
+	NoSMOrNoIndication                  SecureMessagingIndication = "No SM or no indication"
+	ProprietarySMFormat                 SecureMessagingIndication = "Proprietary SM format"
+	SMAccordingToSection6_NotProcessed  SecureMessagingIndication = "SM according to section 6, command header not processed according to 6.2.3.1"
+	SMAccordingToSection6_Authenticated SecureMessagingIndication = "SM according to section 6, command header authenticated according to 6.2.3.1"
 )
@@ -148,71 +148,71 @@ func TestClass_LastInChain(t *testing.T) {
 	}
 }
 
-// func TestClass_SecureMessaging(t *testing.T) {
-// 	testdata := []struct {
-// 		template test.ByteTemplate
-// 		expected SecureMessagingIndication
-// 	}{
-// 		// First interindustry values
-// 		{
-// 			template: "000x_00xx",
-// 			expected: NoSMOrNoIndication,
-// 		},
-// 		{
-// 			template: "000x_01xx",
-// 			expected: ProprietarySMFormat,
-// 		},
-// 		{
-// 			template: "000x_10xx",
-// 			expected: SMAccordingToSection6_NotProcessed,
-// 		},
-// 		{
-// 			template: "000x_11xx",
-// 			expected: SMAccordingToSection6_Authenticated,
-// 		},
+func TestClass_SecureMessaging(t *testing.T) {
+	testdata := []struct {
+		template test.ByteTemplate
+		expected SecureMessagingIndication
+	}{
+		// First interindustry values
+		{
+			template: "000x_00xx",
+			expected: NoSMOrNoIndication,
+		},
+		{
+			template: "000x_01xx",
+			expected: ProprietarySMFormat,
+		},
+		{
+			template: "000x_10xx",
+			expected: SMAccordingToSection6_NotProcessed,
+		},
+		{
+			template: "000x_11xx",
+			expected: SMAccordingToSection6_Authenticated,
+		},
 
-// 		// Futher interindustry values
-// 		{
-// 			template: "010x_00xx",
-// 			expected: NoSMOrNoIndication,
-// 		},
-// 		{
-// 			template: "011x_00xx",
-// 			expected: SMAccordingToSection6_NotProcessed,
-// 		},
+		// Futher interindustry values
+		{
+			template: "010x_00xx",
+			expected: NoSMOrNoIndication,
+		},
+		{
+			template: "011x_00xx",
+			expected: SMAccordingToSection6_NotProcessed,
+		},
 
-// 		// Unspecified values
-// 		{
-// 			template: "001x_11xx",
-// 			expected: NoSMOrNoIndication,
-// 		},
-// 		{
-// 			template: "1xxx_11xx",
-// 			expected: NoSMOrNoIndication,
-// 		},
-// 	}
-// 	for _, td := range testdata {
-// 		t.Run(fmt.Sprintf("%s,last=%v", td.template.String(), td.expected), func(t *testing.T) {
-// 			t.Run(fmt.Sprintf("Min:%08b", td.template.Min(t)), func(t *testing.T) {
-// 				class := Class(td.template.Min(t))
-// 				assert.Equal(t, td.expected, class.SecureMessagingIndication())
-// 			})
+		// Unspecified values
+		{
+			template: "001x_11xx",
+			expected: NoSMOrNoIndication,
+		},
+		{
+			template: "1xxx_11xx",
+			expected: NoSMOrNoIndication,
+		},
+	}
+	for _, td := range testdata {
+		t.Run(fmt.Sprintf("%s,last=%v", td.template.String(), td.expected), func(t *testing.T) {
+			t.Run(fmt.Sprintf("Min:%08b", td.template.Min(t)), func(t *testing.T) {
+				class := Class(td.template.Min(t))
+				assert.Equal(t, td.expected, class.SecureMessagingIndication())
+			})
 
-// 			t.Run(fmt.Sprintf("Max:%08b", td.template.Max(t)), func(t *testing.T) {
-// 				class := Class(td.template.Max(t))
-// 				assert.Equal(t, td.expected, class.SecureMessagingIndication())
-// 			})
+			t.Run(fmt.Sprintf("Max:%08b", td.template.Max(t)), func(t *testing.T) {
+				class := Class(td.template.Max(t))
+				assert.Equal(t, td.expected, class.SecureMessagingIndication())
+			})
 
-// 			for i := 0; i < 10; i++ {
-// 				randomValue := td.template.Random(t)
-// 				t.Run(fmt.Sprintf("%08b", randomValue), func(t *testing.T) {
-// 					class := Class(randomValue)
-// 					assert.Equal(t, td.expected, class.SecureMessagingIndication())
-// 				})
-// 			}
-// 		})
-// 	}
-// }
+			for i := 0; i < 10; i++ {
+				randomValue := td.template.Random(t)
+				t.Run(fmt.Sprintf("%08b", randomValue), func(t *testing.T) {
+					class := Class(randomValue)
+					assert.Equal(t, td.expected, class.SecureMessagingIndication())
+				})
+			}
+		})
+	}
+}
 
 // func TestClass_Invalids(t *testing.T) {
 // 	testdata := []struct {
@@ -7,11 +7,17 @@ type Client struct {
 }
 
 func NewClient(driver Driver) Client {
-	raw := NewRawClient(driver)
+	return NewClientWithRaw(NewRawClient(driver))
+}
+
+// NewClientWithRaw builds the command layers on top of a raw client, such as
+// a secure channel that decorates the client of the driver.
+func NewClientWithRaw(raw RawClient) Client {
 	low := _LowLevelClient{
 		RawClient: raw,
 	}
 	high := _HighLevelClient{
+		Raw: raw,
 		Low: low,
 	}
 	return Client{
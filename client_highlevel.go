@@ -2,7 +2,9 @@ package apdu
 
 import (
 	"errors"
+	"fmt"
 
+	"github.com/mniak/apdu/internal/ber"
 	"github.com/mniak/tlv"
 )
 
@@ -15,12 +17,28 @@ type HighLevelCommands interface {
 	// and returns the previous records returned.
 	ReadAllRecords(sfi int) ([]RecordTemplate, error)
 	GetPSE(contactless bool) ([]RecordTemplate, error)
+
+	// SelectPPSE selects the Proximity Payment System Environment and
+	// returns the directory entries found in its FCI.
+	SelectPPSE() ([]DirectoryEntry, error)
 	GetProcessingOptions(pdolData []byte) (GetProcessingOptionsResponse, error)
 	GenerateARQC(cdolData []byte) (GenerateACResponse, error)
 	GenerateTC(transactionData []byte) (GenerateACResponse, error)
+	GenerateAAC(transactionData []byte) (GenerateACResponse, error)
+	InternalAuthenticate(ddolData []byte) (InternalAuthenticateResponse, error)
+	ExternalAuthenticate(issuerAuthenticationData []byte) error
+
+	// GetData retrieves a primitive data object not encapsulated in a record,
+	// like the ATC (9F36), and returns its value.
+	GetData(tag Tag) ([]byte, error)
+
+	// CompleteOnlineTransaction delivers the issuer response to the card and
+	// requests the second application cryptogram using CDOL2.
+	CompleteOnlineTransaction(aip AIP, cdol2 DataObjectList, terminalData map[Tag][]byte, response OnlineResponse) (OnlineCompletion, error)
 }
 
 type _HighLevelClient struct {
+	Raw RawClient
 	Low LowLevelCommands
 }
 
@@ -61,15 +79,15 @@ func (c _HighLevelClient) ReadAllRecords(sfi int) ([]RecordTemplate, error) {
 }
 
 func (c _HighLevelClient) GetPSE(contactless bool) ([]RecordTemplate, error) {
-	const PSE1 = "1PAY.SYS.DDF01"
-	const PSE2 = "2PAY.SYS.DDF01"
-
-	dfname := []byte(PSE1)
 	if contactless {
-		dfname = []byte(PSE2)
+		entries, err := c.SelectPPSE()
+		if err != nil {
+			return nil, err
+		}
+		return []RecordTemplate{ppseRecord(entries)}, nil
 	}
 
-	fci, err := c.SelectByName(dfname)
+	fci, err := c.SelectByName([]byte(PSEName))
 	if err != nil {
 		return nil, err
 	}
@@ -85,6 +103,33 @@ func (c _HighLevelClient) GetPSE(contactless bool) ([]RecordTemplate, error) {
 	return records, nil
 }
 
+func (c _HighLevelClient) SelectPPSE() ([]DirectoryEntry, error) {
+	fci, err := c.Low.SelectByName([]byte(PPSEName))
+	if err != nil {
+		return nil, err
+	}
+	return ParsePPSE(fci)
+}
+
+// ppseRecord represents the PPSE directory entries like the records of a PSE.
+func ppseRecord(entries []DirectoryEntry) RecordTemplate {
+	templates := make([]ApplicationTemplate, len(entries))
+	for i, e := range entries {
+		label := e.Label
+		priority := int(e.PriorityIndicator)
+		templates[i] = ApplicationTemplate{
+			ID:                e.ADFName,
+			Label:             &label,
+			PriorityIndicator: &priority,
+		}
+	}
+	return RecordTemplate{
+		EMVProprietaryTemplates: []EMVProprietaryTemplate{{
+			ApplicationTemplates: templates,
+		}},
+	}
+}
+
 func (c _HighLevelClient) GenerateARQC(transactionData []byte) (GenerateACResponse, error) {
 	return unmarshal[GenerateACResponse](
 		c.Low.GenerateAC(ARQC, transactionData),
@@ -97,6 +142,38 @@ func (c _HighLevelClient) GenerateTC(transactionData []byte) (GenerateACResponse
 	)
 }
 
+func (c _HighLevelClient) GenerateAAC(transactionData []byte) (GenerateACResponse, error) {
+	return unmarshal[GenerateACResponse](
+		c.Low.GenerateAC(AAC, transactionData),
+	)
+}
+
+func (c _HighLevelClient) ExternalAuthenticate(issuerAuthenticationData []byte) error {
+	_, err := c.Low.ExternalAuthenticate(issuerAuthenticationData)
+	return err
+}
+
+func (c _HighLevelClient) GetData(tag Tag) ([]byte, error) {
+	data, err := c.Low.GetData(tag)
+	if err != nil {
+		return nil, err
+	}
+	tlv, _, err := ber.ParseOne(data)
+	if err != nil {
+		return nil, err
+	}
+	if tlv.Tag != tag {
+		return nil, fmt.Errorf("GET DATA returned tag %s instead of %s", tlv.Tag, tag)
+	}
+	return tlv.Value, nil
+}
+
+func (c _HighLevelClient) InternalAuthenticate(ddolData []byte) (InternalAuthenticateResponse, error) {
+	return unmarshal[InternalAuthenticateResponse](
+		c.Low.InternalAuthenticate(ddolData),
+	)
+}
+
 func unmarshal[T any](data []byte, err error) (T, error) {
 	var result T
 	if err != nil {
@@ -12,6 +12,11 @@ type LowLevelCommands interface {
 	ReadRecord(sfi, recordNumber int) ([]byte, error)
 	GetProcessingOptions(pdolData []byte) ([]byte, error)
 	GenerateAC(cryptogramType ApplicationCryptogramType, transactionData []byte) ([]byte, error)
+	GenerateACWithCDA(cryptogramType ApplicationCryptogramType, transactionData []byte) ([]byte, error)
+	InternalAuthenticate(ddolData []byte) ([]byte, error)
+	ExternalAuthenticate(issuerAuthenticationData []byte) ([]byte, error)
+	GetData(tag Tag) ([]byte, error)
+	ComputeCryptographicChecksum(udolData []byte) ([]byte, error)
 	VerifyPlaintextPIN(pinDigits []int) ([]byte, error)
 }
 
@@ -80,12 +85,23 @@ const (
 	ARQC ApplicationCryptogramType = 0b10
 )
 
+// generateACCDARequested is the bit of P1 that requests a CDA signature
+const generateACCDARequested = 0b0001_0000
+
 func (c _LowLevelClient) GenerateAC(cryptogramType ApplicationCryptogramType, transactionData []byte) ([]byte, error) {
+	return c.generateAC(byte(cryptogramType<<6), transactionData)
+}
+
+func (c _LowLevelClient) GenerateACWithCDA(cryptogramType ApplicationCryptogramType, transactionData []byte) ([]byte, error) {
+	return c.generateAC(byte(cryptogramType<<6)|generateACCDARequested, transactionData)
+}
+
+func (c _LowLevelClient) generateAC(p1 byte, transactionData []byte) ([]byte, error) {
 	cmd := Command{
 		Class:       0x80,
 		Instruction: EMVInstructionAE_GenerateAC,
 		Parameters: Parameters{
-			P1: byte(cryptogramType << 6),
+			P1: p1,
 			P2: 0x00,
 		},
 		Data: transactionData,
@@ -97,6 +113,75 @@ func (c _LowLevelClient) GenerateAC(cryptogramType ApplicationCryptogramType, tr
 	return resp.Data, resp.Trailer.GetError()
 }
 
+func (c _LowLevelClient) InternalAuthenticate(ddolData []byte) ([]byte, error) {
+	cmd := Command{
+		Class:       0x00,
+		Instruction: Instruction88_InternalAuthenticate,
+		Parameters: Parameters{
+			P1: 0x00,
+			P2: 0x00,
+		},
+		Data: ddolData,
+	}
+	resp, err := c.SendCommand(cmd)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Data, resp.Trailer.GetError()
+}
+
+func (c _LowLevelClient) ExternalAuthenticate(issuerAuthenticationData []byte) ([]byte, error) {
+	cmd := Command{
+		Class:       0x00,
+		Instruction: Instruction82_ExternalMutualAuthenticate,
+		Parameters: Parameters{
+			P1: 0x00,
+			P2: 0x00,
+		},
+		Data: issuerAuthenticationData,
+	}
+	resp, err := c.SendCommand(cmd)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Data, resp.Trailer.GetError()
+}
+
+func (c _LowLevelClient) GetData(tag Tag) ([]byte, error) {
+	cmd := Command{
+		Class:       0x80,
+		Instruction: InstructionCA_GetData,
+		Parameters: Parameters{
+			P1: byte(tag >> 8),
+			P2: byte(tag),
+		},
+	}
+	resp, err := c.SendCommand(cmd)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Data, resp.Trailer.GetError()
+}
+
+// ComputeCryptographicChecksum requests the CVC3 used in contactless
+// mag-stripe mode transactions.
+func (c _LowLevelClient) ComputeCryptographicChecksum(udolData []byte) ([]byte, error) {
+	cmd := Command{
+		Class:       0x80,
+		Instruction: EMVInstruction2A_ComputeCryptographicChecksum,
+		Parameters: Parameters{
+			P1: 0x8E,
+			P2: 0x80,
+		},
+		Data: udolData,
+	}
+	resp, err := c.SendCommand(cmd)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Data, resp.Trailer.GetError()
+}
+
 func (c _LowLevelClient) VerifyPlaintextPIN(pinDigits []int) ([]byte, error) {
 	if len(pinDigits) < 4 {
 		return nil, errors.New("the PIN is too short")
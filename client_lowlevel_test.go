@@ -46,3 +46,30 @@ func TestVerifyPlaintextPIN(t *testing.T) {
 	require.NoError(t, err)
 	_ = resp
 }
+
+func TestGenerateACWithCDA(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	transactionData := []byte(gofakeit.SentenceSimple())
+
+	mockClient := NewMockRawClient(ctrl)
+	mockClient.EXPECT().
+		SendCommand(gomock.Any()).
+		Do(func(cmd Command) {
+			assert.Equal(t, byte(0x80), byte(cmd.Class))
+			assert.Equal(t, byte(0xAE), byte(cmd.Instruction))
+			assert.Equal(t, byte(0x90), byte(cmd.Parameters.P1))
+			assert.Equal(t, byte(0x00), byte(cmd.Parameters.P2))
+			assert.Equal(t, transactionData, cmd.Data)
+		}).
+		Return(Response{
+			Trailer: NewTrailer(0x90, 0x00),
+		}, nil)
+
+	lowlevel := _LowLevelClient{
+		RawClient: mockClient,
+	}
+	_, err := lowlevel.GenerateACWithCDA(ARQC, transactionData)
+	require.NoError(t, err)
+}
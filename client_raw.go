@@ -16,27 +16,104 @@ type RawClient interface {
 }
 
 type _RawClient struct {
-	driver        Driver
-	lengthEncoder tlv.LengthEncoder
-	logger        *log.Logger
+	driver Driver
+	logger *log.Logger
+	// capabilities tell whether extended length or command chaining are
+	// used for commands with more than 255 bytes of data
+	capabilities CardCapabilities
+	// verbose enables the log of the APDUs, which are pretty-printed only
+	// when LoggingTo is called
+	verbose bool
 }
 
 func (d *_RawClient) LoggingTo(w io.Writer) *_RawClient {
 	d.logger = log.New(w, "[Client] ", 0)
+	d.verbose = true
 	return d
 }
 
-func NewRawClient(driver Driver) RawClient {
+// NewRawClient creates a client that sends the commands through the driver.
+// When the driver provides the ATR, the card capabilities found in its
+// historical bytes are used right away. The cards that keep them in
+// EF.ATR/INFO need a call to DetectCapabilities.
+func NewRawClient(driver Driver) *_RawClient {
 	result := &_RawClient{
-		driver:        driver,
-		lengthEncoder: tlv.ShortLengthEncoder,
-		logger:        noop.Logger(),
+		driver: driver,
+		logger: noop.Logger(),
+	}
+	if historical, ok := result.historicalBytes(); ok {
+		result.capabilities, _ = historical.CardCapabilities()
 	}
 	return result
 }
 
-func (c _RawClient) internalSendCommand(cmd Command) (Response, error) {
-	bytes, err := cmd.Bytes(c.lengthEncoder)
+// WithCardCapabilities sets the capabilities of the card, for when they are
+// known beforehand.
+func (c *_RawClient) WithCardCapabilities(capabilities CardCapabilities) *_RawClient {
+	c.capabilities = capabilities
+	return c
+}
+
+// DetectCapabilities reads the card capabilities from EF.ATR/INFO when the
+// card service data in the historical bytes indicates it. It does nothing
+// when the capabilities were found in the historical bytes, and returns
+// ErrCardCapabilitiesNotFound when the card has none.
+func (c *_RawClient) DetectCapabilities() error {
+	historical, ok := c.historicalBytes()
+	if !ok {
+		return ErrCardCapabilitiesNotFound
+	}
+	if capabilities, ok := historical.CardCapabilities(); ok {
+		c.capabilities = capabilities
+		return nil
+	}
+	service, ok := historical.Find(CompactTagCardServiceData)
+	if !ok || len(service) == 0 || service[0]&cardServiceDataInATRInfo == 0 {
+		return ErrCardCapabilitiesNotFound
+	}
+	capabilities, err := ReadCardCapabilities(c)
+	if err != nil {
+		return err
+	}
+	c.capabilities = capabilities
+	return nil
+}
+
+// historicalBytes parses the historical bytes of the ATR, when the driver
+// provides it.
+func (c *_RawClient) historicalBytes() (HistoricalBytes, bool) {
+	provider, ok := c.driver.(ATRProvider)
+	if !ok {
+		return HistoricalBytes{}, false
+	}
+	raw, err := provider.ATR()
+	if err != nil || len(raw) == 0 {
+		return HistoricalBytes{}, false
+	}
+	atr, err := ParseATR(raw)
+	if err != nil {
+		return HistoricalBytes{}, false
+	}
+	historical, err := ParseHistoricalBytes(atr.HistoricalBytes)
+	if err != nil {
+		return HistoricalBytes{}, false
+	}
+	return historical, true
+}
+
+// cardServiceDataInATRInfo is the bit of the card service data byte that
+// indicates data objects available in EF.ATR/INFO.
+const cardServiceDataInATRInfo = 0b0001_0000
+
+func (c _RawClient) encode(cmd Command) ([]byte, error) {
+	if len(cmd.Data) > 0xFF && c.capabilities.ExtendedLength() {
+		return cmd.ExtendedBytes()
+	}
+	return cmd.Bytes(tlv.ShortLengthEncoder)
+}
+
+func (c _RawClient) transmit(cmd Command) (Response, error) {
+	bytes, err := c.encode(cmd)
 	if err != nil {
 		return Response{}, err
 	}
@@ -44,7 +121,39 @@ func (c _RawClient) internalSendCommand(cmd Command) (Response, error) {
 	if err != nil {
 		return Response{}, err
 	}
-	resp, err := ParseResponse(responseBytes)
+	return ParseResponse(responseBytes)
+}
+
+// transmitChained splits the data of the command in parts of 255 bytes sent
+// with the chaining bit of the class set, except for the last one.
+func (c _RawClient) transmitChained(cmd Command) (Response, error) {
+	data := cmd.Data
+	for len(data) > 0xFF {
+		part := Command{
+			Class:       cmd.Class | 0b0001_0000,
+			Instruction: cmd.Instruction,
+			Parameters:  cmd.Parameters,
+			Data:        data[:0xFF],
+		}
+		resp, err := c.transmit(part)
+		if err != nil || resp.Trailer != 0x9000 {
+			return resp, err
+		}
+		data = data[0xFF:]
+	}
+	last := cmd
+	last.Data = data
+	return c.transmit(last)
+}
+
+func (c _RawClient) internalSendCommand(cmd Command) (Response, error) {
+	var resp Response
+	var err error
+	if len(cmd.Data) > 0xFF && !c.capabilities.ExtendedLength() && c.capabilities.CommandChaining() {
+		resp, err = c.transmitChained(cmd)
+	} else {
+		resp, err = c.transmit(cmd)
+	}
 	if err != nil {
 		return resp, err
 	}
@@ -82,7 +191,7 @@ func (c _RawClient) internalSendCommand(cmd Command) (Response, error) {
 		}
 
 		var moreDataCmdBytes []byte
-		moreDataCmdBytes, err = moreDataCmd.Bytes(c.lengthEncoder)
+		moreDataCmdBytes, err = c.encode(moreDataCmd)
 		if err != nil {
 			return Response{}, err
 		}
@@ -100,13 +209,30 @@ func (c _RawClient) internalSendCommand(cmd Command) (Response, error) {
 }
 
 func (c _RawClient) SendCommand(cmd Command) (Response, error) {
-	cmdbytes, err := cmd.Bytes(c.lengthEncoder)
-	if err != nil {
-		return Response{}, err
+	if c.verbose {
+		c.logCommand(cmd)
 	}
-	c.logger.Printf("APDU sent: %2X\n%s\n", cmdbytes, utils.IndentString(cmd.StringPretty(), "  "))
-
 	resp, err := c.internalSendCommand(cmd)
-	c.logger.Printf("APDU received: [%2X] [%02X %02X]\n", resp.Data, resp.Trailer.SW1(), resp.Trailer.SW2())
+	if c.verbose {
+		c.logResponse(resp)
+	}
 	return resp, err
 }
+
+func (c _RawClient) logCommand(cmd Command) {
+	if cmdbytes, err := c.encode(cmd); err == nil {
+		c.logger.Printf("APDU sent: %2X\n%s\n", cmdbytes, utils.IndentString(cmd.StringPretty(), "  "))
+	} else {
+		// Sent in parts with command chaining
+		c.logger.Printf("APDU sent:\n%s\n", utils.IndentString(cmd.StringPretty(), "  "))
+	}
+}
+
+func (c _RawClient) logResponse(resp Response) {
+	c.logger.Printf("APDU received: [%2X] [%02X %02X]\n", resp.Data, resp.Trailer.SW1(), resp.Trailer.SW2())
+	if len(resp.Data) > 0 {
+		if pretty, err := PrettyTLV(resp.Data); err == nil {
+			c.logger.Println(utils.IndentString(pretty, "  "))
+		}
+	}
+}
@@ -0,0 +1,25 @@
+package apdu
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/mniak/apdu/internal/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRawClient_LoggingTo(t *testing.T) {
+	driver := &atrDriver{
+		responses: map[Instruction][]byte{
+			InstructionB2_ReadRecords: test.MustParseHex(t, "7003 5F2000 9000"),
+		},
+	}
+	var log bytes.Buffer
+	client := NewRawClient(driver).LoggingTo(&log)
+
+	_, err := client.SendCommand(Command{Class: 0x00, Instruction: InstructionB2_ReadRecords, Parameters: Parameters{P1: 0x01, P2: 0x0C}})
+	require.NoError(t, err)
+	assert.Contains(t, log.String(), "APDU sent: 00B2010C00")
+	assert.Contains(t, log.String(), "5F20 Cardholder Name")
+}
@@ -0,0 +1,279 @@
+package apdu
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+
+	"github.com/mniak/apdu/internal/ber"
+	"github.com/mniak/apdu/internal/utils"
+)
+
+var (
+	ErrSecureMessagingMissing = errors.New("response is not protected with secure messaging")
+	ErrInvalidResponseMAC     = errors.New("response MAC does not match")
+	ErrSecureMessagingBroken  = errors.New("secure messaging session was ended by an unprotected error response")
+)
+
+// SecureMessagingCipherSuite provides the cryptographic functions of ISO/IEC
+// 7816-4 secure messaging with a pair of session keys.
+type SecureMessagingCipherSuite interface {
+	// BlockSize is used for padding and is also the length of the send
+	// sequence counter.
+	BlockSize() int
+	// Encrypt and Decrypt receive the current send sequence counter, which
+	// some suites use to compute the IV.
+	Encrypt(ssc, data []byte) ([]byte, error)
+	Decrypt(ssc, data []byte) ([]byte, error)
+	// MAC computes the 8 bytes cryptographic checksum of the padded data.
+	MAC(data []byte) ([]byte, error)
+}
+
+type tripleDESSecureMessaging struct {
+	enc    cipher.Block
+	macKey []byte
+}
+
+// NewTripleDESSecureMessaging creates a suite using 3DES in CBC mode with a
+// zero IV for encryption and the retail MAC, as used by ICAO Doc 9303 BAC.
+func NewTripleDESSecureMessaging(encKey, macKey []byte) (SecureMessagingCipherSuite, error) {
+	enc, err := utils.NewTripleDES(encKey)
+	if err != nil {
+		return nil, err
+	}
+	if len(macKey) != 16 {
+		return nil, fmt.Errorf("invalid MAC key length: %d", len(macKey))
+	}
+	return tripleDESSecureMessaging{
+		enc:    enc,
+		macKey: macKey,
+	}, nil
+}
+
+func (s tripleDESSecureMessaging) BlockSize() int {
+	return 8
+}
+
+func (s tripleDESSecureMessaging) Encrypt(_, data []byte) ([]byte, error) {
+	return utils.EncryptCBC(s.enc, make([]byte, 8), data)
+}
+
+func (s tripleDESSecureMessaging) Decrypt(_, data []byte) ([]byte, error) {
+	return utils.DecryptCBC(s.enc, make([]byte, 8), data)
+}
+
+func (s tripleDESSecureMessaging) MAC(data []byte) ([]byte, error) {
+	return utils.RetailMAC(s.macKey, data)
+}
+
+type aesSecureMessaging struct {
+	enc cipher.Block
+	mac cipher.Block
+}
+
+// NewAESSecureMessaging creates a suite using AES in CBC mode with the IV
+// derived from the send sequence counter for encryption and the AES CMAC
+// truncated to 8 bytes, as used by ICAO Doc 9303 PACE.
+func NewAESSecureMessaging(encKey, macKey []byte) (SecureMessagingCipherSuite, error) {
+	enc, err := aes.NewCipher(encKey)
+	if err != nil {
+		return nil, err
+	}
+	mac, err := aes.NewCipher(macKey)
+	if err != nil {
+		return nil, err
+	}
+	return aesSecureMessaging{
+		enc: enc,
+		mac: mac,
+	}, nil
+}
+
+func (s aesSecureMessaging) BlockSize() int {
+	return aes.BlockSize
+}
+
+func (s aesSecureMessaging) iv(ssc []byte) []byte {
+	iv := make([]byte, aes.BlockSize)
+	s.enc.Encrypt(iv, ssc)
+	return iv
+}
+
+func (s aesSecureMessaging) Encrypt(ssc, data []byte) ([]byte, error) {
+	return utils.EncryptCBC(s.enc, s.iv(ssc), data)
+}
+
+func (s aesSecureMessaging) Decrypt(ssc, data []byte) ([]byte, error) {
+	return utils.DecryptCBC(s.enc, s.iv(ssc), data)
+}
+
+func (s aesSecureMessaging) MAC(data []byte) ([]byte, error) {
+	return utils.CMAC(s.mac, data)[:8], nil
+}
+
+type _SecureMessagingClient struct {
+	client RawClient
+	suite  SecureMessagingCipherSuite
+	ssc    []byte
+	// broken is set when the card answers with an unprotected error, which
+	// ends the session without the send sequence counter being incremented
+	broken bool
+}
+
+// NewSecureMessagingClient decorates the client so that the commands are
+// sent protected with ISO/IEC 7816-4 secure messaging, with the header
+// authenticated, and the responses are verified and unprotected. The send
+// sequence counter is incremented before each command and each response.
+//
+// The expected response length is only protected when it is not zero, in
+// which case the protected command is sent with Le 00. After an unprotected
+// error response, the commands fail with ErrSecureMessagingBroken.
+func NewSecureMessagingClient(client RawClient, suite SecureMessagingCipherSuite, ssc []byte) (RawClient, error) {
+	if len(ssc) != suite.BlockSize() {
+		return nil, fmt.Errorf("send sequence counter must have %d bytes", suite.BlockSize())
+	}
+	return &_SecureMessagingClient{
+		client: client,
+		suite:  suite,
+		ssc:    append([]byte{}, ssc...),
+	}, nil
+}
+
+func (c *_SecureMessagingClient) incrementSSC() {
+	for i := len(c.ssc) - 1; i >= 0; i-- {
+		c.ssc[i]++
+		if c.ssc[i] != 0 {
+			break
+		}
+	}
+}
+
+func (c *_SecureMessagingClient) mac(data ...[]byte) ([]byte, error) {
+	input := append([]byte{}, c.ssc...)
+	for _, d := range data {
+		input = append(input, d...)
+	}
+	return c.suite.MAC(utils.Pad80Block(input, c.suite.BlockSize()))
+}
+
+// wrap protects the command, incrementing the send sequence counter.
+func (c *_SecureMessagingClient) wrap(cmd Command) (Command, error) {
+	blockSize := c.suite.BlockSize()
+	protected := Command{
+		Class:       cmd.Class.WithSecureMessaging(),
+		Instruction: cmd.Instruction,
+		Parameters:  cmd.Parameters,
+	}
+
+	c.incrementSSC()
+	var objects bytes.Buffer
+	if len(cmd.Data) > 0 {
+		encrypted, err := c.suite.Encrypt(c.ssc, utils.Pad80Block(cmd.Data, blockSize))
+		if err != nil {
+			return protected, err
+		}
+		if cmd.Instruction&1 == 0 {
+			objects.Write(ber.Encode(0x87, append([]byte{0x01}, encrypted...)))
+		} else {
+			objects.Write(ber.Encode(0x85, encrypted))
+		}
+	}
+	if cmd.MaxReponseLength != 0 {
+		objects.Write(ber.Encode(0x97, []byte{cmd.MaxReponseLength}))
+	}
+
+	header := []byte{byte(protected.Class), byte(protected.Instruction), protected.Parameters.P1, protected.Parameters.P2}
+	mac, err := c.mac(utils.Pad80Block(header, blockSize), objects.Bytes())
+	if err != nil {
+		return protected, err
+	}
+	objects.Write(ber.Encode(0x8E, mac))
+
+	protected.Data = objects.Bytes()
+	if len(protected.Data) > 0xFF {
+		return protected, errors.New("protected command data is too long")
+	}
+	return protected, nil
+}
+
+// unwrap verifies the MAC of the response and decrypts its data,
+// incrementing the send sequence counter. Error responses without secure
+// messaging data objects are returned unchanged and break the session.
+func (c *_SecureMessagingClient) unwrap(resp Response) (Response, error) {
+	if len(resp.Data) == 0 && resp.Trailer.GetError() != nil {
+		c.broken = true
+		return resp, nil
+	}
+	tlvs, err := ber.Parse(resp.Data)
+	if err != nil {
+		return resp, fmt.Errorf("%w: %w", ErrSecureMessagingMissing, err)
+	}
+
+	var authenticated bytes.Buffer
+	var cryptogram, status, mac []byte
+	for _, t := range tlvs {
+		switch t.Tag {
+		case 0x87:
+			if len(t.Value) < 1 || t.Value[0] != 0x01 {
+				return resp, errors.New("unsupported padding content indicator")
+			}
+			cryptogram = t.Value[1:]
+		case 0x85:
+			cryptogram = t.Value
+		case 0x99:
+			status = t.Value
+		case 0x8E:
+			mac = t.Value
+			continue
+		}
+		authenticated.Write(t.Bytes())
+	}
+	if mac == nil {
+		return resp, ErrSecureMessagingMissing
+	}
+	if len(mac) < 4 || len(mac) > 8 {
+		return resp, fmt.Errorf("%w: invalid length %d", ErrInvalidResponseMAC, len(mac))
+	}
+
+	c.incrementSSC()
+	expected, err := c.mac(authenticated.Bytes())
+	if err != nil {
+		return resp, err
+	}
+	if subtle.ConstantTimeCompare(expected[:len(mac)], mac) != 1 {
+		return resp, ErrInvalidResponseMAC
+	}
+
+	result := Response{Trailer: resp.Trailer}
+	if len(status) == 2 {
+		result.Trailer = NewTrailer(status[0], status[1])
+	}
+	if cryptogram != nil {
+		data, err := c.suite.Decrypt(c.ssc, cryptogram)
+		if err != nil {
+			return result, err
+		}
+		if result.Data, err = utils.Unpad80(data); err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+func (c *_SecureMessagingClient) SendCommand(cmd Command) (Response, error) {
+	if c.broken {
+		return Response{}, ErrSecureMessagingBroken
+	}
+	protected, err := c.wrap(cmd)
+	if err != nil {
+		return Response{}, err
+	}
+	resp, err := c.client.SendCommand(protected)
+	if err != nil {
+		return resp, err
+	}
+	return c.unwrap(resp)
+}
@@ -0,0 +1,147 @@
+package apdu
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/mniak/apdu/internal/ber"
+	"github.com/mniak/apdu/internal/test"
+	"github.com/mniak/apdu/internal/utils"
+	"github.com/mniak/tlv"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	gomock "go.uber.org/mock/gomock"
+)
+
+func TestSecureMessagingClient_TripleDES(t *testing.T) {
+	// ICAO Doc 9303 Part 11, Appendix D.4
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	suite, err := NewTripleDESSecureMessaging(
+		test.MustParseHex(t, "979EC13B1CBFE9DCD01AB0FED307EAE5"),
+		test.MustParseHex(t, "F1CB1F1FB5ADF208806B89DC579DC1F8"),
+	)
+	require.NoError(t, err)
+
+	mockClient := NewMockRawClient(ctrl)
+	sm, err := NewSecureMessagingClient(mockClient, suite, test.MustParseHex(t, "887022120C06C226"))
+	require.NoError(t, err)
+
+	expectCommand := func(expected string, response string) {
+		mockClient.EXPECT().
+			SendCommand(gomock.Any()).
+			DoAndReturn(func(cmd Command) (Response, error) {
+				cmdBytes, err := cmd.Bytes(tlv.ShortLengthEncoder)
+				require.NoError(t, err)
+				test.AssertBytesEqual(t, expected, cmdBytes)
+				return ParseResponse(test.MustParseHex(t, response))
+			})
+	}
+
+	// Select EF.COM
+	expectCommand("0CA4020C158709016375432908C044F68E08BF8B92D635FF24F800", "990290008E08FA855A5D4C50A8ED9000")
+	resp, err := sm.SendCommand(Command{
+		Class:       0x00,
+		Instruction: InstructionA4_Select,
+		Parameters:  Parameters{P1: 0x02, P2: 0x0C},
+		Data:        test.MustParseHex(t, "011E"),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, Trailer(0x9000), resp.Trailer)
+	assert.Empty(t, resp.Data)
+
+	// Read the first 4 bytes of EF.COM
+	expectCommand("0CB000000D9701048E08ED6705417E96BA5500", "8709019FF0EC34F9922651990290008E08AD55CC17140B2DED9000")
+	resp, err = sm.SendCommand(Command{
+		Class:            0x00,
+		Instruction:      0xB0,
+		MaxReponseLength: 0x04,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, Trailer(0x9000), resp.Trailer)
+	test.AssertBytesEqual(t, "60145F01", resp.Data)
+}
+
+func TestSecureMessagingClient_AES(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	suite, err := NewAESSecureMessaging(
+		test.MustParseHex(t, "2B7E151628AED2A6ABF7158809CF4F3C"),
+		test.MustParseHex(t, "000102030405060708090A0B0C0D0E0F"),
+	)
+	require.NoError(t, err)
+	ssc := make([]byte, 16)
+
+	mockClient := NewMockRawClient(ctrl)
+	sm, err := NewSecureMessagingClient(mockClient, suite, ssc)
+	require.NoError(t, err)
+
+	// The card answers with the SSC incremented twice
+	responseSSC := make([]byte, 16)
+	responseSSC[15] = 2
+	encrypted, err := suite.Encrypt(responseSSC, utils.Pad80Block([]byte("response data"), 16))
+	require.NoError(t, err)
+	objects := append(ber.Encode(0x87, append([]byte{0x01}, encrypted...)), ber.Encode(0x99, []byte{0x90, 0x00})...)
+	mac, err := suite.MAC(utils.Pad80Block(append(append([]byte{}, responseSSC...), objects...), 16))
+	require.NoError(t, err)
+	response := Response{
+		Data:    append(objects, ber.Encode(0x8E, mac)...),
+		Trailer: 0x9000,
+	}
+
+	mockClient.EXPECT().
+		SendCommand(gomock.Any()).
+		DoAndReturn(func(cmd Command) (Response, error) {
+			assert.Equal(t, Class(0x0C), cmd.Class)
+			tlvs, err := ber.Parse(cmd.Data)
+			require.NoError(t, err)
+			require.Len(t, tlvs, 3)
+			assert.Equal(t, []ber.Tag{0x87, 0x97, 0x8E}, []ber.Tag{tlvs[0].Tag, tlvs[1].Tag, tlvs[2].Tag})
+
+			commandSSC := make([]byte, 16)
+			commandSSC[15] = 1
+			decrypted, err := suite.Decrypt(commandSSC, tlvs[0].Value[1:])
+			require.NoError(t, err)
+			assert.True(t, bytes.HasPrefix(decrypted, []byte("command data\x80")))
+			return response, nil
+		})
+
+	cmd := Command{
+		Class:            0x00,
+		Instruction:      0xCA,
+		Data:             []byte("command data"),
+		MaxReponseLength: 0x20,
+	}
+	resp, err := sm.SendCommand(cmd)
+	require.NoError(t, err)
+	assert.Equal(t, "response data", string(resp.Data))
+
+	t.Run("Replayed response", func(t *testing.T) {
+		mockClient.EXPECT().SendCommand(gomock.Any()).Return(response, nil)
+		_, err := sm.SendCommand(cmd)
+		assert.ErrorIs(t, err, ErrInvalidResponseMAC)
+	})
+	t.Run("Unprotected success", func(t *testing.T) {
+		mockClient.EXPECT().SendCommand(gomock.Any()).Return(Response{Data: []byte{0x01, 0x00}, Trailer: 0x9000}, nil)
+		_, err := sm.SendCommand(cmd)
+		assert.ErrorIs(t, err, ErrSecureMessagingMissing)
+	})
+	t.Run("Unprotected error", func(t *testing.T) {
+		mockClient.EXPECT().SendCommand(gomock.Any()).Return(Response{Trailer: 0x6982}, nil)
+		resp, err := sm.SendCommand(cmd)
+		require.NoError(t, err)
+		assert.Equal(t, Trailer(0x6982), resp.Trailer)
+
+		_, err = sm.SendCommand(cmd)
+		assert.ErrorIs(t, err, ErrSecureMessagingBroken)
+	})
+}
+
+func TestClass_WithSecureMessaging(t *testing.T) {
+	assert.Equal(t, Class(0x0C), Class(0x00).WithSecureMessaging())
+	assert.Equal(t, Class(0x84), Class(0x80).WithSecureMessaging())
+	assert.Equal(t, Class(0x60), Class(0x40).WithSecureMessaging())
+	assert.Equal(t, SMAccordingToSection6_Authenticated, Class(0x00).WithSecureMessaging().SecureMessagingIndication())
+}
@@ -44,6 +44,70 @@ func (c Command) Bytes(enc tlv.LengthEncoder) ([]byte, error) {
 	return b.Bytes(), nil
 }
 
+// ShortBytes encodes the command with short lengths, omitting Lc when there
+// is no data and Le when it is zero.
+func (c Command) ShortBytes() []byte {
+	result := []byte{byte(c.Class), byte(c.Instruction), c.Parameters.P1, c.Parameters.P2}
+	if len(c.Data) > 0 {
+		result = append(result, byte(len(c.Data)))
+		result = append(result, c.Data...)
+	}
+	if c.MaxReponseLength != 0 {
+		result = append(result, c.MaxReponseLength)
+	}
+	return result
+}
+
+// ExtendedBytes encodes the command with extended lengths: Lc with three
+// bytes, and Le with two bytes after the data or three bytes otherwise.
+func (c Command) ExtendedBytes() ([]byte, error) {
+	if len(c.Data) > 0xFFFF {
+		return nil, fmt.Errorf("command data is too long for extended length: %d bytes", len(c.Data))
+	}
+	result := []byte{byte(c.Class), byte(c.Instruction), c.Parameters.P1, c.Parameters.P2}
+	if len(c.Data) > 0 {
+		result = append(result, 0x00, byte(len(c.Data)>>8), byte(len(c.Data)))
+		result = append(result, c.Data...)
+	} else {
+		result = append(result, 0x00)
+	}
+	return append(result, 0x00, c.MaxReponseLength), nil
+}
+
+// ParseCommand decodes a short length command APDU, as found in issuer
+// scripts.
+func ParseCommand(data []byte) (Command, error) {
+	if len(data) < 4 {
+		return Command{}, fmt.Errorf("command APDU is too short: %d bytes", len(data))
+	}
+	cmd := Command{
+		Class:       Class(data[0]),
+		Instruction: Instruction(data[1]),
+		Parameters: Parameters{
+			P1: data[2],
+			P2: data[3],
+		},
+	}
+	body := data[4:]
+	switch {
+	case len(body) == 0:
+		// Case 1
+	case len(body) == 1:
+		// Case 2
+		cmd.MaxReponseLength = body[0]
+	case len(body) == 1+int(body[0]) && body[0] > 0:
+		// Case 3
+		cmd.Data = body[1:]
+	case len(body) == 2+int(body[0]) && body[0] > 0:
+		// Case 4
+		cmd.Data = body[1 : len(body)-1]
+		cmd.MaxReponseLength = body[len(body)-1]
+	default:
+		return Command{}, fmt.Errorf("command APDU body length %d does not match Lc", len(body))
+	}
+	return cmd, nil
+}
+
 func (c Command) String() string {
 	return fmt.Sprintf("CLA=%02X INS=%02X P1=%02X P2=%02X DATA=[%2X] Le=%02X", c.Class, c.Instruction, c.Parameters.P1, c.Parameters.P2, c.Data, c.MaxReponseLength)
 }
@@ -0,0 +1,294 @@
+package contactless
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/mniak/apdu"
+	"github.com/mniak/apdu/internal/ber"
+	"github.com/mniak/apdu/oda"
+)
+
+var ErrMissingMandatoryData = errors.New("card data is missing mandatory data objects")
+
+// Kernel2Config is the configuration of the kernel 2 (EMV Book C-2).
+type Kernel2Config struct {
+	// TerminalData are the terminal data objects available for the DOLs,
+	// like the Terminal Country Code (9F1A) and the Terminal Capabilities
+	// (9F33). The transaction data is added to them.
+	TerminalData map[apdu.Tag][]byte
+
+	EMVModeSupported       bool
+	MagStripeModeSupported bool
+
+	// Limits in minor units. A zero ContactlessTransactionLimit means there
+	// is no limit.
+	ContactlessTransactionLimit uint64
+	FloorLimit                  uint64
+	CVMRequiredLimit            uint64
+	// CVMAboveLimit is the CVM required for amounts above the CVM required
+	// limit when the card does not support on device cardholder verification.
+	CVMAboveLimit CVM
+	// OnlineOnly requests an ARQC regardless of the floor limit.
+	OnlineOnly bool
+
+	// CAPublicKeys are used for CDA. Without them, or when the card does not
+	// support CDA, the transactions cannot be approved offline.
+	CAPublicKeys *oda.CAPublicKeyStore
+}
+
+// Kernel2 processes contactless transactions in the style of EMV Book C-2,
+// in either EMV mode or mag-stripe mode.
+type Kernel2 struct {
+	client apdu.LowLevelCommands
+	config Kernel2Config
+}
+
+func NewKernel2(client apdu.LowLevelCommands, config Kernel2Config) Kernel2 {
+	return Kernel2{
+		client: client,
+		config: config,
+	}
+}
+
+var (
+	kernel2EMVDataRecord = []apdu.Tag{
+		0x9F02, 0x9F03, 0x9F26, 0x82, 0x5F34, 0x9F36, 0x9F27, 0x9F34, 0x84, 0x9F1E,
+		0x9F10, 0x9F33, 0x9F35, 0x95, 0x9F53, 0x5F2A, 0x9A, 0x9C, 0x9F37, 0x9F1A,
+		0x57, 0x5A, 0x5F24, 0x9F6E,
+	}
+	kernel2MagStripeDataRecord = []apdu.Tag{
+		0x84, 0x56, 0x9F6B, 0x9F60, 0x9F61, 0x9F36, 0x9F6A, 0x9F02, 0x5F2A, 0x9A, 0x9C,
+	}
+)
+
+// defaultUDOL is used when the card does not provide the UDOL (9F69).
+var defaultUDOL = apdu.DataObjectList{{Tag: 0x9F6A, Length: 4}}
+
+// Process performs the final selection of the candidate and the transaction.
+// Card errors finish the transaction with an End Application outcome, which
+// is returned along with the error.
+func (k Kernel2) Process(candidate apdu.Candidate, transaction Transaction) (Outcome, error) {
+	if k.config.ContactlessTransactionLimit > 0 && transaction.Amount >= k.config.ContactlessTransactionLimit {
+		return tryAnotherInterface(), nil
+	}
+
+	terminalData, err := transaction.terminalData(k.config.TerminalData)
+	if err != nil {
+		return endApplication(MessageProcessingError), err
+	}
+
+	cd := apdu.NewCardData()
+	fci, err := k.client.SelectByName(candidate.SelectName)
+	if err != nil {
+		return Outcome{Type: OutcomeSelectNext, CVM: CVMNotApplicable}, err
+	}
+	if err := cd.AddTLV(fci); err != nil {
+		return Outcome{Type: OutcomeSelectNext, CVM: CVMNotApplicable}, err
+	}
+
+	pdol, err := cd.PDOL()
+	if err != nil {
+		return Outcome{Type: OutcomeSelectNext, CVM: CVMNotApplicable}, err
+	}
+	pdolData := cd.BuildDOL(pdol, terminalData)
+	gpo, err := k.client.GetProcessingOptions(ber.Encode(0x83, pdolData))
+	if errors.Is(err, apdu.ErrConditionsOfUseNotSatisfied) {
+		return Outcome{Type: OutcomeSelectNext, CVM: CVMNotApplicable}, err
+	}
+	if err != nil {
+		return endApplication(MessageInsertSwipeOrTryAnother), err
+	}
+	if err := cd.AddGetProcessingOptionsResponse(gpo); err != nil {
+		return endApplication(MessageInsertSwipeOrTryAnother), err
+	}
+
+	aip := cd.AIP()
+	emvMode := k.config.EMVModeSupported && aip.EMVModeSupported()
+	if !emvMode && !k.config.MagStripeModeSupported {
+		return endApplication(MessageInsertSwipeOrTryAnother), errors.New("card does not support a mode supported by the terminal")
+	}
+
+	var staticData oda.StaticData
+	if err := readRecords(k.client, cd, &staticData); err != nil {
+		return endApplication(MessageInsertSwipeOrTryAnother), err
+	}
+
+	var outcome Outcome
+	if emvMode {
+		outcome, err = k.processEMVMode(candidate.SelectName, cd, &staticData, pdolData, terminalData, transaction)
+	} else {
+		outcome, err = k.processMagStripeMode(cd, terminalData, transaction)
+	}
+	outcome.CardData = cd
+	return outcome, err
+}
+
+// processEMVMode sends GENERATE AC with the CDA signature requested when
+// the ICC public key can be recovered. Only a TC whose signature is verified
+// is approved offline, otherwise an ARQC is requested.
+func (k Kernel2) processEMVMode(aid []byte, cd *apdu.CardData, staticData *oda.StaticData, pdolData []byte, terminalData map[apdu.Tag][]byte, transaction Transaction) (Outcome, error) {
+	for _, tag := range []apdu.Tag{0x5A, 0x5F24, 0x8C} {
+		if !cd.Has(tag) {
+			return endApplication(MessageInsertSwipeOrTryAnother), fmt.Errorf("%w: %s", ErrMissingMandatoryData, tag)
+		}
+	}
+	cdol1, err := cd.CDOL1()
+	if err != nil {
+		return endApplication(MessageInsertSwipeOrTryAnother), err
+	}
+
+	var tvr apdu.TVR
+	copy(tvr[:], terminalData[0x95])
+	var icc oda.PublicKey
+	cda := cd.AIP().CDASupported()
+	if cda {
+		icc, err = recoverICCPublicKey(k.config.CAPublicKeys, aid, cd, staticData, transaction.now())
+		if err != nil {
+			tvr.Set(apdu.TVRCDAFailed)
+			cda = false
+		}
+	} else {
+		tvr.Set(apdu.TVROfflineDataAuthenticationNotPerformed)
+	}
+	terminalData[0x95] = tvr[:]
+
+	cryptogramType := apdu.TC
+	if !cda || k.config.OnlineOnly || transaction.Amount > k.config.FloorLimit {
+		cryptogramType = apdu.ARQC
+	}
+	transactionData := cd.BuildDOL(cdol1, terminalData)
+	var resp []byte
+	if cda {
+		resp, err = k.client.GenerateACWithCDA(cryptogramType, transactionData)
+	} else {
+		resp, err = k.client.GenerateAC(cryptogramType, transactionData)
+	}
+	if err != nil {
+		return endApplication(MessageInsertSwipeOrTryAnother), err
+	}
+	genAC, err := parseGenerateACResponse(resp)
+	if err != nil {
+		return endApplication(MessageInsertSwipeOrTryAnother), err
+	}
+	cid := apdu.CryptogramInformationData(genAC[0x9F27][0])
+
+	offlineAuthenticated := false
+	if cda && cid.CryptogramType() != apdu.AAC {
+		_, err := oda.VerifyCDA(icc, resp, oda.CDAInput{
+			UnpredictableNumber: terminalData[0x9F37],
+			PDOLData:            pdolData,
+			CDOL1Data:           transactionData,
+		})
+		if err != nil {
+			tvr.Set(apdu.TVRCDAFailed)
+			terminalData[0x95] = tvr[:]
+		}
+		offlineAuthenticated = err == nil
+	}
+
+	outcome := Outcome{
+		CVM:        k.cvm(cd.AIP(), transaction),
+		DataRecord: dataRecord(kernel2EMVDataRecord, genAC, terminalData, cd.Values()),
+	}
+	outcome.Receipt = outcome.CVM == CVMObtainSignature
+	switch {
+	case cid.CryptogramType() == apdu.TC && offlineAuthenticated:
+		outcome.Type = OutcomeApproved
+		outcome.UIRequest = &UserInterfaceRequest{MessageID: MessageApproved, Status: StatusCardReadSuccessfully}
+		if outcome.CVM == CVMObtainSignature {
+			outcome.UIRequest.MessageID = MessageApprovedPleaseSign
+		}
+	case cid.CryptogramType() == apdu.ARQC:
+		outcome.Type = OutcomeOnlineRequest
+		outcome.UIRequest = &UserInterfaceRequest{MessageID: MessageAuthorisingPleaseWait, Status: StatusCardReadSuccessfully}
+	default:
+		outcome.Type = OutcomeDeclined
+		outcome.CVM = CVMNotApplicable
+		outcome.Receipt = false
+		outcome.UIRequest = &UserInterfaceRequest{MessageID: MessageNotAuthorised, Status: StatusCardReadSuccessfully}
+	}
+	return outcome, nil
+}
+
+func (k Kernel2) cvm(aip apdu.AIP, transaction Transaction) CVM {
+	if transaction.Amount <= k.config.CVMRequiredLimit {
+		return CVMNoCVM
+	}
+	if aip.OnDeviceCardholderVerificationSupported() {
+		return CVMConfirmationCodeVerified
+	}
+	return k.config.CVMAboveLimit
+}
+
+// parseGenerateACResponse returns the data objects of the response in
+// either format.
+func parseGenerateACResponse(resp []byte) (map[apdu.Tag][]byte, error) {
+	t, _, err := ber.ParseOne(resp)
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[apdu.Tag][]byte)
+	switch t.Tag {
+	case 0x80:
+		if len(t.Value) < 11 {
+			return nil, errors.New("GENERATE AC response format 1 is too short")
+		}
+		result[0x9F27] = t.Value[:1]
+		result[0x9F36] = t.Value[1:3]
+		result[0x9F26] = t.Value[3:11]
+		if len(t.Value) > 11 {
+			result[0x9F10] = t.Value[11:]
+		}
+	case 0x77:
+		cd := apdu.NewCardData()
+		if err := cd.AddTLV(resp); err != nil {
+			return nil, err
+		}
+		result = cd.Values()
+	default:
+		return nil, fmt.Errorf("unexpected GENERATE AC response template %s", t.Tag)
+	}
+	if len(result[0x9F27]) != 1 || len(result[0x9F36]) == 0 {
+		return nil, fmt.Errorf("%w: GENERATE AC response", ErrMissingMandatoryData)
+	}
+	return result, nil
+}
+
+func (k Kernel2) processMagStripeMode(cd *apdu.CardData, terminalData map[apdu.Tag][]byte, transaction Transaction) (Outcome, error) {
+	if !cd.Has(0x9F6B) {
+		return endApplication(MessageInsertSwipeOrTryAnother), fmt.Errorf("%w: %s", ErrMissingMandatoryData, apdu.Tag(0x9F6B))
+	}
+	udol := defaultUDOL
+	if value, found := cd.Get(0x9F69); found {
+		var err error
+		if udol, err = apdu.ParseDOL(value); err != nil {
+			return endApplication(MessageInsertSwipeOrTryAnother), err
+		}
+	}
+
+	var un uint64
+	for _, b := range terminalData[0x9F37] {
+		un = un<<8 | uint64(b)
+	}
+	terminalData[0x9F6A] = numeric(un%100_000_000, 4)
+
+	resp, err := k.client.ComputeCryptographicChecksum(cd.BuildDOL(udol, terminalData))
+	if err != nil {
+		return endApplication(MessageInsertSwipeOrTryAnother), err
+	}
+	checksum := apdu.NewCardData()
+	if err := checksum.AddTLV(resp); err != nil {
+		return endApplication(MessageInsertSwipeOrTryAnother), err
+	}
+	if !checksum.Has(0x9F61) || !checksum.Has(0x9F36) {
+		return endApplication(MessageInsertSwipeOrTryAnother), fmt.Errorf("%w: COMPUTE CRYPTOGRAPHIC CHECKSUM response", ErrMissingMandatoryData)
+	}
+
+	return Outcome{
+		Type:       OutcomeOnlineRequest,
+		CVM:        k.cvm(cd.AIP(), transaction),
+		DataRecord: dataRecord(kernel2MagStripeDataRecord, checksum.Values(), terminalData, cd.Values()),
+		UIRequest:  &UserInterfaceRequest{MessageID: MessageAuthorisingPleaseWait, Status: StatusCardReadSuccessfully},
+	}, nil
+}
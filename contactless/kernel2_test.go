@@ -0,0 +1,259 @@
+package contactless
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"testing"
+
+	"github.com/mniak/apdu"
+	"github.com/mniak/apdu/drivers/simulator"
+	"github.com/mniak/apdu/internal/ber"
+	"github.com/mniak/apdu/internal/test"
+	"github.com/mniak/apdu/oda"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type simulatedCard interface {
+	apdu.Driver
+	Commands() []apdu.Command
+}
+
+// newKernel2Card simulates a card whose CDA signature is computed over the
+// GENERATE AC response. When tamperSDAD is set, it signs a different
+// Unpredictable Number.
+func newKernel2Card(t *testing.T, gpo apdu.Response, tamperSDAD bool) simulatedCard {
+	keys := generateTestKeys(t)
+
+	issuerCertificate, issuerRemainder := test.EMVCertificate(t, keys.ca, keys.issuer,
+		test.MustParseHex(t, "02 541333FF 1249 000001 01 01"), 36)
+	record1 := test.MustParseHex(t, "7029 5A085413330089020011 5F2403251231 8C089F02069A039F3704 9F6B0C5413330089020011D2512201")
+	iccCertificate, iccRemainder := test.EMVCertificate(t, keys.issuer, keys.icc,
+		test.MustParseHex(t, "04 5413330089020011FFFF 1249 000002 01 01"), 42, record1[2:])
+	record2 := ber.Encode(0x70, bytes.Join([][]byte{
+		ber.Encode(0x8F, []byte{0xF1}),
+		ber.Encode(0x90, issuerCertificate),
+		ber.Encode(0x92, issuerRemainder),
+		ber.Encode(0x9F32, test.RSAExponent(keys.issuer)),
+		ber.Encode(0x9F46, iccCertificate),
+		ber.Encode(0x9F47, test.RSAExponent(keys.icc)),
+		ber.Encode(0x9F48, iccRemainder),
+	}, nil))
+
+	return simulator.New().
+		AddApplication(
+			test.MustParseHex(t, "A0000000041010"),
+			test.MustParseHex(t, "6F1D 8407A0000000041010 A512 500A4D415354455243415244 9F38039F3704"),
+		).
+		AddRecord(1, 1, record1).
+		AddRecord(1, 2, record2).
+		Respond(0x80, apdu.EMVInstructionA8_GetProcessingOptions, gpo).
+		Handle(0x80, apdu.EMVInstructionAE_GenerateAC, func(cmd apdu.Command) apdu.Response {
+			cid := cmd.Parameters.P1 & 0xC0
+			data := append([]byte{0x9F, 0x27, 0x01, cid},
+				test.MustParseHex(t, "9F36020001 9F26081122334455667788 9F10070110A000000000")...)
+			if cmd.Parameters.P1&0x10 != 0 {
+				// CDOL1: Amount (6) || Transaction Date (3) || UN (4), where the
+				// UN is also the PDOL data
+				un := cmd.Data[9:13]
+				h := sha1.New()
+				h.Write(un)
+				h.Write(cmd.Data)
+				h.Write(data)
+				if tamperSDAD {
+					un = []byte{0, 0, 0, 0}
+				}
+				dynamicData := bytes.Join([][]byte{{0x02, 0x12, 0x34, cid}, test.MustParseHex(t, "1122334455667788"), h.Sum(nil)}, nil)
+				sdad := test.SignEMV(t, keys.icc, append([]byte{0x05, 0x01, byte(len(dynamicData))}, dynamicData...), un)
+				data = append(data, ber.Encode(0x9F4B, sdad)...)
+			}
+			return apdu.Response{
+				Data:    ber.Encode(0x77, data),
+				Trailer: 0x9000,
+			}
+		}).
+		Respond(0x80, apdu.EMVInstruction2A_ComputeCryptographicChecksum, apdu.Response{
+			Data:    test.MustParseHex(t, "770F 9F61021234 9F60025678 9F36020002"),
+			Trailer: 0x9000,
+		})
+}
+
+func gpoResponse(t *testing.T, aip string) apdu.Response {
+	return apdu.Response{
+		Data:    test.MustParseHex(t, "770A 8202"+aip+" 940408010201"),
+		Trailer: 0x9000,
+	}
+}
+
+func newKernel2Candidate(t *testing.T) apdu.Candidate {
+	aid := test.MustParseHex(t, "A0000000041010")
+	return apdu.Candidate{
+		DirectoryEntry: apdu.DirectoryEntry{ADFName: aid},
+		Combination:    apdu.Combination{AID: aid, KernelID: []byte{apdu.Kernel2}},
+		SelectName:     aid,
+	}
+}
+
+func kernel2Config(t *testing.T) Kernel2Config {
+	keys := generateTestKeys(t)
+	store := oda.NewCAPublicKeyStore()
+	require.NoError(t, store.Add(oda.CAPublicKey{
+		RID:   test.MustParseHex(t, "A000000004"),
+		Index: 0xF1,
+		PublicKey: oda.PublicKey{
+			Modulus:  keys.ca.N.Bytes(),
+			Exponent: test.RSAExponent(keys.ca),
+		},
+		HashAlgorithm: 0x01,
+		KeyAlgorithm:  0x01,
+	}))
+	return Kernel2Config{
+		TerminalData: map[apdu.Tag][]byte{
+			0x9F1A: {0x00, 0x76},
+			0x5F2A: {0x09, 0x86},
+		},
+		EMVModeSupported:            true,
+		MagStripeModeSupported:      true,
+		ContactlessTransactionLimit: 100000,
+		FloorLimit:                  1000,
+		CVMRequiredLimit:            10000,
+		CVMAboveLimit:               CVMOnlinePIN,
+		CAPublicKeys:                store,
+	}
+}
+
+func TestKernel2_EMVMode(t *testing.T) {
+	testCases := []struct {
+		name       string
+		amount     uint64
+		outcome    OutcomeType
+		cvm        CVM
+		cryptogram apdu.ApplicationCryptogramType
+		message    MessageID
+	}{
+		{"online request", 5000, OutcomeOnlineRequest, CVMNoCVM, apdu.ARQC, MessageAuthorisingPleaseWait},
+		{"approved offline", 500, OutcomeApproved, CVMNoCVM, apdu.TC, MessageApproved},
+		{"online request with CVM", 50000, OutcomeOnlineRequest, CVMOnlinePIN, apdu.ARQC, MessageAuthorisingPleaseWait},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			card := newKernel2Card(t, gpoResponse(t, "1980"), false)
+			client := apdu.NewClient(card)
+			kernel := NewKernel2(client.LowLevelCommands, kernel2Config(t))
+
+			outcome, err := kernel.Process(newKernel2Candidate(t), Transaction{
+				Amount:              tc.amount,
+				UnpredictableNumber: test.MustParseHex(t, "CAFEBABE"),
+			})
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.outcome, outcome.Type)
+			assert.Equal(t, tc.cvm, outcome.CVM)
+			require.NotNil(t, outcome.UIRequest)
+			assert.Equal(t, tc.message, outcome.UIRequest.MessageID)
+			test.AssertBytesEqual(t, "1122334455667788", outcome.DataRecord[0x9F26])
+			test.AssertBytesEqual(t, "5413330089020011", outcome.DataRecord[0x5A])
+			test.AssertBytesEqual(t, "CAFEBABE", outcome.DataRecord[0x9F37])
+			assert.Equal(t, []byte{byte(tc.cryptogram) << 6}, outcome.DataRecord[0x9F27])
+
+			test.AssertBytesEqual(t, "0000000000", outcome.DataRecord[0x95])
+
+			commands := card.Commands()
+			require.Len(t, commands, 5)
+			test.AssertBytesEqual(t, "8304CAFEBABE", commands[1].Data)
+			genAC := commands[4]
+			assert.Len(t, genAC.Data, 13)
+			assert.Equal(t, apdu.EMVInstructionAE_GenerateAC, genAC.Instruction)
+			assert.Equal(t, byte(tc.cryptogram)<<6|0x10, genAC.Parameters.P1, "CDA should be requested")
+		})
+	}
+}
+
+func TestKernel2_CDA(t *testing.T) {
+	transaction := Transaction{
+		Amount:              500,
+		UnpredictableNumber: test.MustParseHex(t, "CAFEBABE"),
+	}
+
+	t.Run("Tampered SDAD", func(t *testing.T) {
+		card := newKernel2Card(t, gpoResponse(t, "1980"), true)
+		client := apdu.NewClient(card)
+		kernel := NewKernel2(client.LowLevelCommands, kernel2Config(t))
+
+		outcome, err := kernel.Process(newKernel2Candidate(t), transaction)
+		require.NoError(t, err)
+
+		assert.Equal(t, OutcomeDeclined, outcome.Type)
+		assert.Equal(t, []byte{byte(apdu.TC) << 6}, outcome.DataRecord[0x9F27])
+		var tvr apdu.TVR
+		copy(tvr[:], outcome.DataRecord[0x95])
+		assert.True(t, tvr.Has(apdu.TVRCDAFailed))
+	})
+	t.Run("Without CA public keys", func(t *testing.T) {
+		card := newKernel2Card(t, gpoResponse(t, "1980"), false)
+		client := apdu.NewClient(card)
+		config := kernel2Config(t)
+		config.CAPublicKeys = nil
+		kernel := NewKernel2(client.LowLevelCommands, config)
+
+		outcome, err := kernel.Process(newKernel2Candidate(t), transaction)
+		require.NoError(t, err)
+
+		assert.Equal(t, OutcomeOnlineRequest, outcome.Type)
+		var tvr apdu.TVR
+		copy(tvr[:], outcome.DataRecord[0x95])
+		assert.True(t, tvr.Has(apdu.TVRCDAFailed))
+		genAC := card.Commands()[4]
+		assert.Equal(t, byte(apdu.ARQC)<<6, genAC.Parameters.P1, "CDA should not be requested")
+	})
+}
+
+func TestKernel2_MagStripeMode(t *testing.T) {
+	card := newKernel2Card(t, gpoResponse(t, "0000"), false)
+	client := apdu.NewClient(card)
+	kernel := NewKernel2(client.LowLevelCommands, kernel2Config(t))
+
+	outcome, err := kernel.Process(newKernel2Candidate(t), Transaction{
+		Amount:              50000,
+		UnpredictableNumber: test.MustParseHex(t, "CAFEBABE"),
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, OutcomeOnlineRequest, outcome.Type)
+	assert.Equal(t, CVMOnlinePIN, outcome.CVM)
+	test.AssertBytesEqual(t, "1234", outcome.DataRecord[0x9F61])
+	test.AssertBytesEqual(t, "5678", outcome.DataRecord[0x9F60])
+	test.AssertBytesEqual(t, "0002", outcome.DataRecord[0x9F36])
+	test.AssertBytesEqual(t, "5413330089020011D2512201", outcome.DataRecord[0x9F6B])
+
+	commands := card.Commands()
+	require.Len(t, commands, 5)
+	ccc := commands[4]
+	assert.Equal(t, apdu.EMVInstruction2A_ComputeCryptographicChecksum, ccc.Instruction)
+	// 0xCAFEBABE = 3405691582, whose last 8 digits are used
+	test.AssertBytesEqual(t, "05691582", ccc.Data)
+}
+
+func TestKernel2_AboveContactlessLimit(t *testing.T) {
+	card := newKernel2Card(t, gpoResponse(t, "1980"), false)
+	client := apdu.NewClient(card)
+	kernel := NewKernel2(client.LowLevelCommands, kernel2Config(t))
+
+	outcome, err := kernel.Process(newKernel2Candidate(t), Transaction{Amount: 100000})
+	require.NoError(t, err)
+
+	assert.Equal(t, OutcomeTryAnotherInterface, outcome.Type)
+	assert.Empty(t, card.Commands())
+}
+
+func TestKernel2_ConditionsOfUseNotSatisfied(t *testing.T) {
+	card := newKernel2Card(t, apdu.Response{Trailer: apdu.Trailer(apdu.ErrConditionsOfUseNotSatisfied)}, false)
+	client := apdu.NewClient(card)
+	kernel := NewKernel2(client.LowLevelCommands, kernel2Config(t))
+
+	outcome, err := kernel.Process(newKernel2Candidate(t), Transaction{Amount: 500})
+	require.ErrorIs(t, err, apdu.ErrConditionsOfUseNotSatisfied)
+
+	assert.Equal(t, OutcomeSelectNext, outcome.Type)
+	assert.Len(t, card.Commands(), 2)
+}
@@ -0,0 +1,291 @@
+package contactless
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mniak/apdu"
+	"github.com/mniak/apdu/internal/ber"
+	"github.com/mniak/apdu/oda"
+)
+
+// Kernel3Config is the configuration of the kernel 3 (EMV Book C-3).
+type Kernel3Config struct {
+	// TerminalData are the terminal data objects available for the PDOL,
+	// like the Terminal Country Code (9F1A). The transaction data and the
+	// Terminal Transaction Qualifiers are added to them.
+	TerminalData map[apdu.Tag][]byte
+
+	// Limits in minor units. A zero ContactlessTransactionLimit means there
+	// is no limit.
+	ContactlessTransactionLimit uint64
+	FloorLimit                  uint64
+	CVMRequiredLimit            uint64
+
+	OfflineOnly                bool
+	ContactChipSupported       bool
+	OnlinePINSupported         bool
+	SignatureSupported         bool
+	ConsumerDeviceCVMSupported bool
+	IssuerUpdateSupported      bool
+
+	// CAPublicKeys are used for fDDA. Without them the transactions cannot
+	// be approved offline.
+	CAPublicKeys *oda.CAPublicKeyStore
+}
+
+// Kernel3 processes contactless transactions in the style of EMV Book C-3
+// (qVSDC), where the card returns the cryptogram in the GET PROCESSING
+// OPTIONS response.
+type Kernel3 struct {
+	client apdu.LowLevelCommands
+	config Kernel3Config
+}
+
+func NewKernel3(client apdu.LowLevelCommands, config Kernel3Config) Kernel3 {
+	return Kernel3{
+		client: client,
+		config: config,
+	}
+}
+
+var kernel3DataRecord = []apdu.Tag{
+	0x9F02, 0x9F03, 0x9F26, 0x5F34, 0x82, 0x9F36, 0x9F27, 0x9F10, 0x9F1A, 0x5F2A,
+	0x9A, 0x9C, 0x9F37, 0x57, 0x5A, 0x5F24, 0x84, 0x9F66, 0x9F6C, 0x9F6E, 0x9F7C,
+	0x9F5D,
+}
+
+// TerminalTransactionQualifiers returns the TTQ sent to the card for the
+// transaction.
+func (k Kernel3) TerminalTransactionQualifiers(transaction Transaction) apdu.TerminalTransactionQualifiers {
+	var ttq apdu.TerminalTransactionQualifiers
+	ttq.Set(apdu.TTQEMVModeSupported)
+	flags := []struct {
+		enabled bool
+		bit     apdu.TTQBit
+	}{
+		{k.config.ContactChipSupported, apdu.TTQEMVContactChipSupported},
+		{k.config.OfflineOnly, apdu.TTQOfflineOnlyReader},
+		{k.config.OnlinePINSupported, apdu.TTQOnlinePINSupported},
+		{k.config.SignatureSupported, apdu.TTQSignatureSupported},
+		{!k.config.OfflineOnly && transaction.Amount > k.config.FloorLimit, apdu.TTQOnlineCryptogramRequired},
+		{transaction.Amount > k.config.CVMRequiredLimit, apdu.TTQCVMRequired},
+		{k.config.IssuerUpdateSupported, apdu.TTQIssuerUpdateProcessingSupported},
+		{k.config.ConsumerDeviceCVMSupported, apdu.TTQConsumerDeviceCVMSupported},
+	}
+	for _, f := range flags {
+		if f.enabled {
+			ttq.Set(f.bit)
+		}
+	}
+	return ttq
+}
+
+// Process performs the final selection of the candidate and the transaction.
+// Card errors finish the transaction with an End Application outcome, which
+// is returned along with the error.
+func (k Kernel3) Process(candidate apdu.Candidate, transaction Transaction) (Outcome, error) {
+	if k.config.ContactlessTransactionLimit > 0 && transaction.Amount >= k.config.ContactlessTransactionLimit {
+		return tryAnotherInterface(), nil
+	}
+
+	terminalData, err := transaction.terminalData(k.config.TerminalData)
+	if err != nil {
+		return endApplication(MessageProcessingError), err
+	}
+	ttq := k.TerminalTransactionQualifiers(transaction)
+	terminalData[0x9F66] = ttq[:]
+
+	cd := apdu.NewCardData()
+	fci, err := k.client.SelectByName(candidate.SelectName)
+	if err != nil {
+		return Outcome{Type: OutcomeSelectNext, CVM: CVMNotApplicable}, err
+	}
+	if err := cd.AddTLV(fci); err != nil {
+		return Outcome{Type: OutcomeSelectNext, CVM: CVMNotApplicable}, err
+	}
+
+	pdol, err := cd.PDOL()
+	if err != nil {
+		return Outcome{Type: OutcomeSelectNext, CVM: CVMNotApplicable}, err
+	}
+	gpo, err := k.client.GetProcessingOptions(ber.Encode(0x83, cd.BuildDOL(pdol, terminalData)))
+	if errors.Is(err, apdu.ErrConditionsOfUseNotSatisfied) {
+		return Outcome{Type: OutcomeSelectNext, CVM: CVMNotApplicable}, err
+	}
+	if err != nil {
+		return endApplication(MessageInsertSwipeOrTryAnother), err
+	}
+	if err := cd.AddGetProcessingOptionsResponse(gpo); err != nil {
+		return endApplication(MessageInsertSwipeOrTryAnother), err
+	}
+
+	var staticData oda.StaticData
+	if err := readRecords(k.client, cd, &staticData); err != nil {
+		return endApplication(MessageInsertSwipeOrTryAnother), err
+	}
+	for _, tag := range []apdu.Tag{0x82, 0x9F36, 0x9F26, 0x9F10, 0x57} {
+		if !cd.Has(tag) {
+			return endApplication(MessageInsertSwipeOrTryAnother), fmt.Errorf("%w: %s", ErrMissingMandatoryData, tag)
+		}
+	}
+	cryptogramType, err := qVSDCCryptogramType(cd)
+	if err != nil {
+		return endApplication(MessageInsertSwipeOrTryAnother), err
+	}
+
+	value, _ := cd.Get(0x9F6C)
+	ctq := apdu.ParseCardTransactionQualifiers(value)
+	if k.config.ContactChipSupported &&
+		(transaction.Type == 0x01 && ctq.Has(apdu.CTQSwitchInterfaceForCash) ||
+			transaction.Type == 0x09 && ctq.Has(apdu.CTQSwitchInterfaceForCashback)) {
+		return tryAnotherInterface(), nil
+	}
+
+	outcome := Outcome{
+		DataRecord: dataRecord(kernel3DataRecord, terminalData, cd.Values()),
+		CardData:   cd,
+	}
+	var decision OutcomeType
+	switch cryptogramType {
+	case apdu.TC:
+		decision = k.offlineDecision(candidate.SelectName, cd, &staticData, terminalData, ctq, transaction)
+	case apdu.ARQC:
+		decision = OutcomeOnlineRequest
+		if k.config.OfflineOnly {
+			decision = OutcomeDeclined
+		}
+	default:
+		decision = OutcomeDeclined
+	}
+	if decision == OutcomeTryAnotherInterface {
+		return tryAnotherInterface(), nil
+	}
+	if decision != OutcomeDeclined {
+		cvm, ok := k.cvm(ttq, ctq, decision == OutcomeOnlineRequest)
+		if ok {
+			outcome.CVM = cvm
+		} else {
+			decision = OutcomeDeclined
+		}
+	}
+
+	outcome.Type = decision
+	switch decision {
+	case OutcomeApproved:
+		outcome.UIRequest = &UserInterfaceRequest{MessageID: MessageApproved, Status: StatusCardReadSuccessfully}
+		if outcome.CVM == CVMObtainSignature {
+			outcome.UIRequest.MessageID = MessageApprovedPleaseSign
+		}
+		if balance, found := cd.Get(0x9F5D); found {
+			outcome.UIRequest.Value = balance
+			outcome.UIRequest.CurrencyCode = terminalData[0x5F2A]
+		}
+	case OutcomeOnlineRequest:
+		outcome.UIRequest = &UserInterfaceRequest{MessageID: MessageAuthorisingPleaseWait, Status: StatusCardReadSuccessfully}
+	default:
+		outcome.CVM = CVMNotApplicable
+		outcome.UIRequest = &UserInterfaceRequest{MessageID: MessageNotAuthorised, Status: StatusCardReadSuccessfully}
+	}
+	outcome.Receipt = outcome.CVM == CVMObtainSignature
+	return outcome, nil
+}
+
+// qVSDCCryptogramType reads the cryptogram type from the CID or, when the
+// card does not return it, from the Card Verification Results in the Issuer
+// Application Data.
+func qVSDCCryptogramType(cd *apdu.CardData) (apdu.ApplicationCryptogramType, error) {
+	if cid, found := cd.Get(0x9F27); found && len(cid) == 1 {
+		return apdu.CryptogramInformationData(cid[0]).CryptogramType(), nil
+	}
+	iad, _ := cd.Get(0x9F10)
+	if len(iad) < 5 {
+		return apdu.AAC, fmt.Errorf("%w: %s", ErrMissingMandatoryData, apdu.Tag(0x9F27))
+	}
+	return apdu.ApplicationCryptogramType(iad[4] >> 4 & 0b11), nil
+}
+
+// offlineDecision checks whether a transaction for which the card returned a
+// TC can be approved offline, falling back to online, decline or another
+// interface as indicated by the CTQ.
+func (k Kernel3) offlineDecision(aid []byte, cd *apdu.CardData, staticData *oda.StaticData, terminalData map[apdu.Tag][]byte, ctq apdu.CardTransactionQualifiers, transaction Transaction) OutcomeType {
+	now := transaction.now()
+	if applicationExpired(cd, now) {
+		if ctq.Has(apdu.CTQGoOnlineIfApplicationExpired) && !k.config.OfflineOnly {
+			return OutcomeOnlineRequest
+		}
+		return OutcomeDeclined
+	}
+	if err := k.verifyFDDA(aid, cd, staticData, terminalData, now); err != nil {
+		switch {
+		case ctq.Has(apdu.CTQGoOnlineIfOfflineDataAuthFails) && !k.config.OfflineOnly:
+			return OutcomeOnlineRequest
+		case ctq.Has(apdu.CTQSwitchInterfaceIfOfflineDataAuthFails) && k.config.ContactChipSupported:
+			return OutcomeTryAnotherInterface
+		default:
+			return OutcomeDeclined
+		}
+	}
+	return OutcomeApproved
+}
+
+func applicationExpired(cd *apdu.CardData, now time.Time) bool {
+	expiry, err := cd.ApplicationExpirationDate()
+	if err != nil {
+		track2, err := cd.Track2()
+		if err != nil {
+			return false
+		}
+		if expiry, err = track2.Expiry(); err != nil {
+			return false
+		}
+	}
+	return !now.Before(expiry.AddDate(0, 0, 1))
+}
+
+// verifyFDDA recovers the ICC public key and verifies the Signed Dynamic
+// Application Data returned by the card (EMV Book C-3, section 5.3).
+func (k Kernel3) verifyFDDA(aid []byte, cd *apdu.CardData, staticData *oda.StaticData, terminalData map[apdu.Tag][]byte, now time.Time) error {
+	values := cd.Values()
+	sdad, found := values[0x9F4B]
+	if !found {
+		return oda.ErrMissingSignedDynamicData
+	}
+	icc, err := recoverICCPublicKey(k.config.CAPublicKeys, aid, cd, staticData, now)
+	if err != nil {
+		return err
+	}
+
+	// fDDA version 01 signs the amount, the currency and the Card
+	// Authentication Related Data besides the Unpredictable Number.
+	terminalDynamicData := terminalData[0x9F37]
+	if related := values[0x9F69]; len(related) > 0 && related[0] == 0x01 {
+		terminalDynamicData = bytes.Join([][]byte{terminalData[0x9F37], terminalData[0x9F02], terminalData[0x5F2A], related}, nil)
+	}
+	_, err = oda.RecoverSignedDynamicData(icc, sdad, terminalDynamicData)
+	return err
+}
+
+// cvm selects the CVM as requested by the card in the CTQ or, when the card
+// does not request one, as required by the reader. It returns false when a
+// CVM is required and none is possible.
+func (k Kernel3) cvm(ttq apdu.TerminalTransactionQualifiers, ctq apdu.CardTransactionQualifiers, online bool) (CVM, bool) {
+	switch {
+	case ctq.Has(apdu.CTQOnlinePINRequired) && k.config.OnlinePINSupported && online:
+		return CVMOnlinePIN, true
+	case ctq.Has(apdu.CTQConsumerDeviceCVMPerformed) && k.config.ConsumerDeviceCVMSupported:
+		return CVMConfirmationCodeVerified, true
+	case ctq.Has(apdu.CTQSignatureRequired) && k.config.SignatureSupported:
+		return CVMObtainSignature, true
+	case !ttq.Has(apdu.TTQCVMRequired):
+		return CVMNoCVM, true
+	case k.config.SignatureSupported:
+		return CVMObtainSignature, true
+	case k.config.OnlinePINSupported && online:
+		return CVMOnlinePIN, true
+	default:
+		return CVMNotApplicable, false
+	}
+}
@@ -0,0 +1,313 @@
+package contactless
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mniak/apdu"
+	"github.com/mniak/apdu/drivers/simulator"
+	"github.com/mniak/apdu/internal/ber"
+	"github.com/mniak/apdu/internal/test"
+	"github.com/mniak/apdu/oda"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testKeyset struct{ ca, issuer, icc *rsa.PrivateKey }
+
+// loadTestKeys generates the keys once for all the tests. The error is kept
+// so that every test using the keys fails, not only the first one.
+var loadTestKeys = sync.OnceValues(func() (testKeyset, error) {
+	var keys testKeyset
+	var err error
+	if keys.ca, err = rsa.GenerateKey(rand.Reader, 1408); err != nil {
+		return keys, err
+	}
+	if keys.issuer, err = rsa.GenerateKey(rand.Reader, 1152); err != nil {
+		return keys, err
+	}
+	keys.icc, err = rsa.GenerateKey(rand.Reader, 1024)
+	return keys, err
+})
+
+func generateTestKeys(t *testing.T) testKeyset {
+	keys, err := loadTestKeys()
+	require.NoError(t, err)
+	return keys
+}
+
+type qVSDCCard struct {
+	// cid is omitted from the GPO response when zero
+	cid byte
+	ctq string
+	iad string
+	// expiry is the Application Expiration Date in the format YYMMDD
+	expiry string
+	// tamperSDAD signs a different Unpredictable Number
+	tamperSDAD bool
+}
+
+func (c qVSDCCard) simulate(t *testing.T) simulatedCard {
+	keys := generateTestKeys(t)
+	aid := test.MustParseHex(t, "A0000000031010")
+
+	fci := ber.Encode(0x6F, bytes.Join([][]byte{
+		ber.Encode(0x84, aid),
+		ber.Encode(0xA5, bytes.Join([][]byte{
+			ber.Encode(0x50, []byte("VISA")),
+			ber.Encode(0x9F38, test.MustParseHex(t, "9F6604 9F0206 5F2A02 9F3704")),
+		}, nil)),
+	}, nil))
+
+	issuerCertificate, issuerRemainder := test.EMVCertificate(t, keys.ca, keys.issuer,
+		test.MustParseHex(t, "02 476173FF 1249 000001 01 01"), 36)
+	record1 := bytes.Join([][]byte{
+		ber.Encode(0x8F, []byte{0x92}),
+		ber.Encode(0x90, issuerCertificate),
+		ber.Encode(0x92, issuerRemainder),
+		ber.Encode(0x9F32, test.RSAExponent(keys.issuer)),
+	}, nil)
+	aip := test.MustParseHex(t, "2000")
+	iccCertificate, iccRemainder := test.EMVCertificate(t, keys.issuer, keys.icc,
+		test.MustParseHex(t, "04 4761739001010010FFFF 1249 000002 01 01"), 42, record1, aip)
+	expiry := c.expiry
+	if expiry == "" {
+		expiry = "251231"
+	}
+	cardAuthenticationData := test.MustParseHex(t, "01 11223344 0000")
+	record2 := bytes.Join([][]byte{
+		ber.Encode(0x9F46, iccCertificate),
+		ber.Encode(0x9F47, test.RSAExponent(keys.icc)),
+		ber.Encode(0x9F48, iccRemainder),
+		ber.Encode(0x9F4A, []byte{0x82}),
+		ber.Encode(0x57, test.MustParseHex(t, "4761739001010010D2512201")),
+		ber.Encode(0x5A, test.MustParseHex(t, "4761739001010010")),
+		ber.Encode(0x5F24, test.MustParseHex(t, expiry)),
+		ber.Encode(0x9F69, cardAuthenticationData),
+	}, nil)
+
+	return simulator.New().
+		AddApplication(aid, fci).
+		AddRecord(1, 1, ber.Encode(0x70, record1)).
+		AddRecord(1, 2, ber.Encode(0x70, record2)).
+		Handle(0x80, apdu.EMVInstructionA8_GetProcessingOptions, func(cmd apdu.Command) apdu.Response {
+			// 83 || TTQ (4) || Amount (6) || Currency (2) || UN (4)
+			pdolData := cmd.Data[2:]
+			un := pdolData[12:16]
+			if c.tamperSDAD {
+				un = []byte{0, 0, 0, 0}
+			}
+			terminalDynamicData := bytes.Join([][]byte{un, pdolData[4:10], pdolData[10:12], cardAuthenticationData}, nil)
+			sdad := test.SignEMV(t, keys.icc, test.MustParseHex(t, "05 01 03 02 1234"), terminalDynamicData)
+
+			iad := c.iad
+			if iad == "" {
+				iad = "06011203900000"
+			}
+			data := bytes.Join([][]byte{
+				ber.Encode(0x82, aip),
+				ber.Encode(0x94, test.MustParseHex(t, "08010201")),
+				ber.Encode(0x9F36, test.MustParseHex(t, "0001")),
+				ber.Encode(0x9F26, test.MustParseHex(t, "1122334455667788")),
+				ber.Encode(0x9F10, test.MustParseHex(t, iad)),
+				ber.Encode(0x9F6C, test.MustParseHex(t, c.ctq)),
+				ber.Encode(0x9F5D, test.MustParseHex(t, "000000010000")),
+				ber.Encode(0x9F4B, sdad),
+			}, nil)
+			if c.cid != 0 {
+				data = append(data, ber.Encode(0x9F27, []byte{c.cid})...)
+			}
+			return apdu.Response{Data: ber.Encode(0x77, data), Trailer: 0x9000}
+		})
+}
+
+func kernel3Config(t *testing.T) Kernel3Config {
+	keys := generateTestKeys(t)
+	store := oda.NewCAPublicKeyStore().WithClock(func() time.Time {
+		return time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	})
+	require.NoError(t, store.Add(oda.CAPublicKey{
+		RID:   test.MustParseHex(t, "A000000003"),
+		Index: 0x92,
+		PublicKey: oda.PublicKey{
+			Modulus:  keys.ca.N.Bytes(),
+			Exponent: test.RSAExponent(keys.ca),
+		},
+		HashAlgorithm: 0x01,
+		KeyAlgorithm:  0x01,
+	}))
+	return Kernel3Config{
+		TerminalData: map[apdu.Tag][]byte{
+			0x9F1A: {0x08, 0x40},
+			0x5F2A: {0x08, 0x40},
+		},
+		ContactlessTransactionLimit: 100000,
+		FloorLimit:                  1000,
+		CVMRequiredLimit:            3000,
+		OnlinePINSupported:          true,
+		SignatureSupported:          true,
+		CAPublicKeys:                store,
+	}
+}
+
+func TestKernel3(t *testing.T) {
+	testCases := []struct {
+		name          string
+		card          qVSDCCard
+		amount        uint64
+		contactChip   bool
+		offlineOnly   bool
+		expected      OutcomeType
+		expectedCVM   CVM
+		expectedTTQ   string
+		expectReceipt bool
+	}{
+		{
+			name:        "offline approved with fDDA",
+			card:        qVSDCCard{cid: 0x40, ctq: "0000"},
+			amount:      500,
+			expected:    OutcomeApproved,
+			expectedCVM: CVMNoCVM,
+			expectedTTQ: "26000000",
+		},
+		{
+			name:        "online with PIN requested by the card",
+			card:        qVSDCCard{cid: 0x80, ctq: "8000"},
+			amount:      5000,
+			expected:    OutcomeOnlineRequest,
+			expectedCVM: CVMOnlinePIN,
+			expectedTTQ: "26C00000",
+		},
+		{
+			name:        "offline only reader",
+			card:        qVSDCCard{cid: 0x40, ctq: "0000"},
+			amount:      500,
+			offlineOnly: true,
+			expected:    OutcomeApproved,
+			expectedCVM: CVMNoCVM,
+			expectedTTQ: "2E000000",
+		},
+		{
+			name:        "declined by the card",
+			card:        qVSDCCard{cid: 0x00, iad: "06011203000000", ctq: "0000"},
+			amount:      500,
+			expected:    OutcomeDeclined,
+			expectedCVM: CVMNotApplicable,
+			expectedTTQ: "26000000",
+		},
+		{
+			name:        "cryptogram type from the IAD",
+			card:        qVSDCCard{iad: "06011203A00000", ctq: "0000"},
+			amount:      2000,
+			expected:    OutcomeOnlineRequest,
+			expectedCVM: CVMNoCVM,
+			expectedTTQ: "26800000",
+		},
+		{
+			name:        "online request declined by offline only reader",
+			card:        qVSDCCard{cid: 0x80, ctq: "0000"},
+			amount:      500,
+			offlineOnly: true,
+			expected:    OutcomeDeclined,
+			expectedCVM: CVMNotApplicable,
+			expectedTTQ: "2E000000",
+		},
+		{
+			name:        "fDDA failed and card requests to go online",
+			card:        qVSDCCard{cid: 0x40, ctq: "2000", tamperSDAD: true},
+			amount:      500,
+			expected:    OutcomeOnlineRequest,
+			expectedCVM: CVMNoCVM,
+			expectedTTQ: "26000000",
+		},
+		{
+			name:        "fDDA failed",
+			card:        qVSDCCard{cid: 0x40, ctq: "0000", tamperSDAD: true},
+			amount:      500,
+			expected:    OutcomeDeclined,
+			expectedCVM: CVMNotApplicable,
+			expectedTTQ: "26000000",
+		},
+		{
+			name:        "fDDA failed and card requests to switch interface",
+			card:        qVSDCCard{cid: 0x40, ctq: "1000", tamperSDAD: true},
+			amount:      500,
+			contactChip: true,
+			expected:    OutcomeTryAnotherInterface,
+			expectedCVM: CVMNotApplicable,
+			expectedTTQ: "36000000",
+		},
+		{
+			name:        "application expired and card requests to go online",
+			card:        qVSDCCard{cid: 0x40, ctq: "0800", expiry: "250430"},
+			amount:      500,
+			expected:    OutcomeOnlineRequest,
+			expectedCVM: CVMNoCVM,
+			expectedTTQ: "26000000",
+		},
+		{
+			name:          "signature for amount above CVM limit",
+			card:          qVSDCCard{cid: 0x80, ctq: "0000"},
+			amount:        5000,
+			expected:      OutcomeOnlineRequest,
+			expectedCVM:   CVMObtainSignature,
+			expectedTTQ:   "26C00000",
+			expectReceipt: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			config := kernel3Config(t)
+			config.ContactChipSupported = tc.contactChip
+			config.OfflineOnly = tc.offlineOnly
+			card := tc.card.simulate(t)
+			kernel := NewKernel3(apdu.NewClient(card).LowLevelCommands, config)
+
+			aid := test.MustParseHex(t, "A0000000031010")
+			outcome, err := kernel.Process(apdu.Candidate{
+				DirectoryEntry: apdu.DirectoryEntry{ADFName: aid},
+				Combination:    apdu.Combination{AID: aid, KernelID: []byte{apdu.Kernel3}},
+				SelectName:     aid,
+			}, Transaction{
+				Amount:              tc.amount,
+				Time:                time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC),
+				UnpredictableNumber: test.MustParseHex(t, "CAFEBABE"),
+			})
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.expected, outcome.Type)
+			assert.Equal(t, tc.expectedCVM, outcome.CVM)
+			assert.Equal(t, tc.expectReceipt, outcome.Receipt)
+
+			commands := card.Commands()
+			require.GreaterOrEqual(t, len(commands), 2)
+			gpo := commands[1]
+			test.AssertBytesEqual(t, tc.expectedTTQ, gpo.Data[2:6])
+
+			if tc.expected == OutcomeApproved || tc.expected == OutcomeOnlineRequest {
+				test.AssertBytesEqual(t, "1122334455667788", outcome.DataRecord[0x9F26])
+				test.AssertBytesEqual(t, tc.expectedTTQ, outcome.DataRecord[0x9F66])
+				test.AssertBytesEqual(t, "4761739001010010D2512201", outcome.DataRecord[0x57])
+			}
+			if tc.expected == OutcomeApproved {
+				test.AssertBytesEqual(t, "000000010000", outcome.UIRequest.Value)
+			}
+		})
+	}
+}
+
+func TestKernel3_SwitchInterfaceForCash(t *testing.T) {
+	config := kernel3Config(t)
+	config.ContactChipSupported = true
+	card := qVSDCCard{cid: 0x80, ctq: "0400"}.simulate(t)
+	kernel := NewKernel3(apdu.NewClient(card).LowLevelCommands, config)
+
+	aid := test.MustParseHex(t, "A0000000031010")
+	outcome, err := kernel.Process(apdu.Candidate{SelectName: aid}, Transaction{Amount: 5000, Type: 0x01})
+	require.NoError(t, err)
+	assert.Equal(t, OutcomeTryAnotherInterface, outcome.Type)
+}
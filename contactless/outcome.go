@@ -0,0 +1,154 @@
+package contactless
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/mniak/apdu"
+)
+
+// OutcomeType is the result of the processing of a kernel, as defined in EMV Book A.
+type OutcomeType int
+
+const (
+	OutcomeApproved OutcomeType = iota + 1
+	OutcomeDeclined
+	OutcomeOnlineRequest
+	OutcomeEndApplication
+	OutcomeSelectNext
+	OutcomeTryAnotherInterface
+	OutcomeTryAgain
+)
+
+func (o OutcomeType) String() string {
+	switch o {
+	case OutcomeApproved:
+		return "Approved"
+	case OutcomeDeclined:
+		return "Declined"
+	case OutcomeOnlineRequest:
+		return "Online Request"
+	case OutcomeEndApplication:
+		return "End Application"
+	case OutcomeSelectNext:
+		return "Select Next"
+	case OutcomeTryAnotherInterface:
+		return "Try Another Interface"
+	case OutcomeTryAgain:
+		return "Try Again"
+	default:
+		return fmt.Sprintf("Unknown (%d)", int(o))
+	}
+}
+
+// CVM is the cardholder verification method required by the outcome.
+type CVM int
+
+const (
+	CVMNoCVM CVM = iota
+	CVMObtainSignature
+	CVMOnlinePIN
+	CVMConfirmationCodeVerified
+	CVMNotApplicable
+)
+
+func (c CVM) String() string {
+	switch c {
+	case CVMNoCVM:
+		return "No CVM"
+	case CVMObtainSignature:
+		return "Obtain Signature"
+	case CVMOnlinePIN:
+		return "Online PIN"
+	case CVMConfirmationCodeVerified:
+		return "Confirmation Code Verified"
+	case CVMNotApplicable:
+		return "N/A"
+	default:
+		return fmt.Sprintf("Unknown (%d)", int(c))
+	}
+}
+
+// MessageID identifies the message displayed to the cardholder, as defined in EMV Book A, section 9.4.
+type MessageID byte
+
+const (
+	MessageApproved                MessageID = 0x03
+	MessageNotAuthorised           MessageID = 0x07
+	MessagePleaseEnterYourPIN      MessageID = 0x09
+	MessageProcessingError         MessageID = 0x0F
+	MessageRemoveCard              MessageID = 0x10
+	MessageWelcome                 MessageID = 0x14
+	MessagePresentCard             MessageID = 0x15
+	MessageProcessing              MessageID = 0x16
+	MessageCardReadOKRemoveCard    MessageID = 0x17
+	MessageInsertOrSwipeCard       MessageID = 0x18
+	MessagePresentOneCardOnly      MessageID = 0x19
+	MessageApprovedPleaseSign      MessageID = 0x1A
+	MessageAuthorisingPleaseWait   MessageID = 0x1B
+	MessageInsertSwipeOrTryAnother MessageID = 0x1C
+	MessagePleaseInsertCard        MessageID = 0x1D
+	MessageSeePhoneForInstructions MessageID = 0x20
+	MessagePresentCardAgain        MessageID = 0x21
+	MessageNotApplicable           MessageID = 0xFF
+)
+
+// Status is the status of the reader indicated to the cardholder.
+type Status byte
+
+const (
+	StatusNotReady Status = iota
+	StatusIdle
+	StatusReadyToRead
+	StatusProcessing
+	StatusCardReadSuccessfully
+	StatusProcessingError
+	StatusNotApplicable Status = 0xFF
+)
+
+// UserInterfaceRequest asks the reader to display a message and indicate a status.
+type UserInterfaceRequest struct {
+	MessageID          MessageID
+	Status             Status
+	HoldTime           time.Duration
+	LanguagePreference string
+	// Value is the amount or balance displayed with the message, if any.
+	Value []byte
+	// CurrencyCode is the currency of the value.
+	CurrencyCode []byte
+}
+
+// Outcome is the result of the kernel processing.
+type Outcome struct {
+	Type      OutcomeType
+	CVM       CVM
+	Receipt   bool
+	UIRequest *UserInterfaceRequest
+	// DataRecord are the data objects sent to the acquirer in the
+	// authorisation or clearing message.
+	DataRecord map[apdu.Tag][]byte
+	// CardData are all the data objects read from the card.
+	CardData *apdu.CardData
+}
+
+func endApplication(message MessageID) Outcome {
+	return Outcome{
+		Type: OutcomeEndApplication,
+		CVM:  CVMNotApplicable,
+		UIRequest: &UserInterfaceRequest{
+			MessageID: message,
+			Status:    StatusNotReady,
+		},
+	}
+}
+
+func tryAnotherInterface() Outcome {
+	return Outcome{
+		Type: OutcomeTryAnotherInterface,
+		CVM:  CVMNotApplicable,
+		UIRequest: &UserInterfaceRequest{
+			MessageID: MessageInsertSwipeOrTryAnother,
+			Status:    StatusNotReady,
+		},
+	}
+}
@@ -0,0 +1,147 @@
+package contactless
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mniak/apdu"
+	"github.com/mniak/apdu/oda"
+)
+
+// Transaction is the data of the transaction provided to the kernels.
+type Transaction struct {
+	// Amount and AmountOther are in minor units.
+	Amount      uint64
+	AmountOther uint64
+	Type        byte
+	Time        time.Time
+	// UnpredictableNumber is generated when not provided.
+	UnpredictableNumber []byte
+}
+
+// terminalData merges the configured terminal data with the transaction data.
+func (t Transaction) terminalData(configured map[apdu.Tag][]byte) (map[apdu.Tag][]byte, error) {
+	result := make(map[apdu.Tag][]byte, len(configured)+7)
+	for tag, value := range configured {
+		result[tag] = value
+	}
+
+	un := t.UnpredictableNumber
+	if len(un) == 0 {
+		un = make([]byte, 4)
+		if _, err := rand.Read(un); err != nil {
+			return nil, fmt.Errorf("failed to generate unpredictable number: %w", err)
+		}
+	}
+	now := t.now()
+
+	result[0x9F02] = numeric(t.Amount, 6)
+	result[0x9F03] = numeric(t.AmountOther, 6)
+	result[0x9C] = []byte{t.Type}
+	result[0x9A] = numeric(uint64(now.Year()%100*10000+int(now.Month())*100+now.Day()), 3)
+	result[0x9F21] = numeric(uint64(now.Hour()*10000+now.Minute()*100+now.Second()), 3)
+	result[0x9F37] = un
+	return result, nil
+}
+
+func (t Transaction) now() time.Time {
+	if t.Time.IsZero() {
+		return time.Now()
+	}
+	return t.Time
+}
+
+// numeric encodes a number in the format n with the length in bytes.
+func numeric(value uint64, length int) []byte {
+	result := make([]byte, length)
+	for i := length - 1; i >= 0; i-- {
+		result[i] = byte(value%10) | byte(value/10%10)<<4
+		value /= 100
+	}
+	return result
+}
+
+// dataRecord copies the data objects present in the sources, in order of
+// precedence, for the tags listed.
+func dataRecord(tags []apdu.Tag, sources ...map[apdu.Tag][]byte) map[apdu.Tag][]byte {
+	result := make(map[apdu.Tag][]byte)
+	for _, tag := range tags {
+		for _, source := range sources {
+			if value, found := source[tag]; found {
+				result[tag] = value
+				break
+			}
+		}
+	}
+	return result
+}
+
+// readRecords reads the records listed in the AFL into the card data. When
+// staticData is not nil, the records marked for offline data authentication
+// are added to it.
+func readRecords(client apdu.LowLevelCommands, cd *apdu.CardData, staticData *oda.StaticData) error {
+	entries, err := cd.AFL().GetEntries()
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		for record := entry.FirstRecord; record <= entry.LastRecord; record++ {
+			data, err := client.ReadRecord(entry.SFI, record)
+			if err != nil {
+				return fmt.Errorf("failed to read record %d of SFI %d: %w", record, entry.SFI, err)
+			}
+			if err := cd.AddTLV(data); err != nil {
+				return err
+			}
+			if staticData != nil && record < entry.FirstRecord+entry.RecordsInDataAuth {
+				if err := staticData.AddRecord(entry.SFI, data); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// recoverICCPublicKey recovers the issuer public key with the CA public keys
+// and then the ICC public key, checking the static data read from the
+// records marked for offline data authentication.
+func recoverICCPublicKey(store *oda.CAPublicKeyStore, aid []byte, cd *apdu.CardData, staticData *oda.StaticData, now time.Time) (oda.PublicKey, error) {
+	if store == nil {
+		return oda.PublicKey{}, errors.New("no CA public keys available for offline data authentication")
+	}
+	values := cd.Values()
+	if len(values[0x8F]) != 1 {
+		return oda.PublicKey{}, fmt.Errorf("%w: %s", ErrMissingMandatoryData, apdu.Tag(0x8F))
+	}
+	pan, err := cd.PAN()
+	if err != nil {
+		track2, err := cd.Track2()
+		if err != nil {
+			return oda.PublicKey{}, err
+		}
+		pan = track2.PAN
+	}
+
+	issuer, err := store.RecoverIssuerPublicKey(aid, values[0x8F][0], values[0x90], values[0x92], values[0x9F32], string(pan))
+	if err != nil {
+		return oda.PublicKey{}, err
+	}
+	if tagList, found := values[0x9F4A]; found {
+		if !bytes.Equal(tagList, []byte{0x82}) {
+			return oda.PublicKey{}, fmt.Errorf("invalid SDA tag list %X", tagList)
+		}
+		staticData.AddSDATagListValue(values[0x82])
+	}
+	icc, err := oda.RecoverICCPublicKey(issuer.PublicKey, values[0x9F46], values[0x9F48], values[0x9F47], staticData.Bytes(), string(pan))
+	if err != nil {
+		return oda.PublicKey{}, err
+	}
+	if icc.Expired(now) {
+		return oda.PublicKey{}, oda.ErrCertificateExpired
+	}
+	return icc.PublicKey, nil
+}
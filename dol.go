@@ -0,0 +1,99 @@
+package apdu
+
+import (
+	"encoding/hex"
+	"fmt"
+
+	"github.com/mniak/apdu/internal/ber"
+)
+
+// Tag is a BER-TLV tag with all of its bytes packed big-endian in an integer,
+// so that 9F37 is represented as 0x9F37. It is the same type used by the
+// internal BER-TLV parser, so that parsed tags need no conversion.
+type Tag = ber.Tag
+
+type DOLEntry struct {
+	Tag    Tag
+	Length int
+}
+
+// DataObjectList is a list of tags and lengths sent by the card, like the
+// PDOL, CDOL1, CDOL2 and DDOL, that the terminal uses to build the data sent
+// in a command.
+type DataObjectList []DOLEntry
+
+func ParseDOL(data []byte) (DataObjectList, error) {
+	entries, err := ber.ParseDOL(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse DOL: %w", err)
+	}
+	result := make(DataObjectList, len(entries))
+	for i, e := range entries {
+		result[i] = DOLEntry{
+			Tag:    e.Tag,
+			Length: e.Length,
+		}
+	}
+	return result, nil
+}
+
+// ParseDOLHex parses a DOL read as a hex string, like EMVProprietaryTemplate.CDOL2Hex.
+func ParseDOLHex(data string) (DataObjectList, error) {
+	decoded, err := hex.DecodeString(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse DOL: %w", err)
+	}
+	return ParseDOL(decoded)
+}
+
+func (dol DataObjectList) Contains(tag Tag) bool {
+	for _, e := range dol {
+		if e.Tag == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// Build concatenates the values of the data objects in the list, as
+// specified in EMV Book 3, section 5.4. Values for tags not found are filled
+// with zeroes, numeric values are padded and truncated at the left and all
+// the other values at the right. Compressed numeric values are padded with
+// 'F' nibbles instead of zeroes.
+func (dol DataObjectList) Build(values map[Tag][]byte) []byte {
+	var result []byte
+	for _, e := range dol {
+		value, found := values[e.Tag]
+		if !found {
+			result = append(result, make([]byte, e.Length)...)
+			continue
+		}
+		switch info, _ := LookupTag(e.Tag); info.Format {
+		case FormatNumeric:
+			result = append(result, padLeft(value, e.Length)...)
+		case FormatCompressedNumeric:
+			result = append(result, padRight(value, e.Length, 0xFF)...)
+		default:
+			result = append(result, padRight(value, e.Length, 0x00)...)
+		}
+	}
+	return result
+}
+
+func padLeft(value []byte, length int) []byte {
+	if len(value) >= length {
+		return value[len(value)-length:]
+	}
+	return append(make([]byte, length-len(value)), value...)
+}
+
+func padRight(value []byte, length int, padding byte) []byte {
+	if len(value) >= length {
+		return value[:length]
+	}
+	result := append(make([]byte, 0, length), value...)
+	for len(result) < length {
+		result = append(result, padding)
+	}
+	return result
+}
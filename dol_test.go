@@ -0,0 +1,54 @@
+package apdu
+
+import (
+	"testing"
+
+	"github.com/mniak/apdu/internal/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDataObjectList_Build(t *testing.T) {
+	dol, err := ParseDOLHex("9F02069F03069F1A0295055F2A029A039C019F37048A029102")
+	require.NoError(t, err)
+	require.Len(t, dol, 10)
+	assert.Equal(t, DOLEntry{Tag: 0x9F02, Length: 6}, dol[0])
+	assert.True(t, dol.Contains(0x8A))
+	assert.False(t, dol.Contains(0x9F4C))
+
+	data := dol.Build(map[Tag][]byte{
+		0x9F02: {0x10, 0x00},
+		0x9F1A: {0x00, 0x76},
+		0x5F2A: {0x09, 0x86},
+		0x9A:   {0x24, 0x01, 0x31},
+		0x9F37: {0xCA, 0xFE, 0xBA, 0xBE, 0xFF},
+		0x8A:   []byte("00"),
+		0x91:   {0x01, 0x02, 0x03},
+	})
+	test.AssertBytesEqual(t, ""+
+		"000000001000"+ // 9F02 padded left
+		"000000000000"+ // 9F03 missing
+		"0076"+
+		"0000000000"+ // 95 missing
+		"0986"+
+		"240131"+
+		"00"+
+		"CAFEBABE"+ // 9F37 truncated right
+		"3030"+
+		"0102", // 91 truncated right
+		data)
+}
+
+func TestDataObjectList_Build_CompressedNumeric(t *testing.T) {
+	dol, err := ParseDOLHex("5A0A9F2002")
+	require.NoError(t, err)
+
+	data := dol.Build(map[Tag][]byte{
+		0x5A:   {0x54, 0x13, 0x33, 0x00, 0x89, 0x02, 0x00, 0x11},
+		0x9F20: {0x12, 0x34, 0x5F},
+	})
+	test.AssertBytesEqual(t, ""+
+		"5413330089020011FFFF"+ // 5A padded right with F
+		"1234", // 9F20 truncated right
+		data)
+}
@@ -3,3 +3,9 @@ package apdu
 type Driver interface {
 	SendBytes(bytes []byte) ([]byte, error)
 }
+
+// ATRProvider is implemented by the drivers that know the Answer To Reset of
+// the card, which the client uses to detect the capabilities of the card.
+type ATRProvider interface {
+	ATR() ([]byte, error)
+}
@@ -56,4 +56,16 @@ func (d driver) SendBytes(b []byte) ([]byte, error) {
 	return r, err
 }
 
+// ATR returns the Answer To Reset of the connected card.
+func (d driver) ATR() ([]byte, error) {
+	if d.card == nil {
+		return nil, ErrNotConnected
+	}
+	status, err := d.card.Status()
+	if err != nil {
+		return nil, err
+	}
+	return status.Atr, nil
+}
+
 var ErrNotConnected = errors.New("not connected. Connect() should be called first")
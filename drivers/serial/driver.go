@@ -0,0 +1,100 @@
+package serial
+
+import (
+	"errors"
+	"io"
+	"log"
+
+	"github.com/mniak/apdu"
+	"github.com/mniak/apdu/internal/noop"
+)
+
+// protocol is a transmission protocol that exchanges command and response
+// APDUs with the card as TPDUs.
+type protocol interface {
+	transmit(command []byte) ([]byte, error)
+}
+
+type driver struct {
+	port     io.ReadWriter
+	protocol protocol
+	atr      []byte
+	// reset drives the reset line of the card and setBaudRate changes the
+	// speed of the port, as supported by the reader
+	reset       func(warm bool) error
+	setBaudRate func(baud int) error
+	clock       int
+	logger      *log.Logger
+}
+
+// New creates a driver that resets the card on the port when Connect is
+// called.
+func New(port io.ReadWriter) *driver {
+	return &driver{
+		port:   port,
+		clock:  defaultClock,
+		logger: noop.Logger(),
+	}
+}
+
+// NewT0 creates a driver for a card that is already reset and uses the
+// protocol T=0 on the port.
+func NewT0(port io.ReadWriter) *driver {
+	result := New(port)
+	result.protocol = &t0{port: port}
+	return result
+}
+
+// NewT1 creates a driver for a card that is already reset and uses the
+// protocol T=1 with the parameters indicated in its ATR.
+func NewT1(port io.ReadWriter, params apdu.T1Parameters) *driver {
+	result := New(port)
+	result.protocol = newT1(port, params)
+	return result
+}
+
+func (d *driver) LoggingTo(w io.Writer) *driver {
+	d.logger = log.New(w, "[serial] ", 0)
+	return d
+}
+
+// WithReset sets the function that drives the reset line of the card. Without
+// it, the card is expected to send the ATR when Connect is called.
+func (d *driver) WithReset(reset func(warm bool) error) *driver {
+	d.reset = reset
+	return d
+}
+
+// WithBaudRate sets the function that changes the speed of the port. Without
+// it, the card is kept at the initial speed.
+func (d *driver) WithBaudRate(setBaudRate func(baud int) error) *driver {
+	d.setBaudRate = setBaudRate
+	return d
+}
+
+// WithClock sets the frequency of the clock of the card, in Hz.
+func (d *driver) WithClock(hz int) *driver {
+	d.clock = hz
+	return d
+}
+
+func (d *driver) SendBytes(b []byte) ([]byte, error) {
+	if d.protocol == nil {
+		return nil, ErrNotConnected
+	}
+
+	d.logger.Printf("Data sent: %2X\n", b)
+	r, err := d.protocol.transmit(b)
+	d.logger.Printf("Data received: %2X\n", r)
+	return r, err
+}
+
+// ATR returns the Answer To Reset received by Connect.
+func (d *driver) ATR() ([]byte, error) {
+	if d.atr == nil {
+		return nil, ErrNotConnected
+	}
+	return d.atr, nil
+}
+
+var ErrNotConnected = errors.New("not connected. Connect() should be called first")
@@ -0,0 +1,57 @@
+package serial
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/mniak/apdu/internal/test"
+)
+
+// step is an exchange of the scripted card: the bytes expected from the
+// driver, followed by the bytes sent back.
+type step struct {
+	expect []byte
+	reply  []byte
+}
+
+// scriptedCard returns the port connected to a card that follows the steps.
+func scriptedCard(t *testing.T, steps ...step) io.ReadWriter {
+	port, card := net.Pipe()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer card.Close()
+		for i, s := range steps {
+			received := make([]byte, len(s.expect))
+			if _, err := io.ReadFull(card, received); err != nil {
+				t.Errorf("step %d: expected %X: %v", i, s.expect, err)
+				return
+			}
+			if !bytes.Equal(s.expect, received) {
+				t.Errorf("step %d: expected %X but received %X", i, s.expect, received)
+				return
+			}
+			if len(s.reply) == 0 {
+				continue
+			}
+			if _, err := card.Write(s.reply); err != nil {
+				t.Errorf("step %d: %v", i, err)
+				return
+			}
+		}
+	}()
+	t.Cleanup(func() {
+		port.Close()
+		<-done
+	})
+	return port
+}
+
+func exchange(t *testing.T, expect, reply string) step {
+	return step{
+		expect: test.MustParseHex(t, expect),
+		reply:  test.MustParseHex(t, reply),
+	}
+}
@@ -0,0 +1,253 @@
+package serial
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math/bits"
+
+	"github.com/mniak/apdu"
+)
+
+var (
+	ErrPPSRejected         = errors.New("the card rejected the PPS request")
+	ErrUnsupportedProtocol = errors.New("unsupported protocol")
+	ErrUnsupportedFiDi     = errors.New("unsupported Fi/Di")
+)
+
+const (
+	// defaultClock is the usual frequency of the clock of the readers, which
+	// makes the initial speed 9600 baud
+	defaultClock = 3_571_200
+	// defaultFiDi is Fi=372 and Di=1, used until the PPS
+	defaultFiDi = 0x11
+
+	ppss = 0xFF
+)
+
+// Connect makes a cold reset of the card, reads the ATR and negotiates the
+// protocol and the speed with PPS.
+func (d *driver) Connect() error {
+	return d.activate(false)
+}
+
+// WarmReset resets the card keeping it powered, which is the way to recover
+// from a failed PPS exchange.
+func (d *driver) WarmReset() error {
+	return d.activate(true)
+}
+
+func (d *driver) activate(warm bool) error {
+	d.protocol = nil
+	d.atr = nil
+	if err := d.changeBaudRate(defaultFiDi); err != nil {
+		return err
+	}
+	if d.reset != nil {
+		if err := d.reset(warm); err != nil {
+			return err
+		}
+	}
+
+	port, raw, err := readATR(d.port)
+	if err != nil {
+		return err
+	}
+	d.logger.Printf("ATR received: %2X\n", raw)
+	atr, err := apdu.ParseATR(raw)
+	if err != nil {
+		return err
+	}
+
+	protocol, fidi, err := d.negotiate(port, atr)
+	if err != nil {
+		return err
+	}
+	if err := d.changeBaudRate(fidi); err != nil {
+		return err
+	}
+
+	switch protocol {
+	case 0:
+		d.protocol = &t0{port: port}
+	case 1:
+		d.protocol = newT1(port, atr.T1Parameters())
+	default:
+		return fmt.Errorf("%w: T=%d", ErrUnsupportedProtocol, protocol)
+	}
+	d.atr = raw
+	return nil
+}
+
+// negotiate returns the protocol and the Fi/Di to be used with the card. In
+// specific mode, they are imposed by the card. Otherwise, T=1 is preferred and
+// the speed indicated in TA1 is requested with PPS when the baud rate of the
+// port can be changed.
+func (d *driver) negotiate(port io.ReadWriter, atr apdu.ATR) (int, byte, error) {
+	if protocol, specific := atr.SpecificMode(); specific {
+		ta2, _ := atr.TA(2)
+		if ta2&0b0001_0000 != 0 {
+			// The parameters are implicit
+			return protocol, defaultFiDi, nil
+		}
+		if !supportedFiDi(atr.FiDi()) {
+			return 0, 0, fmt.Errorf("%w: TA1=%02X", ErrUnsupportedFiDi, atr.FiDi())
+		}
+		return protocol, atr.FiDi(), nil
+	}
+
+	protocols := atr.Protocols()
+	protocol := protocols[0]
+	for _, p := range protocols {
+		if p == 1 {
+			protocol = p
+		}
+	}
+	fidi := byte(defaultFiDi)
+	if d.setBaudRate != nil && supportedFiDi(atr.FiDi()) {
+		fidi = atr.FiDi()
+	}
+	if protocol == protocols[0] && fidi == defaultFiDi {
+		// The default values need no PPS
+		return protocol, fidi, nil
+	}
+
+	fidi, err := exchangePPS(port, protocol, fidi)
+	return protocol, fidi, err
+}
+
+// exchangePPS sends the PPS request with PPS1 and validates the response,
+// returning the Fi/Di accepted by the card.
+func exchangePPS(port io.ReadWriter, protocol int, fidi byte) (byte, error) {
+	request := []byte{ppss, 0b0001_0000 | byte(protocol), fidi}
+	request = append(request, lrc(request)...)
+	if _, err := port.Write(request); err != nil {
+		return 0, err
+	}
+
+	response := make([]byte, 2)
+	if _, err := io.ReadFull(port, response); err != nil {
+		return 0, err
+	}
+	if response[0] != ppss {
+		return 0, fmt.Errorf("%w: PPSS=%02X", ErrPPSRejected, response[0])
+	}
+	rest := make([]byte, bits.OnesCount8(response[1]&0b0111_0000)+1)
+	if _, err := io.ReadFull(port, rest); err != nil {
+		return 0, err
+	}
+	response = append(response, rest...)
+	if lrc(response)[0] != 0 {
+		return 0, fmt.Errorf("%w: wrong PCK in %2X", ErrPPSRejected, response)
+	}
+	if response[1]&0x0F != byte(protocol) {
+		return 0, fmt.Errorf("%w: %2X", ErrPPSRejected, response)
+	}
+
+	if response[1]&0b0001_0000 == 0 {
+		return defaultFiDi, nil
+	}
+	if response[2] != fidi {
+		return 0, fmt.Errorf("%w: %2X", ErrPPSRejected, response)
+	}
+	return fidi, nil
+}
+
+// supportedFiDi tells whether neither Fi nor Di is reserved for future use.
+func supportedFiDi(fidi byte) bool {
+	return apdu.ClockRateConversion(fidi) != 0 && apdu.BaudRateAdjustment(fidi) != 0
+}
+
+func (d *driver) changeBaudRate(fidi byte) error {
+	if d.setBaudRate == nil {
+		return nil
+	}
+	if !supportedFiDi(fidi) {
+		return fmt.Errorf("%w: %02X", ErrUnsupportedFiDi, fidi)
+	}
+	baud := d.clock * apdu.BaudRateAdjustment(fidi) / apdu.ClockRateConversion(fidi)
+	d.logger.Printf("Baud rate: %d\n", baud)
+	return d.setBaudRate(baud)
+}
+
+// readATR reads the ATR byte by byte, as its length is given by T0 and TD.
+// When TS indicates the inverse convention, the port returned decodes the
+// characters of the card.
+func readATR(port io.ReadWriter) (io.ReadWriter, []byte, error) {
+	atr := make([]byte, 2)
+	if _, err := io.ReadFull(port, atr[:1]); err != nil {
+		return nil, nil, err
+	}
+	switch atr[0] {
+	case byte(apdu.DirectConvention), byte(apdu.InverseConvention):
+	case inverse(byte(apdu.InverseConvention)):
+		port = inverseConvention{port}
+		atr[0] = byte(apdu.InverseConvention)
+	default:
+		return nil, nil, fmt.Errorf("%w: TS=%02X", apdu.ErrInvalidATR, atr[0])
+	}
+
+	read := func(n int) error {
+		data := make([]byte, n)
+		if _, err := io.ReadFull(port, data); err != nil {
+			return err
+		}
+		atr = append(atr, data...)
+		return nil
+	}
+	if _, err := io.ReadFull(port, atr[1:]); err != nil {
+		return nil, nil, err
+	}
+	y := atr[1] >> 4
+	checksumPresent := false
+	for y != 0 {
+		if err := read(bits.OnesCount8(y)); err != nil {
+			return nil, nil, err
+		}
+		if y&0b1000 == 0 {
+			break
+		}
+		td := atr[len(atr)-1]
+		if td&0x0F != 0 {
+			checksumPresent = true
+		}
+		y = td >> 4
+	}
+	length := int(atr[1] & 0x0F)
+	if checksumPresent {
+		length++
+	}
+	if err := read(length); err != nil {
+		return nil, nil, err
+	}
+	return port, atr, nil
+}
+
+// inverse converts a character between the direct and the inverse
+// conventions, in which the bits are sent in the opposite order and the
+// levels are inverted.
+func inverse(b byte) byte {
+	return ^bits.Reverse8(b)
+}
+
+// inverseConvention converts the characters exchanged with a card that uses
+// the inverse convention through a port in the direct convention.
+type inverseConvention struct {
+	io.ReadWriter
+}
+
+func (c inverseConvention) Read(p []byte) (int, error) {
+	n, err := c.ReadWriter.Read(p)
+	for i := range p[:n] {
+		p[i] = inverse(p[i])
+	}
+	return n, err
+}
+
+func (c inverseConvention) Write(p []byte) (int, error) {
+	converted := make([]byte, len(p))
+	for i, b := range p {
+		converted[i] = inverse(b)
+	}
+	return c.ReadWriter.Write(converted)
+}
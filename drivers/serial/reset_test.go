@@ -0,0 +1,144 @@
+package serial
+
+import (
+	"testing"
+
+	"github.com/mniak/apdu"
+	"github.com/mniak/apdu/internal/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// encodeInverse converts the characters to the inverse convention.
+func encodeInverse(t *testing.T, hex string) []byte {
+	data := test.MustParseHex(t, hex)
+	for i, b := range data {
+		data[i] = inverse(b)
+	}
+	return data
+}
+
+func TestDriver_Connect(t *testing.T) {
+	jcop := "3BF81300008131FE454A434F5076323431B7"
+
+	t.Run("PPS with T=1", func(t *testing.T) {
+		port := scriptedCard(t,
+			exchange(t, "", jcop),
+			exchange(t, "FF 11 13 FD", "FF 11 13 FD"),
+			blocks(t, "00 C1 01 FE", "00 E1 01 FE"),
+			blocks(t, "00 00 05 00B2010C00", "00 00 02 9000"),
+		)
+		var resets []bool
+		var baudRates []int
+		driver := New(port).
+			WithReset(func(warm bool) error {
+				resets = append(resets, warm)
+				return nil
+			}).
+			WithBaudRate(func(baud int) error {
+				baudRates = append(baudRates, baud)
+				return nil
+			})
+		require.NoError(t, driver.Connect())
+		assert.Equal(t, []bool{false}, resets)
+		assert.Equal(t, []int{9600, 38400}, baudRates)
+
+		atr, err := driver.ATR()
+		require.NoError(t, err)
+		test.AssertBytesEqual(t, jcop, atr)
+
+		resp, err := driver.SendBytes(test.MustParseHex(t, "00B2010C00"))
+		require.NoError(t, err)
+		test.AssertBytesEqual(t, "9000", resp)
+	})
+	t.Run("Without baud rate", func(t *testing.T) {
+		port := scriptedCard(t,
+			exchange(t, "", jcop),
+			blocks(t, "00 C1 01 FE", "00 E1 01 FE"),
+			blocks(t, "00 00 05 00B2010C00", "00 00 02 9000"),
+		)
+		driver := New(port)
+		require.NoError(t, driver.Connect())
+		resp, err := driver.SendBytes(test.MustParseHex(t, "00B2010C00"))
+		require.NoError(t, err)
+		test.AssertBytesEqual(t, "9000", resp)
+	})
+	t.Run("PPS rejected", func(t *testing.T) {
+		port := scriptedCard(t,
+			exchange(t, "", jcop),
+			exchange(t, "FF 11 13 FD", "FF 10 13 FC"),
+		)
+		driver := New(port).WithBaudRate(func(int) error { return nil })
+		assert.ErrorIs(t, driver.Connect(), ErrPPSRejected)
+
+		_, err := driver.ATR()
+		assert.ErrorIs(t, err, ErrNotConnected)
+		_, err = driver.SendBytes(test.MustParseHex(t, "00B2010C00"))
+		assert.ErrorIs(t, err, ErrNotConnected)
+	})
+	t.Run("PPS without PPS1", func(t *testing.T) {
+		port := scriptedCard(t,
+			exchange(t, "", jcop),
+			exchange(t, "FF 11 13 FD", "FF 01 FE"),
+		)
+		var baudRates []int
+		driver := New(port).WithBaudRate(func(baud int) error {
+			baudRates = append(baudRates, baud)
+			return nil
+		})
+		require.NoError(t, driver.Connect())
+		assert.Equal(t, []int{9600, 9600}, baudRates)
+	})
+	t.Run("Specific mode", func(t *testing.T) {
+		port := scriptedCard(t,
+			exchange(t, "", "3B B0 96 00 90 01 01 B6"),
+		)
+		var baudRates []int
+		driver := New(port).WithBaudRate(func(baud int) error {
+			baudRates = append(baudRates, baud)
+			return nil
+		})
+		require.NoError(t, driver.Connect())
+		assert.Equal(t, []int{9600, 223200}, baudRates)
+		assert.IsType(t, &t1{}, driver.protocol)
+	})
+	t.Run("Specific mode with reserved Fi", func(t *testing.T) {
+		port := scriptedCard(t,
+			exchange(t, "", "3B B0 76 00 90 01 01 56"),
+		)
+		driver := New(port).WithBaudRate(func(int) error { return nil })
+		assert.ErrorIs(t, driver.Connect(), ErrUnsupportedFiDi)
+
+		_, err := driver.SendBytes(test.MustParseHex(t, "00B2010C00"))
+		assert.ErrorIs(t, err, ErrNotConnected)
+	})
+	t.Run("Inverse convention with warm reset", func(t *testing.T) {
+		port := scriptedCard(t,
+			step{reply: encodeInverse(t, "3F 00")},
+			step{reply: encodeInverse(t, "3F 00")},
+			step{expect: encodeInverse(t, "00B2010C00"), reply: encodeInverse(t, "6A83")},
+		)
+		var resets []bool
+		driver := New(port).WithReset(func(warm bool) error {
+			resets = append(resets, warm)
+			return nil
+		})
+		require.NoError(t, driver.Connect())
+		require.NoError(t, driver.WarmReset())
+		assert.Equal(t, []bool{false, true}, resets)
+
+		atr, err := driver.ATR()
+		require.NoError(t, err)
+		test.AssertBytesEqual(t, "3F00", atr)
+
+		resp, err := driver.SendBytes(test.MustParseHex(t, "00B2010C00"))
+		require.NoError(t, err)
+		test.AssertBytesEqual(t, "6A83", resp)
+	})
+	t.Run("Invalid TS", func(t *testing.T) {
+		port := scriptedCard(t,
+			exchange(t, "", "3A"),
+		)
+		assert.ErrorIs(t, New(port).Connect(), apdu.ErrInvalidATR)
+	})
+}
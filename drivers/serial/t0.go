@@ -0,0 +1,162 @@
+package serial
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+var (
+	ErrInvalidCommand = errors.New("invalid command APDU")
+	ErrExtendedLength = errors.New("extended length commands are not supported by T=0")
+	ErrProcedureByte  = errors.New("unexpected procedure byte")
+)
+
+const instructionGetResponse = 0xC0
+
+// command is a command APDU split as in the cases of ISO/IEC 7816-3,
+// section 12.1.
+type command struct {
+	header [4]byte
+	data   []byte
+	// ne is the maximum number of bytes expected in the response, or zero
+	// when Le is absent
+	ne int
+}
+
+func shortLength(b byte) int {
+	if b == 0 {
+		return 256
+	}
+	return int(b)
+}
+
+func parseCommand(b []byte) (command, error) {
+	if len(b) < 4 {
+		return command{}, fmt.Errorf("%w: %d bytes", ErrInvalidCommand, len(b))
+	}
+	var result command
+	copy(result.header[:], b)
+	body := b[4:]
+	switch {
+	case len(body) == 0:
+	case len(body) == 1:
+		result.ne = shortLength(body[0])
+	case body[0] == 0:
+		return command{}, ErrExtendedLength
+	case len(body) == 1+int(body[0]):
+		result.data = body[1:]
+	case len(body) == 2+int(body[0]):
+		result.data = body[1 : len(body)-1]
+		result.ne = shortLength(body[len(body)-1])
+	default:
+		return command{}, fmt.Errorf("%w: body length %d does not match Lc", ErrInvalidCommand, len(body))
+	}
+	return result, nil
+}
+
+// t0 is the character oriented protocol T=0, defined in ISO/IEC 7816-3,
+// section 10. The header is sent first and the card requests the data, or
+// sends the response data, with procedure bytes.
+type t0 struct {
+	port io.ReadWriter
+}
+
+func (p *t0) transmit(b []byte) ([]byte, error) {
+	cmd, err := parseCommand(b)
+	if err != nil {
+		return nil, err
+	}
+
+	var data []byte
+	var sw [2]byte
+	switch {
+	case len(cmd.data) > 0:
+		// Cases 3 and 4: the response data, if any, is retrieved with GET
+		// RESPONSE
+		_, sw, err = p.exchange(cmd.header, byte(len(cmd.data)), cmd.data, 0)
+	case cmd.ne > 0:
+		data, sw, err = p.exchange(cmd.header, byte(cmd.ne), nil, cmd.ne)
+		if err == nil && sw[0] == 0x6C {
+			data, sw, err = p.exchange(cmd.header, sw[1], nil, shortLength(sw[1]))
+		}
+	default:
+		_, sw, err = p.exchange(cmd.header, 0, nil, 0)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for sw[0] == 0x61 && len(data) < cmd.ne {
+		var more []byte
+		more, sw, err = p.getResponse(cmd.header[0], min(shortLength(sw[1]), cmd.ne-len(data)))
+		if err != nil {
+			return nil, err
+		}
+		data = append(data, more...)
+	}
+	return append(data, sw[:]...), nil
+}
+
+// getResponse retrieves the response data available, asking again with the
+// exact length when the card answers 6Cxx.
+func (p *t0) getResponse(class byte, length int) ([]byte, [2]byte, error) {
+	header := [4]byte{class & 0b11, instructionGetResponse, 0x00, 0x00}
+	data, sw, err := p.exchange(header, byte(length), nil, length)
+	if err == nil && sw[0] == 0x6C {
+		data, sw, err = p.exchange(header, sw[1], nil, shortLength(sw[1]))
+	}
+	return data, sw, err
+}
+
+// exchange sends the header with P3 and follows the procedure bytes, sending
+// the data or receiving the number of bytes expected, until the card sends
+// the status bytes.
+func (p *t0) exchange(header [4]byte, p3 byte, data []byte, expected int) ([]byte, [2]byte, error) {
+	var sw [2]byte
+	if _, err := p.port.Write(append(header[:], p3)); err != nil {
+		return nil, sw, err
+	}
+
+	ins := header[1]
+	var received []byte
+	procedure := make([]byte, 1)
+	for {
+		if _, err := io.ReadFull(p.port, procedure); err != nil {
+			return nil, sw, err
+		}
+		pb := procedure[0]
+		switch {
+		case pb == 0x60:
+			// NULL: the card asks for more time
+		case pb&0xF0 == 0x60 || pb&0xF0 == 0x90:
+			sw[0] = pb
+			if _, err := io.ReadFull(p.port, sw[1:]); err != nil {
+				return nil, sw, err
+			}
+			return received, sw, nil
+		case pb == ins || pb == ^ins:
+			count := len(data)
+			if expected > 0 {
+				count = expected - len(received)
+			}
+			if pb == ^ins {
+				count = min(count, 1)
+			}
+			if expected > 0 {
+				chunk := make([]byte, count)
+				if _, err := io.ReadFull(p.port, chunk); err != nil {
+					return nil, sw, err
+				}
+				received = append(received, chunk...)
+			} else {
+				if _, err := p.port.Write(data[:count]); err != nil {
+					return nil, sw, err
+				}
+				data = data[count:]
+			}
+		default:
+			return nil, sw, fmt.Errorf("%w: %02X", ErrProcedureByte, pb)
+		}
+	}
+}
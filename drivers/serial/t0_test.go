@@ -0,0 +1,61 @@
+package serial
+
+import (
+	"testing"
+
+	"github.com/mniak/apdu/internal/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestT0(t *testing.T) {
+	t.Run("Case 2 with wrong length", func(t *testing.T) {
+		port := scriptedCard(t,
+			exchange(t, "80CA9F1700", "6C03"),
+			exchange(t, "80CA9F1703", "CA 010203 9000"),
+		)
+		resp, err := NewT0(port).SendBytes(test.MustParseHex(t, "80CA9F1700"))
+		require.NoError(t, err)
+		test.AssertBytesEqual(t, "0102039000", resp)
+	})
+	t.Run("Case 3 byte by byte", func(t *testing.T) {
+		port := scriptedCard(t,
+			exchange(t, "00DA010102", "25"),
+			exchange(t, "AA", "60 25"),
+			exchange(t, "BB", "9000"),
+		)
+		resp, err := NewT0(port).SendBytes(test.MustParseHex(t, "00DA010102AABB"))
+		require.NoError(t, err)
+		test.AssertBytesEqual(t, "9000", resp)
+	})
+	t.Run("Case 4 with GET RESPONSE", func(t *testing.T) {
+		port := scriptedCard(t,
+			exchange(t, "00A4040007", "A4"),
+			exchange(t, "A0000000041010", "60 6106"),
+			exchange(t, "00C0000006", "C0 6F048402AABB 9000"),
+		)
+		resp, err := NewT0(port).SendBytes(test.MustParseHex(t, "00A4040007A000000004101000"))
+		require.NoError(t, err)
+		test.AssertBytesEqual(t, "6F048402AABB9000", resp)
+	})
+	t.Run("Case 4 with error", func(t *testing.T) {
+		port := scriptedCard(t,
+			exchange(t, "00A4040007", "A4"),
+			exchange(t, "A0000000041010", "6A82"),
+		)
+		resp, err := NewT0(port).SendBytes(test.MustParseHex(t, "00A4040007A000000004101000"))
+		require.NoError(t, err)
+		test.AssertBytesEqual(t, "6A82", resp)
+	})
+	t.Run("Unexpected procedure byte", func(t *testing.T) {
+		port := scriptedCard(t,
+			exchange(t, "00B2010C00", "12"),
+		)
+		_, err := NewT0(port).SendBytes(test.MustParseHex(t, "00B2010C00"))
+		assert.ErrorIs(t, err, ErrProcedureByte)
+	})
+	t.Run("Extended length", func(t *testing.T) {
+		_, err := NewT0(nil).SendBytes(test.MustParseHex(t, "00D60000000002AABB"))
+		assert.ErrorIs(t, err, ErrExtendedLength)
+	})
+}
@@ -0,0 +1,326 @@
+package serial
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/mniak/apdu"
+)
+
+var (
+	ErrInvalidBlock    = errors.New("invalid T=1 block")
+	ErrUnexpectedBlock = errors.New("unexpected T=1 block")
+	ErrAborted         = errors.New("the card aborted the chain")
+	ErrResynchronized  = errors.New("T=1 was resynchronized after transmission errors")
+
+	errWrongEDC = fmt.Errorf("%w: wrong EDC", ErrInvalidBlock)
+)
+
+// Bits of the protocol control byte (PCB)
+const (
+	pcbRBlock    = 0b1000_0000
+	pcbSBlock    = 0b1100_0000
+	pcbIMore     = 0b0010_0000
+	pcbSResponse = 0b0010_0000
+
+	sResynch = 0x00
+	sIFS     = 0x01
+	sAbort   = 0x02
+	sWTX     = 0x03
+
+	rEDCError   = 0x01
+	rOtherError = 0x02
+)
+
+const (
+	// defaultIFSD is the maximum information field size that the interface
+	// device can receive, announced to the card on the first exchange
+	defaultIFSD = 0xFE
+	maxAttempts = 3
+)
+
+// block is a T=1 block without the epilogue.
+type block struct {
+	nad byte
+	pcb byte
+	inf []byte
+}
+
+func (b block) isI() bool {
+	return b.pcb&0x80 == 0
+}
+
+func (b block) isR() bool {
+	return b.pcb&0xC0 == pcbRBlock
+}
+
+func (b block) isS() bool {
+	return b.pcb&0xC0 == pcbSBlock
+}
+
+// sequence returns N(S) of I-blocks or N(R) of R-blocks.
+func (b block) sequence() byte {
+	if b.isI() {
+		return b.pcb >> 6 & 1
+	}
+	return b.pcb >> 4 & 1
+}
+
+func (b block) String() string {
+	return fmt.Sprintf("[%02X %02X %2X]", b.nad, b.pcb, b.inf)
+}
+
+// lrc is the longitudinal redundancy check, the exclusive-or of the bytes.
+func lrc(data []byte) []byte {
+	var result byte
+	for _, b := range data {
+		result ^= b
+	}
+	return []byte{result}
+}
+
+// crc is the CRC of ISO/IEC 13239 with the polynomial x^16 + x^12 + x^5 + 1,
+// most significant byte first.
+func crc(data []byte) []byte {
+	value := uint16(0xFFFF)
+	for _, b := range data {
+		value ^= uint16(b)
+		for i := 0; i < 8; i++ {
+			if value&1 != 0 {
+				value = value>>1 ^ 0x8408
+			} else {
+				value >>= 1
+			}
+		}
+	}
+	return []byte{byte(value >> 8), byte(value)}
+}
+
+// t1 is the block oriented protocol T=1, defined in ISO/IEC 7816-3,
+// section 11.
+type t1 struct {
+	port   io.ReadWriter
+	params apdu.T1Parameters
+	ifsc   int
+	ifsd   int
+	// ns is the send sequence number of the interface device and nr the one
+	// expected from the card
+	ns, nr     byte
+	negotiated bool
+}
+
+func newT1(port io.ReadWriter, params apdu.T1Parameters) *t1 {
+	if params.IFSC == 0 {
+		params.IFSC = 32
+	}
+	return &t1{
+		port:   port,
+		params: params,
+		ifsc:   params.IFSC,
+		ifsd:   defaultIFSD,
+	}
+}
+
+func (p *t1) edc(data []byte) []byte {
+	if p.params.CRC {
+		return crc(data)
+	}
+	return lrc(data)
+}
+
+func (p *t1) write(b block) error {
+	data := append([]byte{b.nad, b.pcb, byte(len(b.inf))}, b.inf...)
+	_, err := p.port.Write(append(data, p.edc(data)...))
+	return err
+}
+
+// read receives a block. The errors of transmission are wrapped in
+// ErrInvalidBlock, while the errors of the port are returned as they are.
+func (p *t1) read() (block, error) {
+	prologue := make([]byte, 3)
+	if _, err := io.ReadFull(p.port, prologue); err != nil {
+		return block{}, err
+	}
+	rest := make([]byte, int(prologue[2])+len(p.edc(nil)))
+	if _, err := io.ReadFull(p.port, rest); err != nil {
+		return block{}, err
+	}
+	data := append(prologue, rest[:prologue[2]]...)
+	if string(p.edc(data)) != string(rest[prologue[2]:]) {
+		return block{}, errWrongEDC
+	}
+	result := block{nad: prologue[0], pcb: prologue[1], inf: data[3:]}
+	if prologue[2] == 0xFF || result.isR() && (len(result.inf) > 0 || result.pcb&0b0010_0000 != 0) {
+		return block{}, fmt.Errorf("%w: %s", ErrInvalidBlock, result)
+	}
+	return result, nil
+}
+
+// receive reads the next block, answering the requests of waiting time
+// extension and information field size of the card.
+func (p *t1) receive() (block, error) {
+	for {
+		b, err := p.read()
+		if err != nil || !b.isS() || b.pcb&pcbSResponse != 0 {
+			return b, err
+		}
+		switch b.pcb & 0x1F {
+		case sWTX:
+			// The port is expected to wait as long as the card needs
+		case sIFS:
+			if len(b.inf) != 1 || b.inf[0] == 0x00 || b.inf[0] == 0xFF {
+				return block{}, fmt.Errorf("%w: %s", ErrInvalidBlock, b)
+			}
+			p.ifsc = int(b.inf[0])
+		case sAbort:
+			if err := p.write(block{pcb: pcbSBlock | pcbSResponse | sAbort}); err != nil {
+				return block{}, err
+			}
+			return block{}, ErrAborted
+		default:
+			return b, nil
+		}
+		if err := p.write(block{pcb: b.pcb | pcbSResponse, inf: b.inf}); err != nil {
+			return block{}, err
+		}
+	}
+}
+
+// send writes the block and returns the reply of the card. The block is sent
+// again when the card indicates that it was not received and the card is
+// asked to send its reply again when it is invalid. After three attempts the
+// protocol is resynchronized.
+func (p *t1) send(b block) (block, error) {
+	out := b
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		if err := p.write(out); err != nil {
+			return block{}, err
+		}
+		reply, err := p.receive()
+		switch {
+		case errors.Is(err, ErrInvalidBlock):
+			code := byte(rOtherError)
+			if errors.Is(err, errWrongEDC) {
+				code = rEDCError
+			}
+			out = block{pcb: pcbRBlock | p.nr<<4 | code}
+		case err != nil:
+			return block{}, err
+		case b.isI() && reply.isR() && reply.sequence() == b.sequence():
+			out = b
+		default:
+			return reply, nil
+		}
+	}
+	if err := p.resynchronize(); err != nil {
+		return block{}, err
+	}
+	return block{}, ErrResynchronized
+}
+
+// resynchronize resets the sequence numbers and the information field sizes
+// with S(RESYNCH).
+func (p *t1) resynchronize() error {
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		if err := p.write(block{pcb: pcbSBlock | sResynch}); err != nil {
+			return err
+		}
+		reply, err := p.read()
+		if errors.Is(err, ErrInvalidBlock) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if reply.pcb == pcbSBlock|pcbSResponse|sResynch {
+			p.ns, p.nr = 0, 0
+			p.ifsc = p.params.IFSC
+			p.negotiated = false
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: the card did not answer S(RESYNCH)", ErrUnexpectedBlock)
+}
+
+// negotiate announces the information field size of the interface device.
+func (p *t1) negotiate() error {
+	request := block{pcb: pcbSBlock | sIFS, inf: []byte{byte(p.ifsd)}}
+	reply, err := p.send(request)
+	if err != nil {
+		return err
+	}
+	if reply.pcb != request.pcb|pcbSResponse || string(reply.inf) != string(request.inf) {
+		return fmt.Errorf("%w: %s in response to S(IFS request)", ErrUnexpectedBlock, reply)
+	}
+	p.negotiated = true
+	return nil
+}
+
+func (p *t1) transmit(command []byte) ([]byte, error) {
+	if len(command) == 0 {
+		return nil, ErrInvalidCommand
+	}
+	response, err := p.exchange(command)
+	if errors.Is(err, ErrResynchronized) {
+		// The command may not have been processed, so it is sent once more
+		response, err = p.exchange(command)
+	}
+	return response, err
+}
+
+// exchange sends the command chained in I-blocks of at most IFSC bytes and
+// receives the chain of the response.
+func (p *t1) exchange(command []byte) ([]byte, error) {
+	if !p.negotiated {
+		if err := p.negotiate(); err != nil {
+			return nil, err
+		}
+	}
+
+	var reply block
+	for len(command) > 0 {
+		size := min(len(command), p.ifsc)
+		i := block{pcb: p.ns << 6, inf: command[:size]}
+		more := size < len(command)
+		if more {
+			i.pcb |= pcbIMore
+		}
+
+		var err error
+		reply, err = p.send(i)
+		if err != nil {
+			return nil, err
+		}
+		if more {
+			if !reply.isR() || reply.sequence() == p.ns {
+				return nil, fmt.Errorf("%w: %s while chaining", ErrUnexpectedBlock, reply)
+			}
+			p.ns ^= 1
+		}
+		command = command[size:]
+	}
+	if !reply.isI() {
+		return nil, fmt.Errorf("%w: %s instead of the response", ErrUnexpectedBlock, reply)
+	}
+	// The first I-block of the card acknowledges the last one sent
+	p.ns ^= 1
+
+	var response []byte
+	for {
+		if !reply.isI() || reply.sequence() != p.nr {
+			return nil, fmt.Errorf("%w: %s in the response chain", ErrUnexpectedBlock, reply)
+		}
+		p.nr ^= 1
+		response = append(response, reply.inf...)
+		if reply.pcb&pcbIMore == 0 {
+			return response, nil
+		}
+
+		var err error
+		reply, err = p.send(block{pcb: pcbRBlock | p.nr<<4})
+		if err != nil {
+			return nil, err
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package serial
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/mniak/apdu"
+	"github.com/mniak/apdu/internal/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// withLRC appends the LRC to the block.
+func withLRC(t *testing.T, hex string) string {
+	data := test.MustParseHex(t, hex)
+	return fmt.Sprintf("%s %X", hex, lrc(data))
+}
+
+// blocks is a step of T=1 with the LRC of both blocks.
+func blocks(t *testing.T, expect, reply string) step {
+	s := exchange(t, withLRC(t, expect), "")
+	if reply != "" {
+		s.reply = test.MustParseHex(t, withLRC(t, reply))
+	}
+	return s
+}
+
+func TestCRC(t *testing.T) {
+	assert.Equal(t, []byte{0x6F, 0x91}, crc([]byte("123456789")))
+}
+
+func TestT1(t *testing.T) {
+	params := apdu.T1Parameters{IFSC: 32, BWI: 4, CWI: 13}
+	negotiation := func(t *testing.T) step {
+		return blocks(t, "00 C1 01 FE", "00 E1 01 FE")
+	}
+
+	t.Run("Sequence numbers", func(t *testing.T) {
+		port := scriptedCard(t,
+			negotiation(t),
+			blocks(t, "00 00 05 00B2010C00", "00 00 04 AABB9000"),
+			blocks(t, "00 40 05 00B2020C00", "00 40 04 CCDD9000"),
+		)
+		driver := NewT1(port, params)
+		resp, err := driver.SendBytes(test.MustParseHex(t, "00B2010C00"))
+		require.NoError(t, err)
+		test.AssertBytesEqual(t, "AABB9000", resp)
+
+		resp, err = driver.SendBytes(test.MustParseHex(t, "00B2020C00"))
+		require.NoError(t, err)
+		test.AssertBytesEqual(t, "CCDD9000", resp)
+	})
+	t.Run("Chaining", func(t *testing.T) {
+		port := scriptedCard(t,
+			negotiation(t),
+			blocks(t, "00 20 04 00A40400", "00 90 00"),
+			blocks(t, "00 60 04 07A00000", "00 80 00"),
+			blocks(t, "00 20 04 00041010", "00 90 00"),
+			blocks(t, "00 40 01 00", "00 20 02 6F02"),
+			blocks(t, "00 90 00", "00 40 04 84009000"),
+		)
+		resp, err := NewT1(port, apdu.T1Parameters{IFSC: 4}).SendBytes(test.MustParseHex(t, "00A4040007A000000004101000"))
+		require.NoError(t, err)
+		test.AssertBytesEqual(t, "6F0284009000", resp)
+	})
+	t.Run("Requests of the card", func(t *testing.T) {
+		port := scriptedCard(t,
+			negotiation(t),
+			blocks(t, "00 00 05 00B2010C00", "00 C3 01 02"),
+			blocks(t, "00 E3 01 02", "00 C1 01 10"),
+			blocks(t, "00 E1 01 10", "00 00 02 9000"),
+		)
+		resp, err := NewT1(port, params).SendBytes(test.MustParseHex(t, "00B2010C00"))
+		require.NoError(t, err)
+		test.AssertBytesEqual(t, "9000", resp)
+	})
+	t.Run("Transmission errors", func(t *testing.T) {
+		port := scriptedCard(t,
+			negotiation(t),
+			blocks(t, "00 00 05 00B2010C00", "00 81 00"),
+			exchange(t, withLRC(t, "00 00 05 00B2010C00"), "00 00 02 9000 FF"),
+			blocks(t, "00 81 00", "00 00 02 9000"),
+		)
+		resp, err := NewT1(port, params).SendBytes(test.MustParseHex(t, "00B2010C00"))
+		require.NoError(t, err)
+		test.AssertBytesEqual(t, "9000", resp)
+	})
+	t.Run("Resynchronization", func(t *testing.T) {
+		port := scriptedCard(t,
+			negotiation(t),
+			exchange(t, withLRC(t, "00 00 05 00B2010C00"), "00 00 02 9000 FF"),
+			exchange(t, withLRC(t, "00 81 00"), "00 00 02 9000 FF"),
+			exchange(t, withLRC(t, "00 81 00"), "00 00 02 9000 FF"),
+			blocks(t, "00 C0 00", "00 E0 00"),
+			negotiation(t),
+			blocks(t, "00 00 05 00B2010C00", "00 00 02 9000"),
+		)
+		resp, err := NewT1(port, params).SendBytes(test.MustParseHex(t, "00B2010C00"))
+		require.NoError(t, err)
+		test.AssertBytesEqual(t, "9000", resp)
+	})
+	t.Run("Abort", func(t *testing.T) {
+		port := scriptedCard(t,
+			negotiation(t),
+			blocks(t, "00 00 05 00B2010C00", "00 C2 00"),
+			blocks(t, "00 E2 00", ""),
+		)
+		_, err := NewT1(port, params).SendBytes(test.MustParseHex(t, "00B2010C00"))
+		assert.ErrorIs(t, err, ErrAborted)
+	})
+	t.Run("CRC", func(t *testing.T) {
+		withCRC := func(hex string) string {
+			data := test.MustParseHex(t, hex)
+			return fmt.Sprintf("%X", append(data, crc(data)...))
+		}
+		port := scriptedCard(t,
+			exchange(t, withCRC("00 C1 01 FE"), withCRC("00 E1 01 FE")),
+			exchange(t, withCRC("00 00 05 00B2010C00"), withCRC("00 00 02 9000")),
+		)
+		resp, err := NewT1(port, apdu.T1Parameters{IFSC: 32, CRC: true}).SendBytes(test.MustParseHex(t, "00B2010C00"))
+		require.NoError(t, err)
+		test.AssertBytesEqual(t, "9000", resp)
+	})
+}
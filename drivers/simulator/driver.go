@@ -0,0 +1,131 @@
+package simulator
+
+import (
+	"bytes"
+	"io"
+	"log"
+
+	"github.com/mniak/apdu"
+	"github.com/mniak/apdu/internal/noop"
+)
+
+// HandlerFunc responds to a command sent to the simulated card.
+type HandlerFunc func(cmd apdu.Command) apdu.Response
+
+type handlerKey struct {
+	class       apdu.Class
+	instruction apdu.Instruction
+}
+
+type recordKey struct {
+	sfi    int
+	record int
+}
+
+type application struct {
+	dfname []byte
+	fci    []byte
+}
+
+type driver struct {
+	applications []application
+	records      map[recordKey][]byte
+	handlers     map[handlerKey]HandlerFunc
+	commands     []apdu.Command
+	atr          []byte
+	logger       *log.Logger
+}
+
+// New creates a driver that simulates a card. SELECT by name and READ
+// RECORD are answered from the applications and records added, the other
+// commands by the handlers registered.
+func New() *driver {
+	return &driver{
+		records:  make(map[recordKey][]byte),
+		handlers: make(map[handlerKey]HandlerFunc),
+		logger:   noop.Logger(),
+	}
+}
+
+func (d *driver) LoggingTo(w io.Writer) *driver {
+	d.logger = log.New(w, "[simulator] ", 0)
+	return d
+}
+
+// AddApplication makes the DF name selectable, returning the FCI provided.
+func (d *driver) AddApplication(dfname, fci []byte) *driver {
+	d.applications = append(d.applications, application{dfname: dfname, fci: fci})
+	return d
+}
+
+func (d *driver) AddRecord(sfi, record int, data []byte) *driver {
+	d.records[recordKey{sfi, record}] = data
+	return d
+}
+
+func (d *driver) Handle(class apdu.Class, instruction apdu.Instruction, handler HandlerFunc) *driver {
+	d.handlers[handlerKey{class, instruction}] = handler
+	return d
+}
+
+// Respond registers a handler that always returns the same response.
+func (d *driver) Respond(class apdu.Class, instruction apdu.Instruction, resp apdu.Response) *driver {
+	return d.Handle(class, instruction, func(apdu.Command) apdu.Response {
+		return resp
+	})
+}
+
+// WithATR sets the Answer To Reset reported by the card.
+func (d *driver) WithATR(atr []byte) *driver {
+	d.atr = atr
+	return d
+}
+
+func (d *driver) ATR() ([]byte, error) {
+	return d.atr, nil
+}
+
+// Commands returns the commands received so far.
+func (d *driver) Commands() []apdu.Command {
+	return d.commands
+}
+
+func (d *driver) SendBytes(b []byte) ([]byte, error) {
+	d.logger.Printf("Data received: %2X\n", b)
+	resp := d.process(b)
+	r := resp.Bytes()
+	d.logger.Printf("Data sent: %2X\n", r)
+	return r, nil
+}
+
+func (d *driver) process(b []byte) apdu.Response {
+	cmd, err := apdu.ParseCommand(b)
+	if err != nil {
+		return apdu.Response{Trailer: apdu.Trailer(apdu.ErrWrongLength)}
+	}
+	d.commands = append(d.commands, cmd)
+
+	if handler, found := d.handlers[handlerKey{cmd.Class, cmd.Instruction}]; found {
+		return handler(cmd)
+	}
+	switch {
+	case cmd.Instruction == apdu.InstructionA4_Select && cmd.Parameters.P1 == 0x04:
+		for _, app := range d.applications {
+			if bytes.Equal(app.dfname, cmd.Data) {
+				return apdu.Response{Data: app.fci, Trailer: 0x9000}
+			}
+		}
+		return apdu.Response{Trailer: apdu.Trailer(apdu.ErrFileOrApplicationNotFound)}
+	case cmd.Instruction == apdu.InstructionB2_ReadRecords && cmd.Parameters.P2&0b111 == 0b100:
+		key := recordKey{
+			sfi:    int(cmd.Parameters.P2 >> 3),
+			record: int(cmd.Parameters.P1),
+		}
+		if record, found := d.records[key]; found {
+			return apdu.Response{Data: record, Trailer: 0x9000}
+		}
+		return apdu.Response{Trailer: apdu.Trailer(apdu.ErrRecordNotFound)}
+	default:
+		return apdu.Response{Trailer: apdu.Trailer(apdu.ErrInstructionNotSupported)}
+	}
+}
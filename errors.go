@@ -5,6 +5,12 @@ import (
 )
 
 const (
+	ErrVerificationFailed          TrailerError = 0x6300
+	ErrWrongLength                 TrailerError = 0x6700
+	ErrConditionsOfUseNotSatisfied TrailerError = 0x6985
+	ErrInstructionNotSupported     TrailerError = 0x6D00
+	ErrClassNotSupported           TrailerError = 0x6E00
+
 	ErrNoInformationGiven                       TrailerError = 0x6A00
 	ErrIncorrectParametersInTheCommandDataField TrailerError = 0x6A80
 	ErrFunctionNotSupported                     TrailerError = 0x6A81
@@ -0,0 +1,296 @@
+package globalplatform
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mniak/apdu"
+	"github.com/mniak/apdu/internal/ber"
+)
+
+// Tags of the data objects retrieved with GET DATA
+const (
+	TagCardProductionLifeCycle apdu.Tag = 0x9F7F
+	TagCardRecognitionData     apdu.Tag = 0x66
+	TagKeyInformation          apdu.Tag = 0xE0
+)
+
+// CPLCDate is a date of the CPLC in the format YDDD, with the last digit of
+// the year followed by the day of the year, all BCD encoded.
+type CPLCDate uint16
+
+func (d CPLCDate) Year() int {
+	return int(d >> 12)
+}
+
+func (d CPLCDate) DayOfYear() int {
+	return int(d>>8&0xF)*100 + int(d>>4&0xF)*10 + int(d&0xF)
+}
+
+func (d CPLCDate) Valid() bool {
+	for v := d; v > 0; v >>= 4 {
+		if v&0xF > 9 {
+			return false
+		}
+	}
+	return d.DayOfYear() >= 1 && d.DayOfYear() <= 366
+}
+
+// Time resolves the decade of the date as the latest one that does not put
+// the date after the reference.
+func (d CPLCDate) Time(reference time.Time) time.Time {
+	date := func(year int) time.Time {
+		return time.Date(year, time.January, d.DayOfYear(), 0, 0, 0, 0, time.UTC)
+	}
+	year := reference.Year() - reference.Year()%10 + d.Year()
+	if date(year).After(reference) {
+		year -= 10
+	}
+	return date(year)
+}
+
+func (d CPLCDate) String() string {
+	return fmt.Sprintf("%04X", uint16(d))
+}
+
+// CPLC is the Card Production Life Cycle data (9F7F), which identifies the
+// chip, its operating system and the parties that produced the card.
+type CPLC struct {
+	ICFabricator                      uint16
+	ICType                            uint16
+	OperatingSystemID                 uint16
+	OperatingSystemReleaseDate        CPLCDate
+	OperatingSystemReleaseLevel       uint16
+	ICFabricationDate                 CPLCDate
+	ICSerialNumber                    uint32
+	ICBatchIdentifier                 uint16
+	ICModuleFabricator                uint16
+	ICModulePackagingDate             CPLCDate
+	ICCManufacturer                   uint16
+	ICEmbeddingDate                   CPLCDate
+	ICPrePersonalizer                 uint16
+	ICPrePersonalizationEquipmentDate CPLCDate
+	ICPrePersonalizationEquipmentID   uint32
+	ICPersonalizer                    uint16
+	ICPersonalizationDate             CPLCDate
+	ICPersonalizationEquipmentID      uint32
+}
+
+var icFabricators = map[uint16]string{
+	0x4090: "Infineon",
+	0x4180: "Atmel",
+	0x4250: "Samsung",
+	0x4750: "STMicroelectronics",
+	0x4790: "NXP",
+}
+
+// ICFabricatorName returns the name of the IC fabricator, or an empty string
+// when it is not known.
+func (c CPLC) ICFabricatorName() string {
+	return icFabricators[c.ICFabricator]
+}
+
+// ParseCPLC decodes the CPLC, either with or without the 9F7F tag.
+func ParseCPLC(data []byte) (CPLC, error) {
+	var result CPLC
+	value, err := unwrapDataObject(TagCardProductionLifeCycle, data)
+	if err != nil {
+		return result, err
+	}
+	if len(value) != binary.Size(result) {
+		return result, fmt.Errorf("invalid CPLC length: %d", len(value))
+	}
+	err = binary.Read(bytes.NewReader(value), binary.BigEndian, &result)
+	return result, err
+}
+
+// unwrapDataObject returns the value of the data object when the data is
+// encoded with the tag, or the data itself otherwise.
+func unwrapDataObject(tag apdu.Tag, data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, tag.Bytes()) {
+		return data, nil
+	}
+	tlv, rest, err := ber.ParseOne(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) > 0 {
+		return nil, fmt.Errorf("unexpected data after tag %X", uint32(tag))
+	}
+	return tlv.Value, nil
+}
+
+// globalPlatformOID is the prefix of the object identifiers defined by
+// GlobalPlatform: {iso(1) member-body(2) us(840) globalPlatform(114283)}
+var globalPlatformOID = []int{1, 2, 840, 114283}
+
+func parseOID(data []byte) ([]int, error) {
+	if len(data) == 0 {
+		return nil, errors.New("empty object identifier")
+	}
+	var result []int
+	value := 0
+	for i, b := range data {
+		value = value<<7 | int(b&0x7F)
+		if b&0x80 != 0 {
+			if i == len(data)-1 {
+				return nil, errors.New("truncated object identifier")
+			}
+			continue
+		}
+		if len(result) == 0 {
+			first := min(value/40, 2)
+			result = append(result, first, value-first*40)
+		} else {
+			result = append(result, value)
+		}
+		value = 0
+	}
+	return result, nil
+}
+
+// globalPlatformArcs returns the arcs of a GlobalPlatform object identifier
+// after the prefix and the category.
+func globalPlatformArcs(oid []int, category int) ([]int, bool) {
+	prefix := append(append([]int{}, globalPlatformOID...), category)
+	if len(oid) < len(prefix) {
+		return nil, false
+	}
+	for i, arc := range prefix {
+		if oid[i] != arc {
+			return nil, false
+		}
+	}
+	return oid[len(prefix):], true
+}
+
+// SecureChannelProtocolOption is a Secure Channel Protocol supported by the
+// card with its implementation option "i".
+type SecureChannelProtocolOption struct {
+	Protocol byte
+	Option   byte
+}
+
+func (o SecureChannelProtocolOption) String() string {
+	return fmt.Sprintf("SCP%02X i=%02X", o.Protocol, o.Option)
+}
+
+// CardRecognitionData is the Card Recognition Data (66) that describes the
+// GlobalPlatform features of the card.
+type CardRecognitionData struct {
+	// GlobalPlatformVersion is the version of the card management type, like
+	// "2.2.1"
+	GlobalPlatformVersion  string
+	SecureChannelProtocols []SecureChannelProtocolOption
+	// CardConfigurationDetails and CardChipDetails are proprietary
+	CardConfigurationDetails []byte
+	CardChipDetails          []byte
+}
+
+func ParseCardRecognitionData(data []byte) (CardRecognitionData, error) {
+	var result CardRecognitionData
+	value, err := unwrapDataObject(TagCardRecognitionData, data)
+	if err != nil {
+		return result, err
+	}
+	tlvs, err := ber.Parse(value)
+	if err != nil {
+		return result, err
+	}
+	template, ok := ber.Find(tlvs, 0x73)
+	if !ok {
+		return result, errors.New("card recognition data template (73) not found")
+	}
+	children, err := template.Children()
+	if err != nil {
+		return result, err
+	}
+
+	for _, child := range children {
+		var oid []int
+		if child.Tag.Constructed() {
+			if t, ok := ber.Find([]ber.TLV{child}, 0x06); ok {
+				if oid, err = parseOID(t.Value); err != nil {
+					return result, err
+				}
+			}
+		}
+		switch child.Tag {
+		case 0x60:
+			if arcs, ok := globalPlatformArcs(oid, 2); ok {
+				versions := make([]string, len(arcs))
+				for i, arc := range arcs {
+					versions[i] = strconv.Itoa(arc)
+				}
+				result.GlobalPlatformVersion = strings.Join(versions, ".")
+			}
+		case 0x64:
+			if arcs, ok := globalPlatformArcs(oid, 4); ok && len(arcs) == 2 {
+				result.SecureChannelProtocols = append(result.SecureChannelProtocols, SecureChannelProtocolOption{
+					Protocol: byte(arcs[0]),
+					Option:   byte(arcs[1]),
+				})
+			}
+		case 0x65:
+			result.CardConfigurationDetails = child.Value
+		case 0x66:
+			result.CardChipDetails = child.Value
+		}
+	}
+	return result, nil
+}
+
+// KeyComponent is the type and length of a component of a key.
+type KeyComponent struct {
+	Type   KeyType
+	Length int
+}
+
+// KeyInformation describes a key of the Key Information Template (E0).
+type KeyInformation struct {
+	ID         byte
+	Version    byte
+	Components []KeyComponent
+}
+
+// ParseKeyInformation decodes the Key Information Template. Only the basic
+// format of the key information data (C0) is supported.
+func ParseKeyInformation(data []byte) ([]KeyInformation, error) {
+	value, err := unwrapDataObject(TagKeyInformation, data)
+	if err != nil {
+		return nil, err
+	}
+	tlvs, err := ber.Parse(value)
+	if err != nil {
+		return nil, err
+	}
+	var result []KeyInformation
+	for _, t := range tlvs {
+		if t.Tag != 0xC0 {
+			continue
+		}
+		if len(t.Value) < 2 || len(t.Value)%2 != 0 {
+			return nil, fmt.Errorf("invalid key information length: %d", len(t.Value))
+		}
+		key := KeyInformation{
+			ID:      t.Value[0],
+			Version: t.Value[1],
+		}
+		for i := 2; i < len(t.Value); i += 2 {
+			if t.Value[i] == 0xFF {
+				return nil, errors.New("extended key information is not supported")
+			}
+			key.Components = append(key.Components, KeyComponent{
+				Type:   KeyType(t.Value[i]),
+				Length: int(t.Value[i+1]),
+			})
+		}
+		result = append(result, key)
+	}
+	return result, nil
+}
@@ -0,0 +1,114 @@
+package globalplatform
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mniak/apdu"
+	"github.com/mniak/apdu/drivers/simulator"
+	"github.com/mniak/apdu/internal/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCPLC(t *testing.T) {
+	data := test.MustParseHex(t, "9F7F2A 4790 5040 4791 8102 3100 9164 12345678 0ABC 4812 9170 4815 9171 0000 0000 00000000 0000 0000 00000000")
+
+	cplc, err := ParseCPLC(data)
+	require.NoError(t, err)
+	assert.Equal(t, uint16(0x4790), cplc.ICFabricator)
+	assert.Equal(t, "NXP", cplc.ICFabricatorName())
+	assert.Equal(t, uint16(0x5040), cplc.ICType)
+	assert.Equal(t, uint16(0x4791), cplc.OperatingSystemID)
+	assert.Equal(t, CPLCDate(0x8102), cplc.OperatingSystemReleaseDate)
+	assert.Equal(t, uint16(0x3100), cplc.OperatingSystemReleaseLevel)
+	assert.Equal(t, uint32(0x12345678), cplc.ICSerialNumber)
+	assert.Equal(t, uint16(0x0ABC), cplc.ICBatchIdentifier)
+	assert.Equal(t, uint16(0x4815), cplc.ICCManufacturer)
+	assert.Equal(t, CPLCDate(0x9171), cplc.ICEmbeddingDate)
+
+	reference := time.Date(2026, time.October, 19, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t, time.Date(2018, time.April, 12, 0, 0, 0, 0, time.UTC), cplc.OperatingSystemReleaseDate.Time(reference))
+	assert.Equal(t, time.Date(2019, time.June, 13, 0, 0, 0, 0, time.UTC), cplc.ICFabricationDate.Time(reference))
+	assert.Equal(t, time.Date(2026, time.January, 5, 0, 0, 0, 0, time.UTC), CPLCDate(0x6005).Time(reference))
+	assert.True(t, cplc.ICFabricationDate.Valid())
+	assert.False(t, CPLCDate(0x0000).Valid())
+	assert.False(t, CPLCDate(0x1A01).Valid())
+
+	t.Run("Without tag", func(t *testing.T) {
+		untagged, err := ParseCPLC(data[3:])
+		require.NoError(t, err)
+		assert.Equal(t, cplc, untagged)
+	})
+	t.Run("Wrong length", func(t *testing.T) {
+		_, err := ParseCPLC(data[:20])
+		assert.Error(t, err)
+	})
+}
+
+func TestParseCardRecognitionData(t *testing.T) {
+	data := test.MustParseHex(t, "6659 7357"+
+		"06072A864886FC6B01"+
+		"600C060A2A864886FC6B02020101"+
+		"630906072A864886FC6B03"+
+		"640B06092A864886FC6B040215"+
+		"640B06092A864886FC6B040370"+
+		"650B06092B8510864864020103"+
+		"660C060A2B060104012A026E0102")
+
+	crd, err := ParseCardRecognitionData(data)
+	require.NoError(t, err)
+	assert.Equal(t, "2.1.1", crd.GlobalPlatformVersion)
+	assert.Equal(t, []SecureChannelProtocolOption{
+		{Protocol: 0x02, Option: 0x15},
+		{Protocol: 0x03, Option: 0x70},
+	}, crd.SecureChannelProtocols)
+	assert.Equal(t, "SCP02 i=15", crd.SecureChannelProtocols[0].String())
+	test.AssertBytesEqual(t, "06092B8510864864020103", crd.CardConfigurationDetails)
+	test.AssertBytesEqual(t, "060A2B060104012A026E0102", crd.CardChipDetails)
+}
+
+func TestParseOID(t *testing.T) {
+	oid, err := parseOID(test.MustParseHex(t, "2A864886FC6B040370"))
+	require.NoError(t, err)
+	assert.Equal(t, []int{1, 2, 840, 114283, 4, 3, 112}, oid)
+
+	_, err = parseOID(test.MustParseHex(t, "2A86"))
+	assert.Error(t, err)
+}
+
+func TestParseKeyInformation(t *testing.T) {
+	keys, err := ParseKeyInformation(test.MustParseHex(t, "E012 C00401018810 C00402018810 C00403018810"))
+	require.NoError(t, err)
+	require.Len(t, keys, 3)
+	for i, key := range keys {
+		assert.Equal(t, byte(i+1), key.ID)
+		assert.Equal(t, byte(0x01), key.Version)
+		assert.Equal(t, []KeyComponent{{Type: KeyTypeAES, Length: 16}}, key.Components)
+	}
+
+	_, err = ParseKeyInformation(test.MustParseHex(t, "E006 C004010AFF80"))
+	assert.Error(t, err)
+}
+
+func TestCardManager_GetCPLC(t *testing.T) {
+	card := simulator.New().
+		Handle(0x80, InstructionGetData, func(cmd apdu.Command) apdu.Response {
+			if cmd.Parameters != (apdu.Parameters{P1: 0x9F, P2: 0x7F}) {
+				return apdu.Response{Trailer: 0x6A88}
+			}
+			return apdu.Response{
+				Data:    test.MustParseHex(t, "9F7F2A 4090 7897 4091 5123 0100 5200 00112233 0001 4091 5201 4091 5202 0000 0000 00000000 0000 0000 00000000"),
+				Trailer: 0x9000,
+			}
+		})
+	manager := NewCardManager(apdu.NewRawClient(card))
+
+	cplc, err := manager.GetCPLC()
+	require.NoError(t, err)
+	assert.Equal(t, "Infineon", cplc.ICFabricatorName())
+	assert.Equal(t, uint32(0x00112233), cplc.ICSerialNumber)
+
+	_, err = manager.GetKeyInformation()
+	assert.True(t, apdu.IsTrailerError(err, apdu.ErrReferencedDataOrReferenceDataNotFound))
+}
@@ -0,0 +1,468 @@
+package globalplatform
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/sha1"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+
+	"github.com/mniak/apdu"
+	"github.com/mniak/apdu/internal/ber"
+	"github.com/mniak/apdu/internal/utils"
+	"github.com/mniak/apdu/javacard"
+)
+
+// StatusSubset selects the entries returned by GET STATUS, sent as P1.
+type StatusSubset byte
+
+const (
+	StatusIssuerSecurityDomain StatusSubset = 0x80
+	StatusApplications         StatusSubset = 0x40
+	StatusLoadFiles            StatusSubset = 0x20
+	StatusLoadFilesAndModules  StatusSubset = 0x10
+)
+
+// Life cycle states of the card, the applications and the load files
+const (
+	LifeCycleLoaded       byte = 0x01
+	LifeCycleInstalled    byte = 0x03
+	LifeCycleSelectable   byte = 0x07
+	LifeCyclePersonalized byte = 0x0F
+	LifeCycleLocked       byte = 0x83
+
+	LifeCycleCardOPReady     byte = 0x01
+	LifeCycleCardInitialized byte = 0x07
+	LifeCycleCardSecured     byte = 0x0F
+	LifeCycleCardLocked      byte = 0x7F
+	LifeCycleCardTerminated  byte = 0xFF
+)
+
+// KeyType is the algorithm of a key sent with PUT KEY.
+type KeyType byte
+
+const (
+	KeyTypeDES KeyType = 0x80
+	KeyTypeAES KeyType = 0x88
+)
+
+// KeyEncryptor is implemented by the secure channels, which encrypt the keys
+// sent with PUT KEY with their data encryption key.
+type KeyEncryptor interface {
+	EncryptKey(key []byte) (KeyType, []byte, error)
+}
+
+var ErrKeyCheckValueMismatch = errors.New("key check value returned by the card does not match")
+
+// ContentEntry is an entry of the GlobalPlatform registry returned by
+// GET STATUS.
+type ContentEntry struct {
+	Subset         StatusSubset
+	AID            []byte
+	LifeCycleState byte
+	Privileges     []byte
+	// LoadFile is the AID of the Executable Load File of an application
+	LoadFile []byte
+	// Modules are the AIDs of the Executable Modules of a load file
+	Modules        [][]byte
+	Version        []byte
+	SecurityDomain []byte
+}
+
+func parseContentEntries(subset StatusSubset, data []byte) ([]ContentEntry, error) {
+	tlvs, err := ber.Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	var result []ContentEntry
+	for _, t := range tlvs {
+		if t.Tag != 0xE3 {
+			continue
+		}
+		children, err := t.Children()
+		if err != nil {
+			return nil, err
+		}
+		entry := ContentEntry{Subset: subset}
+		for _, child := range children {
+			switch child.Tag {
+			case 0x4F:
+				entry.AID = child.Value
+			case 0x9F70:
+				if len(child.Value) > 0 {
+					entry.LifeCycleState = child.Value[0]
+				}
+			case 0xC5:
+				entry.Privileges = child.Value
+			case 0xC4:
+				entry.LoadFile = child.Value
+			case 0x84:
+				entry.Modules = append(entry.Modules, child.Value)
+			case 0xCE:
+				entry.Version = child.Value
+			case 0xCC:
+				entry.SecurityDomain = child.Value
+			}
+		}
+		result = append(result, entry)
+	}
+	return result, nil
+}
+
+// lengthValue encodes the fields of INSTALL, which are preceded by a single
+// length byte.
+func lengthValue(fields ...[]byte) ([]byte, error) {
+	var b bytes.Buffer
+	for _, f := range fields {
+		if len(f) > 0xFF {
+			return nil, fmt.Errorf("field is too long: %d bytes", len(f))
+		}
+		b.WriteByte(byte(len(f)))
+		b.Write(f)
+	}
+	return b.Bytes(), nil
+}
+
+// CardManager sends the card content management commands of GlobalPlatform
+// Card Specification, chapter 11, usually through a secure channel opened
+// with the Issuer Security Domain.
+type CardManager struct {
+	client    apdu.RawClient
+	blockSize int
+}
+
+func NewCardManager(client apdu.RawClient) *CardManager {
+	return &CardManager{
+		client:    client,
+		blockSize: 0xE0,
+	}
+}
+
+// WithLoadBlockSize changes the size of the blocks of the load file sent in
+// each LOAD command, which must leave room for the secure channel overhead.
+func (m *CardManager) WithLoadBlockSize(size int) *CardManager {
+	m.blockSize = size
+	return m
+}
+
+func (m *CardManager) send(instruction apdu.Instruction, p1, p2 byte, data []byte) ([]byte, error) {
+	resp, err := m.client.SendCommand(apdu.Command{
+		Class:       classGlobalPlatform,
+		Instruction: instruction,
+		Parameters: apdu.Parameters{
+			P1: p1,
+			P2: p2,
+		},
+		Data: data,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Data, resp.Trailer.GetError()
+}
+
+// GetStatus lists the registry entries of the subset. When the card answers
+// 6310 (more data available), the following entries are requested until the
+// last one is received.
+func (m *CardManager) GetStatus(subset StatusSubset) ([]ContentEntry, error) {
+	var result []ContentEntry
+	p2 := byte(0x02) // Response data in TLV format
+	for {
+		resp, err := m.client.SendCommand(apdu.Command{
+			Class:       classGlobalPlatform,
+			Instruction: InstructionGetStatus,
+			Parameters: apdu.Parameters{
+				P1: byte(subset),
+				P2: p2,
+			},
+			Data: []byte{0x4F, 0x00}, // Search criteria: all the AIDs
+		})
+		if err != nil {
+			return nil, err
+		}
+		if resp.Trailer != 0x6310 {
+			if err := resp.Trailer.GetError(); err != nil {
+				return nil, err
+			}
+		}
+		entries, err := parseContentEntries(subset, resp.Data)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, entries...)
+		if resp.Trailer != 0x6310 {
+			return result, nil
+		}
+		p2 |= 0x01 // Get next occurrence(s)
+	}
+}
+
+// GetData retrieves a data object of the Security Domain.
+func (m *CardManager) GetData(tag apdu.Tag) ([]byte, error) {
+	return m.send(InstructionGetData, byte(tag>>8), byte(tag), nil)
+}
+
+func (m *CardManager) GetCPLC() (CPLC, error) {
+	data, err := m.GetData(TagCardProductionLifeCycle)
+	if err != nil {
+		return CPLC{}, err
+	}
+	return ParseCPLC(data)
+}
+
+func (m *CardManager) GetCardRecognitionData() (CardRecognitionData, error) {
+	data, err := m.GetData(TagCardRecognitionData)
+	if err != nil {
+		return CardRecognitionData{}, err
+	}
+	return ParseCardRecognitionData(data)
+}
+
+func (m *CardManager) GetKeyInformation() ([]KeyInformation, error) {
+	data, err := m.GetData(TagKeyInformation)
+	if err != nil {
+		return nil, err
+	}
+	return ParseKeyInformation(data)
+}
+
+// ListContents returns the Issuer Security Domain, the applications and the
+// load files of the card. The modules of the load files are included when
+// the card supports it.
+func (m *CardManager) ListContents() ([]ContentEntry, error) {
+	var result []ContentEntry
+	for _, subset := range []StatusSubset{StatusIssuerSecurityDomain, StatusApplications} {
+		entries, err := m.GetStatus(subset)
+		if err != nil && !apdu.IsTrailerError(err, apdu.ErrReferencedDataOrReferenceDataNotFound) {
+			return nil, err
+		}
+		result = append(result, entries...)
+	}
+
+	entries, err := m.GetStatus(StatusLoadFilesAndModules)
+	if apdu.IsTrailerError(err, apdu.ErrIncorrectParametersP1P2) {
+		entries, err = m.GetStatus(StatusLoadFiles)
+	}
+	if err != nil && !apdu.IsTrailerError(err, apdu.ErrReferencedDataOrReferenceDataNotFound) {
+		return nil, err
+	}
+	return append(result, entries...), nil
+}
+
+// InstallForLoad prepares the card for the LOAD of a load file, which is
+// associated to the security domain, or to the ISD when it is empty.
+func (m *CardManager) InstallForLoad(loadFileAID, securityDomainAID, hash, parameters []byte) error {
+	data, err := lengthValue(loadFileAID, securityDomainAID, hash, parameters, nil)
+	if err != nil {
+		return err
+	}
+	_, err = m.send(InstructionInstall, 0x02, 0x00, data)
+	return err
+}
+
+// InstallForInstall creates an application from a module of a load file.
+// The parameters are the application specific parameters (tag C9) and the
+// application is made selectable when selectable is true.
+func (m *CardManager) InstallForInstall(loadFileAID, moduleAID, applicationAID, privileges, parameters []byte, selectable bool) error {
+	data, err := lengthValue(loadFileAID, moduleAID, applicationAID, privileges, ber.Encode(0xC9, parameters), nil)
+	if err != nil {
+		return err
+	}
+	p1 := byte(0x04)
+	if selectable {
+		p1 |= 0x08
+	}
+	_, err = m.send(InstructionInstall, p1, 0x00, data)
+	return err
+}
+
+// InstallForMakeSelectable makes an installed application selectable.
+func (m *CardManager) InstallForMakeSelectable(applicationAID, privileges []byte) error {
+	data, err := lengthValue(nil, nil, applicationAID, privileges, nil, nil)
+	if err != nil {
+		return err
+	}
+	_, err = m.send(InstructionInstall, 0x08, 0x00, data)
+	return err
+}
+
+// InstallForPersonalization directs the following STORE DATA commands to
+// the application.
+func (m *CardManager) InstallForPersonalization(applicationAID []byte) error {
+	data, err := lengthValue(nil, nil, applicationAID, nil, nil, nil)
+	if err != nil {
+		return err
+	}
+	_, err = m.send(InstructionInstall, 0x20, 0x00, data)
+	return err
+}
+
+// Load sends the load file, which is the Load File Data Block (tag C4)
+// optionally preceded by DAP blocks, split in numbered blocks.
+func (m *CardManager) Load(loadFile []byte) error {
+	if m.blockSize <= 0 || m.blockSize > 0xFF {
+		return fmt.Errorf("invalid load block size: %d", m.blockSize)
+	}
+	blocks := (len(loadFile) + m.blockSize - 1) / m.blockSize
+	if blocks > 0x100 {
+		return fmt.Errorf("load file is too large: %d blocks", blocks)
+	}
+	for i := 0; i < blocks; i++ {
+		block := loadFile[i*m.blockSize : min((i+1)*m.blockSize, len(loadFile))]
+		p1 := byte(0x00) // More blocks
+		if i == blocks-1 {
+			p1 = 0x80 // Last block
+		}
+		if _, err := m.send(InstructionLoad, p1, byte(i), block); err != nil {
+			return fmt.Errorf("LOAD of block %d failed: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// Delete removes an application or a load file, and also its related
+// objects when related is true.
+func (m *CardManager) Delete(aid []byte, related bool) error {
+	p2 := byte(0x00)
+	if related {
+		p2 = 0x80
+	}
+	_, err := m.send(InstructionDelete, 0x00, p2, ber.Encode(0x4F, aid))
+	return err
+}
+
+// SetStatus changes the life cycle state of the ISD (the card) or of an
+// application.
+func (m *CardManager) SetStatus(subset StatusSubset, aid []byte, state byte) error {
+	_, err := m.send(InstructionSetStatus, byte(subset), state, aid)
+	return err
+}
+
+func keyCheckValue(keyType KeyType, key []byte) ([]byte, error) {
+	switch keyType {
+	case KeyTypeDES:
+		block, err := utils.NewTripleDES(key)
+		if err != nil {
+			return nil, err
+		}
+		kcv := make([]byte, 8)
+		block.Encrypt(kcv, kcv)
+		return kcv[:3], nil
+	case KeyTypeAES:
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		kcv := bytes.Repeat([]byte{0x01}, aes.BlockSize)
+		block.Encrypt(kcv, kcv)
+		return kcv[:3], nil
+	}
+	return nil, fmt.Errorf("unsupported key type: %02X", byte(keyType))
+}
+
+// PutKeys adds the key set (when oldVersion is zero) or replaces the key set
+// of version oldVersion. The keys are encrypted by the secure channel, so
+// the client of the card manager must be a KeyEncryptor.
+func (m *CardManager) PutKeys(oldVersion byte, keys StaticKeys) error {
+	encryptor, ok := m.client.(KeyEncryptor)
+	if !ok {
+		return errors.New("PUT KEY requires a secure channel")
+	}
+
+	data := []byte{keys.Version}
+	var checkValues []byte
+	for _, key := range [][]byte{keys.ENC, keys.MAC, keys.DEK} {
+		keyType, encrypted, err := encryptor.EncryptKey(key)
+		if err != nil {
+			return err
+		}
+		kcv, err := keyCheckValue(keyType, key)
+		if err != nil {
+			return err
+		}
+		value := encrypted
+		if keyType == KeyTypeAES {
+			value = append([]byte{byte(len(key))}, encrypted...)
+		}
+		data = append(data, byte(keyType), byte(len(value)))
+		data = append(data, value...)
+		data = append(data, byte(len(kcv)))
+		data = append(data, kcv...)
+		checkValues = append(checkValues, kcv...)
+	}
+
+	resp, err := m.send(InstructionPutKey, oldVersion, 0x81, data)
+	if err != nil {
+		return err
+	}
+	if len(resp) > 0 {
+		expected := append([]byte{keys.Version}, checkValues...)
+		if subtle.ConstantTimeCompare(expected, resp) != 1 {
+			return ErrKeyCheckValueMismatch
+		}
+	}
+	return nil
+}
+
+// AppletInstallation describes an applet to be installed from a load file.
+type AppletInstallation struct {
+	// LoadFile is the load file sent with LOAD, including tag C4
+	LoadFile    []byte
+	LoadFileAID []byte
+	ModuleAID   []byte
+	// ApplicationAID defaults to the module AID when empty
+	ApplicationAID []byte
+	Privileges     []byte
+	Parameters     []byte
+}
+
+func (m *CardManager) load(loadFileAID, hash, loadFile []byte) error {
+	if err := m.InstallForLoad(loadFileAID, nil, hash, nil); err != nil {
+		return fmt.Errorf("INSTALL [for load] failed: %w", err)
+	}
+	return m.Load(loadFile)
+}
+
+func (m *CardManager) install(loadFileAID, moduleAID, applicationAID, privileges, parameters []byte) error {
+	if len(applicationAID) == 0 {
+		applicationAID = moduleAID
+	}
+	if privileges == nil {
+		privileges = []byte{0x00}
+	}
+	if err := m.InstallForInstall(loadFileAID, moduleAID, applicationAID, privileges, parameters, true); err != nil {
+		return fmt.Errorf("INSTALL [for install and make selectable] failed: %w", err)
+	}
+	return nil
+}
+
+// InstallApplet loads the load file and installs an application from it,
+// which is made selectable.
+func (m *CardManager) InstallApplet(applet AppletInstallation) error {
+	if err := m.load(applet.LoadFileAID, nil, applet.LoadFile); err != nil {
+		return err
+	}
+	return m.install(applet.LoadFileAID, applet.ModuleAID, applet.ApplicationAID, applet.Privileges, applet.Parameters)
+}
+
+// InstallCAP loads the package of the CAP file and installs each of its
+// applets with the same privileges and parameters. The DAP blocks are only
+// needed when a Security Domain with the DAP verification privilege must
+// authorize the load, in which case the SHA-1 hash of the Load File Data
+// Block is also sent.
+func (m *CardManager) InstallCAP(cap *javacard.CAPFile, privileges, parameters []byte, daps ...javacard.DAPBlock) error {
+	var hash []byte
+	if len(daps) > 0 {
+		hash = cap.Hash(sha1.New())
+	}
+	if err := m.load(cap.PackageAID, hash, cap.LoadFile(daps...)); err != nil {
+		return err
+	}
+	for _, applet := range cap.Applets {
+		if err := m.install(cap.PackageAID, applet.AID, applet.AID, privileges, parameters); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,198 @@
+package globalplatform
+
+import (
+	"testing"
+
+	"github.com/mniak/apdu"
+	"github.com/mniak/apdu/drivers/simulator"
+	"github.com/mniak/apdu/internal/test"
+	"github.com/mniak/apdu/javacard"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCardManager_GetStatus(t *testing.T) {
+	pages := []apdu.Response{
+		{Data: test.MustParseHex(t, "E310 4F07A0000000031010 9F700107 C50100"), Trailer: 0x6310},
+		{Data: test.MustParseHex(t, "E319 4F07A0000000041010 9F70010F C50100 C407A0000000040000"), Trailer: 0x9000},
+	}
+	card := simulator.New().
+		Handle(0x80, InstructionGetStatus, func(cmd apdu.Command) apdu.Response {
+			resp := pages[0]
+			pages = pages[1:]
+			return resp
+		})
+
+	entries, err := NewCardManager(apdu.NewRawClient(card)).GetStatus(StatusApplications)
+	require.NoError(t, err)
+	assert.Equal(t, []ContentEntry{
+		{
+			Subset:         StatusApplications,
+			AID:            test.MustParseHex(t, "A0000000031010"),
+			LifeCycleState: LifeCycleSelectable,
+			Privileges:     []byte{0x00},
+		},
+		{
+			Subset:         StatusApplications,
+			AID:            test.MustParseHex(t, "A0000000041010"),
+			LifeCycleState: LifeCyclePersonalized,
+			Privileges:     []byte{0x00},
+			LoadFile:       test.MustParseHex(t, "A0000000040000"),
+		},
+	}, entries)
+
+	commands := card.Commands()
+	require.Len(t, commands, 2)
+	assert.Equal(t, apdu.Parameters{P1: 0x40, P2: 0x02}, commands[0].Parameters)
+	assert.Equal(t, apdu.Parameters{P1: 0x40, P2: 0x03}, commands[1].Parameters)
+	test.AssertBytesEqual(t, "4F00", commands[1].Data)
+}
+
+func TestCardManager_ListContents(t *testing.T) {
+	card := simulator.New().
+		Handle(0x80, InstructionGetStatus, func(cmd apdu.Command) apdu.Response {
+			switch StatusSubset(cmd.Parameters.P1) {
+			case StatusIssuerSecurityDomain:
+				return apdu.Response{Data: test.MustParseHex(t, "E30E 4F08A000000151000000 9F70010F"), Trailer: 0x9000}
+			case StatusLoadFiles:
+				return apdu.Response{Data: test.MustParseHex(t, "E30D 4F07A0000000620001 9F700101"), Trailer: 0x9000}
+			case StatusApplications:
+				return apdu.Response{Trailer: 0x6A88}
+			}
+			return apdu.Response{Trailer: 0x6A86}
+		})
+
+	entries, err := NewCardManager(apdu.NewRawClient(card)).ListContents()
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, StatusIssuerSecurityDomain, entries[0].Subset)
+	test.AssertBytesEqual(t, "A000000151000000", entries[0].AID)
+	assert.Equal(t, StatusLoadFiles, entries[1].Subset)
+	test.AssertBytesEqual(t, "A0000000620001", entries[1].AID)
+	assert.Equal(t, LifeCycleLoaded, entries[1].LifeCycleState)
+}
+
+func TestCardManager_InstallApplet(t *testing.T) {
+	card := simulator.New().
+		Respond(0x80, InstructionInstall, apdu.Response{Data: []byte{0x00}, Trailer: 0x9000}).
+		Respond(0x80, InstructionLoad, apdu.Response{Data: []byte{0x00}, Trailer: 0x9000})
+
+	err := NewCardManager(apdu.NewRawClient(card)).
+		WithLoadBlockSize(4).
+		InstallApplet(AppletInstallation{
+			LoadFile:    test.MustParseHex(t, "C4080102030405060708 AA"),
+			LoadFileAID: test.MustParseHex(t, "A00000006203"),
+			ModuleAID:   test.MustParseHex(t, "A0000000620301"),
+			Parameters:  test.MustParseHex(t, "0102"),
+		})
+	require.NoError(t, err)
+
+	commands := card.Commands()
+	require.Len(t, commands, 5)
+	assert.Equal(t, InstructionInstall, commands[0].Instruction)
+	assert.Equal(t, byte(0x02), commands[0].Parameters.P1)
+	test.AssertBytesEqual(t, "06A0000000620300000000", commands[0].Data)
+
+	for i, expected := range []string{"C4080102", "03040506", "0708AA"} {
+		cmd := commands[1+i]
+		assert.Equal(t, InstructionLoad, cmd.Instruction)
+		assert.Equal(t, byte(i), cmd.Parameters.P2)
+		test.AssertBytesEqual(t, expected, cmd.Data)
+	}
+	assert.Equal(t, byte(0x00), commands[2].Parameters.P1)
+	assert.Equal(t, byte(0x80), commands[3].Parameters.P1)
+
+	assert.Equal(t, InstructionInstall, commands[4].Instruction)
+	assert.Equal(t, byte(0x0C), commands[4].Parameters.P1)
+	test.AssertBytesEqual(t, "06A0000000620307A000000062030107A0000000620301010004C902010200", commands[4].Data)
+}
+
+func TestCardManager_Commands(t *testing.T) {
+	card := simulator.New().
+		Respond(0x80, InstructionDelete, apdu.Response{Data: []byte{0x00}, Trailer: 0x9000}).
+		Respond(0x80, InstructionSetStatus, apdu.Response{Trailer: 0x9000})
+	manager := NewCardManager(apdu.NewRawClient(card))
+
+	require.NoError(t, manager.Delete(test.MustParseHex(t, "A00000006203"), true))
+	require.NoError(t, manager.SetStatus(StatusApplications, test.MustParseHex(t, "A0000000620301"), LifeCycleLocked))
+
+	commands := card.Commands()
+	require.Len(t, commands, 2)
+	assert.Equal(t, apdu.Parameters{P1: 0x00, P2: 0x80}, commands[0].Parameters)
+	test.AssertBytesEqual(t, "4F06A00000006203", commands[0].Data)
+	assert.Equal(t, apdu.Parameters{P1: 0x40, P2: 0x83}, commands[1].Parameters)
+	test.AssertBytesEqual(t, "A0000000620301", commands[1].Data)
+}
+
+// plaintextKeys is a client that "encrypts" keys without changing them, so
+// that the PUT KEY data can be checked.
+type plaintextKeys struct {
+	apdu.RawClient
+}
+
+func (plaintextKeys) EncryptKey(key []byte) (KeyType, []byte, error) {
+	return KeyTypeAES, key, nil
+}
+
+func TestCardManager_PutKeys(t *testing.T) {
+	key := test.MustParseHex(t, "2B7E151628AED2A6ABF7158809CF4F3C")
+	keys := StaticKeys{ENC: key, MAC: key, DEK: key, Version: 0x31}
+	kcv, err := keyCheckValue(KeyTypeAES, key)
+	require.NoError(t, err)
+
+	t.Run("Success", func(t *testing.T) {
+		var data []byte
+		card := simulator.New().
+			Handle(0x80, InstructionPutKey, func(cmd apdu.Command) apdu.Response {
+				data = cmd.Data
+				return apdu.Response{
+					Data:    append([]byte{0x31}, append(append(kcv, kcv...), kcv...)...),
+					Trailer: 0x9000,
+				}
+			})
+		err := NewCardManager(plaintextKeys{apdu.NewRawClient(card)}).PutKeys(0x30, keys)
+		require.NoError(t, err)
+
+		keyData := append(append([]byte{0x88, 0x11, 0x10}, key...), 0x03)
+		keyData = append(keyData, kcv...)
+		expected := append([]byte{0x31}, keyData...)
+		expected = append(expected, keyData...)
+		expected = append(expected, keyData...)
+		assert.Equal(t, expected, data)
+		assert.Equal(t, apdu.Parameters{P1: 0x30, P2: 0x81}, card.Commands()[0].Parameters)
+	})
+	t.Run("Wrong check value", func(t *testing.T) {
+		card := simulator.New().
+			Respond(0x80, InstructionPutKey, apdu.Response{Data: test.MustParseHex(t, "31000000000000000000"), Trailer: 0x9000})
+		err := NewCardManager(plaintextKeys{apdu.NewRawClient(card)}).PutKeys(0x30, keys)
+		assert.ErrorIs(t, err, ErrKeyCheckValueMismatch)
+	})
+	t.Run("Without secure channel", func(t *testing.T) {
+		err := NewCardManager(apdu.NewRawClient(simulator.New())).PutKeys(0x30, keys)
+		assert.Error(t, err)
+	})
+}
+
+func TestKeyCheckValue(t *testing.T) {
+	kcv, err := keyCheckValue(KeyTypeDES, DefaultTestKeys().ENC)
+	require.NoError(t, err)
+	test.AssertBytesEqual(t, "8BAF47", kcv)
+}
+
+func TestCardManager_InstallCAP(t *testing.T) {
+	cap, err := javacard.OpenCAP("../javacard/testdata/hello.cap")
+	require.NoError(t, err)
+
+	card := simulator.New().
+		Respond(0x80, InstructionInstall, apdu.Response{Data: []byte{0x00}, Trailer: 0x9000}).
+		Respond(0x80, InstructionLoad, apdu.Response{Data: []byte{0x00}, Trailer: 0x9000})
+	err = NewCardManager(apdu.NewRawClient(card)).InstallCAP(cap, nil, nil)
+	require.NoError(t, err)
+
+	commands := card.Commands()
+	require.Len(t, commands, 3)
+	test.AssertBytesEqual(t, "09A00000006203010C0100000000", commands[0].Data)
+	assert.Equal(t, apdu.Parameters{P1: 0x80, P2: 0x00}, commands[1].Parameters)
+	assert.Equal(t, cap.LoadFile(), commands[1].Data)
+	test.AssertBytesEqual(t, "09A00000006203010C010AA00000006203010C01010AA00000006203010C0101010002C90000", commands[2].Data)
+}
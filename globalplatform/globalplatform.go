@@ -0,0 +1,95 @@
+package globalplatform
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/mniak/apdu"
+)
+
+const classGlobalPlatform apdu.Class = 0x80
+
+// Commands defined in GlobalPlatform Card Specification, chapter 11
+const (
+	InstructionDelete               apdu.Instruction = 0xE4
+	InstructionExternalAuthenticate apdu.Instruction = 0x82
+	InstructionGetData              apdu.Instruction = 0xCA
+	InstructionGetStatus            apdu.Instruction = 0xF2
+	InstructionInitializeUpdate     apdu.Instruction = 0x50
+	InstructionInstall              apdu.Instruction = 0xE6
+	InstructionLoad                 apdu.Instruction = 0xE8
+	InstructionPutKey               apdu.Instruction = 0xD8
+	InstructionSetStatus            apdu.Instruction = 0xF0
+	InstructionStoreData            apdu.Instruction = 0xE2
+)
+
+var (
+	ErrCardCryptogramMismatch = errors.New("card cryptogram does not match")
+	ErrCardChallengeMismatch  = errors.New("pseudo-random card challenge does not match")
+	ErrUnsupportedProtocol    = errors.New("secure channel protocol is not supported")
+)
+
+// SecurityLevel is the protection applied to the commands and responses
+// after the mutual authentication, sent as P1 of EXTERNAL AUTHENTICATE.
+type SecurityLevel byte
+
+const (
+	SecurityLevelNone        SecurityLevel = 0x00
+	SecurityLevelCMAC        SecurityLevel = 0x01
+	SecurityLevelCDecryption SecurityLevel = 0x02
+	SecurityLevelRMAC        SecurityLevel = 0x10
+	SecurityLevelREncryption SecurityLevel = 0x20
+)
+
+func (l SecurityLevel) Has(other SecurityLevel) bool {
+	return l&other == other
+}
+
+// StaticKeys are the keys of a Security Domain key set.
+type StaticKeys struct {
+	ENC []byte
+	MAC []byte
+	DEK []byte
+	// Version is the key version number sent in INITIALIZE UPDATE. Zero
+	// selects the first available key set.
+	Version byte
+}
+
+// DefaultTestKeys returns the well known key set 404142...4F that cards are
+// usually issued with.
+func DefaultTestKeys() StaticKeys {
+	key := make([]byte, 16)
+	for i := range key {
+		key[i] = 0x40 + byte(i)
+	}
+	return StaticKeys{
+		ENC: key,
+		MAC: key,
+		DEK: key,
+	}
+}
+
+func randomChallenge(r io.Reader, length int) ([]byte, error) {
+	challenge := make([]byte, length)
+	if _, err := io.ReadFull(r, challenge); err != nil {
+		return nil, fmt.Errorf("failed to generate host challenge: %w", err)
+	}
+	return challenge, nil
+}
+
+func initializeUpdate(client apdu.RawClient, keyVersion byte, hostChallenge []byte) ([]byte, error) {
+	resp, err := client.SendCommand(apdu.Command{
+		Class:       classGlobalPlatform,
+		Instruction: InstructionInitializeUpdate,
+		Parameters: apdu.Parameters{
+			P1: keyVersion,
+			P2: 0x00,
+		},
+		Data: hostChallenge,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Data, resp.Trailer.GetError()
+}
@@ -0,0 +1,268 @@
+package globalplatform
+
+import (
+	"crypto/des"
+	"crypto/rand"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/mniak/apdu"
+	"github.com/mniak/apdu/internal/utils"
+)
+
+// Derivation constants of the SCP02 session keys
+var (
+	scp02ConstantCMAC = []byte{0x01, 0x01}
+	scp02ConstantRMAC = []byte{0x01, 0x02}
+	scp02ConstantDEK  = []byte{0x01, 0x81}
+	scp02ConstantENC  = []byte{0x01, 0x82}
+)
+
+// SCP02 opens secure channels with the Secure Channel Protocol '02' as
+// defined in GlobalPlatform Card Specification, Appendix E, with the
+// implementation option i=15: C-MAC on the modified APDU, ICV set to zero
+// and encrypted for the following commands, and three static keys.
+type SCP02 struct {
+	client apdu.RawClient
+	keys   StaticKeys
+	random io.Reader
+}
+
+func NewSCP02(client apdu.RawClient, keys StaticKeys) *SCP02 {
+	return &SCP02{
+		client: client,
+		keys:   keys,
+		random: rand.Reader,
+	}
+}
+
+// WithRandom replaces the source of the host challenge.
+func (s *SCP02) WithRandom(r io.Reader) *SCP02 {
+	s.random = r
+	return s
+}
+
+// SCP02InitializeUpdateResponse is the response of INITIALIZE UPDATE for
+// SCP02.
+type SCP02InitializeUpdateResponse struct {
+	KeyDiversificationData []byte
+	KeyVersion             byte
+	Protocol               byte
+	SequenceCounter        []byte
+	CardChallenge          []byte
+	CardCryptogram         []byte
+}
+
+func ParseSCP02InitializeUpdateResponse(data []byte) (SCP02InitializeUpdateResponse, error) {
+	if len(data) != 28 {
+		return SCP02InitializeUpdateResponse{}, fmt.Errorf("invalid INITIALIZE UPDATE response length: %d", len(data))
+	}
+	return SCP02InitializeUpdateResponse{
+		KeyDiversificationData: data[:10],
+		KeyVersion:             data[10],
+		Protocol:               data[11],
+		SequenceCounter:        data[12:14],
+		CardChallenge:          data[14:20],
+		CardCryptogram:         data[20:28],
+	}, nil
+}
+
+// SCP02SessionKeys are the keys derived from the static keys for a session.
+type SCP02SessionKeys struct {
+	CMAC []byte
+	RMAC []byte
+	ENC  []byte
+	DEK  []byte
+}
+
+func deriveSCP02SessionKey(staticKey, constant, sequenceCounter []byte) ([]byte, error) {
+	block, err := utils.NewTripleDES(staticKey)
+	if err != nil {
+		return nil, err
+	}
+	data := make([]byte, 16)
+	copy(data, constant)
+	copy(data[2:], sequenceCounter)
+	return utils.EncryptCBC(block, make([]byte, 8), data)
+}
+
+// DeriveSCP02SessionKeys derives the session keys from the static keys and
+// the sequence counter returned by INITIALIZE UPDATE.
+func DeriveSCP02SessionKeys(keys StaticKeys, sequenceCounter []byte) (SCP02SessionKeys, error) {
+	if len(sequenceCounter) != 2 {
+		return SCP02SessionKeys{}, fmt.Errorf("invalid sequence counter length: %d", len(sequenceCounter))
+	}
+	var result SCP02SessionKeys
+	derivations := []struct {
+		target   *[]byte
+		key      []byte
+		constant []byte
+	}{
+		{&result.CMAC, keys.MAC, scp02ConstantCMAC},
+		{&result.RMAC, keys.MAC, scp02ConstantRMAC},
+		{&result.ENC, keys.ENC, scp02ConstantENC},
+		{&result.DEK, keys.DEK, scp02ConstantDEK},
+	}
+	for _, d := range derivations {
+		key, err := deriveSCP02SessionKey(d.key, d.constant, sequenceCounter)
+		if err != nil {
+			return result, err
+		}
+		*d.target = key
+	}
+	return result, nil
+}
+
+// fullTripleDESMAC computes the MAC used for the SCP02 cryptograms: the
+// last block of the 3DES CBC encryption of the padded data.
+func fullTripleDESMAC(key []byte, data ...[]byte) ([]byte, error) {
+	block, err := utils.NewTripleDES(key)
+	if err != nil {
+		return nil, err
+	}
+	var input []byte
+	for _, d := range data {
+		input = append(input, d...)
+	}
+	encrypted, err := utils.EncryptCBC(block, make([]byte, 8), utils.Pad80(input, false))
+	if err != nil {
+		return nil, err
+	}
+	return encrypted[len(encrypted)-8:], nil
+}
+
+// Open performs the mutual authentication with INITIALIZE UPDATE and
+// EXTERNAL AUTHENTICATE, returning a client that protects the following
+// commands with the security level requested. R-MAC is not supported.
+func (s *SCP02) Open(level SecurityLevel) (apdu.RawClient, error) {
+	if level.Has(SecurityLevelRMAC) || level.Has(SecurityLevelREncryption) {
+		return nil, fmt.Errorf("unsupported SCP02 security level: %02X", byte(level))
+	}
+	if level.Has(SecurityLevelCDecryption) && !level.Has(SecurityLevelCMAC) {
+		return nil, errors.New("C-DECRYPTION requires C-MAC")
+	}
+
+	hostChallenge, err := randomChallenge(s.random, 8)
+	if err != nil {
+		return nil, err
+	}
+	data, err := initializeUpdate(s.client, s.keys.Version, hostChallenge)
+	if err != nil {
+		return nil, fmt.Errorf("INITIALIZE UPDATE failed: %w", err)
+	}
+	init, err := ParseSCP02InitializeUpdateResponse(data)
+	if err != nil {
+		return nil, err
+	}
+	if init.Protocol != 0x02 {
+		return nil, fmt.Errorf("%w: %02X", ErrUnsupportedProtocol, init.Protocol)
+	}
+
+	sessionKeys, err := DeriveSCP02SessionKeys(s.keys, init.SequenceCounter)
+	if err != nil {
+		return nil, err
+	}
+	cardCryptogram, err := fullTripleDESMAC(sessionKeys.ENC, hostChallenge, init.SequenceCounter, init.CardChallenge)
+	if err != nil {
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare(cardCryptogram, init.CardCryptogram) != 1 {
+		return nil, ErrCardCryptogramMismatch
+	}
+	hostCryptogram, err := fullTripleDESMAC(sessionKeys.ENC, init.SequenceCounter, init.CardChallenge, hostChallenge)
+	if err != nil {
+		return nil, err
+	}
+
+	channel := &scp02Channel{
+		client: s.client,
+		keys:   sessionKeys,
+		level:  SecurityLevelCMAC,
+	}
+	resp, err := channel.SendCommand(apdu.Command{
+		Class:       classGlobalPlatform,
+		Instruction: InstructionExternalAuthenticate,
+		Parameters: apdu.Parameters{
+			P1: byte(level),
+			P2: 0x00,
+		},
+		Data: hostCryptogram,
+	})
+	if err == nil {
+		err = resp.Trailer.GetError()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("EXTERNAL AUTHENTICATE failed: %w", err)
+	}
+	channel.level = level
+	return channel, nil
+}
+
+type scp02Channel struct {
+	client apdu.RawClient
+	keys   SCP02SessionKeys
+	level  SecurityLevel
+	// icv is the C-MAC of the previous command
+	icv []byte
+}
+
+func (c *scp02Channel) cmac(data []byte) ([]byte, error) {
+	icv := make([]byte, 8)
+	if c.icv != nil {
+		block, err := des.NewCipher(c.keys.CMAC[:8])
+		if err != nil {
+			return nil, err
+		}
+		block.Encrypt(icv, c.icv)
+	}
+	padded := utils.Pad80(data, false)
+	utils.XORInPlace(padded[:8], icv)
+	return utils.RetailMAC(c.keys.CMAC, padded)
+}
+
+func (c *scp02Channel) wrap(cmd apdu.Command) (apdu.Command, error) {
+	if !c.level.Has(SecurityLevelCMAC) {
+		return cmd, nil
+	}
+	if len(cmd.Data) > 0xFF-16 {
+		return cmd, errors.New("command data is too long for the secure channel")
+	}
+
+	protected := cmd
+	protected.Class = cmd.Class | 0b0000_0100
+	header := []byte{byte(protected.Class), byte(cmd.Instruction), cmd.Parameters.P1, cmd.Parameters.P2, byte(len(cmd.Data) + 8)}
+	mac, err := c.cmac(append(header, cmd.Data...))
+	if err != nil {
+		return cmd, err
+	}
+	c.icv = mac
+
+	data := cmd.Data
+	if c.level.Has(SecurityLevelCDecryption) && len(data) > 0 {
+		block, err := utils.NewTripleDES(c.keys.ENC)
+		if err != nil {
+			return cmd, err
+		}
+		if data, err = utils.EncryptCBC(block, make([]byte, 8), utils.Pad80(data, false)); err != nil {
+			return cmd, err
+		}
+	}
+	protected.Data = append(append([]byte{}, data...), mac...)
+	return protected, nil
+}
+
+// EncryptKey encrypts a DES key with the DEK session key in ECB mode.
+func (c *scp02Channel) EncryptKey(key []byte) (KeyType, []byte, error) {
+	encrypted, err := utils.EncryptTripleDESECB(c.keys.DEK, key)
+	return KeyTypeDES, encrypted, err
+}
+
+func (c *scp02Channel) SendCommand(cmd apdu.Command) (apdu.Response, error) {
+	protected, err := c.wrap(cmd)
+	if err != nil {
+		return apdu.Response{}, err
+	}
+	return c.client.SendCommand(protected)
+}
@@ -0,0 +1,179 @@
+package globalplatform
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/des"
+	"testing"
+
+	"github.com/mniak/apdu"
+	"github.com/mniak/apdu/drivers/simulator"
+	"github.com/mniak/apdu/internal/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// scp02Card implements the card side of SCP02 with the standard library
+// only, so that the client is verified against an independent computation.
+type scp02Card struct {
+	t               *testing.T
+	keys            StaticKeys
+	sequenceCounter []byte
+	cardChallenge   []byte
+
+	hostChallenge []byte
+	sessionENC    []byte
+	sessionCMAC   []byte
+	level         SecurityLevel
+	lastMAC       []byte
+	received      [][]byte
+}
+
+func tripleDES(t *testing.T, key []byte) cipher.Block {
+	block, err := des.NewTripleDESCipher(append(append([]byte{}, key...), key[:8]...))
+	require.NoError(t, err)
+	return block
+}
+
+func pad80(data []byte) []byte {
+	padded := append(append([]byte{}, data...), 0x80)
+	for len(padded)%8 != 0 {
+		padded = append(padded, 0x00)
+	}
+	return padded
+}
+
+func cbc(block cipher.Block, iv, data []byte) []byte {
+	result := make([]byte, len(data))
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(result, data)
+	return result
+}
+
+func (c *scp02Card) sessionKey(static []byte, constant ...byte) []byte {
+	data := make([]byte, 16)
+	copy(data, constant)
+	copy(data[2:], c.sequenceCounter)
+	return cbc(tripleDES(c.t, static), make([]byte, 8), data)
+}
+
+func (c *scp02Card) cryptogram(parts ...[]byte) []byte {
+	encrypted := cbc(tripleDES(c.t, c.sessionENC), make([]byte, 8), pad80(bytes.Join(parts, nil)))
+	return encrypted[len(encrypted)-8:]
+}
+
+func (c *scp02Card) verifyCMAC(cmd apdu.Command, data []byte) bool {
+	mac := cmd.Data[len(cmd.Data)-8:]
+	icv := make([]byte, 8)
+	if c.lastMAC != nil {
+		single, err := des.NewCipher(c.sessionCMAC[:8])
+		require.NoError(c.t, err)
+		single.Encrypt(icv, c.lastMAC)
+	}
+	input := append([]byte{byte(cmd.Class), byte(cmd.Instruction), cmd.Parameters.P1, cmd.Parameters.P2, byte(len(data) + 8)}, data...)
+	single, err := des.NewCipher(c.sessionCMAC[:8])
+	require.NoError(c.t, err)
+	right, err := des.NewCipher(c.sessionCMAC[8:])
+	require.NoError(c.t, err)
+	chained := cbc(single, icv, pad80(input))
+	expected := chained[len(chained)-8:]
+	right.Decrypt(expected, expected)
+	single.Encrypt(expected, expected)
+	c.lastMAC = mac
+	return bytes.Equal(expected, mac)
+}
+
+func (c *scp02Card) simulate() apdu.Driver {
+	return simulator.New().
+		Handle(0x80, InstructionInitializeUpdate, func(cmd apdu.Command) apdu.Response {
+			c.hostChallenge = cmd.Data
+			c.sessionENC = c.sessionKey(c.keys.ENC, 0x01, 0x82)
+			c.sessionCMAC = c.sessionKey(c.keys.MAC, 0x01, 0x01)
+			data := bytes.Join([][]byte{
+				test.MustParseHex(c.t, "00010203040506070809"),
+				{0x20, 0x02},
+				c.sequenceCounter,
+				c.cardChallenge,
+				c.cryptogram(c.hostChallenge, c.sequenceCounter, c.cardChallenge),
+			}, nil)
+			return apdu.Response{Data: data, Trailer: 0x9000}
+		}).
+		Handle(0x84, InstructionExternalAuthenticate, func(cmd apdu.Command) apdu.Response {
+			if len(cmd.Data) != 16 || !c.verifyCMAC(cmd, cmd.Data[:8]) {
+				return apdu.Response{Trailer: 0x6982}
+			}
+			if !bytes.Equal(cmd.Data[:8], c.cryptogram(c.sequenceCounter, c.cardChallenge, c.hostChallenge)) {
+				return apdu.Response{Trailer: 0x6300}
+			}
+			c.level = SecurityLevel(cmd.Parameters.P1)
+			return apdu.Response{Trailer: 0x9000}
+		}).
+		Handle(0x84, InstructionGetStatus, func(cmd apdu.Command) apdu.Response {
+			data := cmd.Data[:len(cmd.Data)-8]
+			if c.level.Has(SecurityLevelCDecryption) {
+				decrypted := make([]byte, len(data))
+				cipher.NewCBCDecrypter(tripleDES(c.t, c.sessionENC), make([]byte, 8)).CryptBlocks(decrypted, data)
+				data = decrypted[:bytes.LastIndexByte(decrypted, 0x80)]
+			}
+			if !c.verifyCMAC(cmd, data) {
+				return apdu.Response{Trailer: 0x6982}
+			}
+			c.received = append(c.received, data)
+			return apdu.Response{Trailer: 0x9000}
+		})
+}
+
+func newSCP02Card(t *testing.T) *scp02Card {
+	return &scp02Card{
+		t:               t,
+		keys:            DefaultTestKeys(),
+		sequenceCounter: test.MustParseHex(t, "002A"),
+		cardChallenge:   test.MustParseHex(t, "A1B2C3D4E5F6"),
+	}
+}
+
+func TestSCP02(t *testing.T) {
+	getStatus := apdu.Command{
+		Class:       classGlobalPlatform,
+		Instruction: InstructionGetStatus,
+		Parameters:  apdu.Parameters{P1: 0x80, P2: 0x00},
+		Data:        test.MustParseHex(t, "4F00"),
+	}
+
+	levels := map[string]SecurityLevel{
+		"C-MAC":                  SecurityLevelCMAC,
+		"C-MAC and C-DECRYPTION": SecurityLevelCMAC | SecurityLevelCDecryption,
+	}
+	for name, level := range levels {
+		t.Run(name, func(t *testing.T) {
+			card := newSCP02Card(t)
+			scp := NewSCP02(apdu.NewRawClient(card.simulate()), DefaultTestKeys()).
+				WithRandom(bytes.NewReader(test.MustParseHex(t, "0102030405060708")))
+
+			channel, err := scp.Open(level)
+			require.NoError(t, err)
+			test.AssertBytesEqual(t, "0102030405060708", card.hostChallenge)
+			assert.Equal(t, level, card.level)
+
+			for i := 0; i < 2; i++ {
+				resp, err := channel.SendCommand(getStatus)
+				require.NoError(t, err)
+				assert.Equal(t, apdu.Trailer(0x9000), resp.Trailer)
+			}
+			assert.Equal(t, [][]byte{getStatus.Data, getStatus.Data}, card.received)
+		})
+	}
+}
+
+func TestSCP02_WrongKeys(t *testing.T) {
+	card := newSCP02Card(t)
+	card.keys.ENC = test.MustParseHex(t, "00112233445566778899AABBCCDDEEFF")
+
+	_, err := NewSCP02(apdu.NewRawClient(card.simulate()), DefaultTestKeys()).Open(SecurityLevelCMAC)
+	assert.ErrorIs(t, err, ErrCardCryptogramMismatch)
+}
+
+func TestSCP02_UnsupportedLevel(t *testing.T) {
+	card := newSCP02Card(t)
+	_, err := NewSCP02(apdu.NewRawClient(card.simulate()), DefaultTestKeys()).Open(SecurityLevelCMAC | SecurityLevelRMAC)
+	assert.Error(t, err)
+}
@@ -0,0 +1,380 @@
+package globalplatform
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/mniak/apdu"
+	"github.com/mniak/apdu/internal/utils"
+)
+
+// Derivation constants of the SCP03 key derivation function
+const (
+	scp03ConstantCardCryptogram byte = 0x00
+	scp03ConstantHostCryptogram byte = 0x01
+	scp03ConstantCardChallenge  byte = 0x02
+	scp03ConstantSENC           byte = 0x04
+	scp03ConstantSMAC           byte = 0x06
+	scp03ConstantSRMAC          byte = 0x07
+)
+
+// Bits of the SCP03 implementation option "i"
+const (
+	SCP03PseudoRandomCardChallenge byte = 0x10
+	SCP03RMACSupported             byte = 0x20
+	SCP03RENCSupported             byte = 0x40
+)
+
+// SCP03 opens secure channels with the Secure Channel Protocol '03' as
+// defined in GlobalPlatform Card Specification, Amendment D. The options
+// i=00, 10 and 70 are supported, as indicated by the card in INITIALIZE
+// UPDATE.
+type SCP03 struct {
+	client         apdu.RawClient
+	keys           StaticKeys
+	random         io.Reader
+	securityDomain []byte
+}
+
+func NewSCP03(client apdu.RawClient, keys StaticKeys) *SCP03 {
+	return &SCP03{
+		client: client,
+		keys:   keys,
+		random: rand.Reader,
+	}
+}
+
+// WithRandom replaces the source of the host challenge.
+func (s *SCP03) WithRandom(r io.Reader) *SCP03 {
+	s.random = r
+	return s
+}
+
+// WithSecurityDomain sets the AID of the selected Security Domain, which
+// enables the verification of pseudo-random card challenges.
+func (s *SCP03) WithSecurityDomain(aid []byte) *SCP03 {
+	s.securityDomain = aid
+	return s
+}
+
+// SCP03InitializeUpdateResponse is the response of INITIALIZE UPDATE for
+// SCP03. The sequence counter is only present when the card challenge is
+// pseudo-random.
+type SCP03InitializeUpdateResponse struct {
+	KeyDiversificationData []byte
+	KeyVersion             byte
+	Protocol               byte
+	Parameter              byte
+	CardChallenge          []byte
+	CardCryptogram         []byte
+	SequenceCounter        []byte
+}
+
+func ParseSCP03InitializeUpdateResponse(data []byte) (SCP03InitializeUpdateResponse, error) {
+	if len(data) != 29 && len(data) != 32 {
+		return SCP03InitializeUpdateResponse{}, fmt.Errorf("invalid INITIALIZE UPDATE response length: %d", len(data))
+	}
+	result := SCP03InitializeUpdateResponse{
+		KeyDiversificationData: data[:10],
+		KeyVersion:             data[10],
+		Protocol:               data[11],
+		Parameter:              data[12],
+		CardChallenge:          data[13:21],
+		CardCryptogram:         data[21:29],
+	}
+	if len(data) == 32 {
+		result.SequenceCounter = data[29:32]
+	}
+	return result, nil
+}
+
+// scp03KDF is the NIST SP 800-108 key derivation function in counter mode
+// with AES CMAC as the pseudo-random function.
+func scp03KDF(key []byte, constant byte, bits int, context []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	input := make([]byte, 16, 16+len(context))
+	input[11] = constant
+	binary.BigEndian.PutUint16(input[13:], uint16(bits))
+	input = append(input, context...)
+
+	var result []byte
+	for counter := byte(1); len(result)*8 < bits; counter++ {
+		input[15] = counter
+		result = append(result, utils.CMAC(block, input)...)
+	}
+	return result[:bits/8], nil
+}
+
+// SCP03SessionKeys are the keys derived from the static keys for a session.
+type SCP03SessionKeys struct {
+	ENC  []byte
+	MAC  []byte
+	RMAC []byte
+}
+
+// DeriveSCP03SessionKeys derives the session keys from the static keys and
+// the host challenge followed by the card challenge.
+func DeriveSCP03SessionKeys(keys StaticKeys, context []byte) (SCP03SessionKeys, error) {
+	var result SCP03SessionKeys
+	derivations := []struct {
+		target   *[]byte
+		key      []byte
+		constant byte
+	}{
+		{&result.ENC, keys.ENC, scp03ConstantSENC},
+		{&result.MAC, keys.MAC, scp03ConstantSMAC},
+		{&result.RMAC, keys.MAC, scp03ConstantSRMAC},
+	}
+	for _, d := range derivations {
+		key, err := scp03KDF(d.key, d.constant, len(d.key)*8, context)
+		if err != nil {
+			return result, err
+		}
+		*d.target = key
+	}
+	return result, nil
+}
+
+func (s *SCP03) checkLevel(level SecurityLevel, parameter byte) error {
+	switch level {
+	case SecurityLevelNone,
+		SecurityLevelCMAC,
+		SecurityLevelCMAC | SecurityLevelCDecryption:
+	case SecurityLevelCMAC | SecurityLevelRMAC,
+		SecurityLevelCMAC | SecurityLevelCDecryption | SecurityLevelRMAC:
+		if parameter&SCP03RMACSupported == 0 {
+			return fmt.Errorf("card does not support R-MAC (i=%02X)", parameter)
+		}
+	case SecurityLevelCMAC | SecurityLevelCDecryption | SecurityLevelRMAC | SecurityLevelREncryption:
+		if parameter&SCP03RENCSupported == 0 {
+			return fmt.Errorf("card does not support R-ENCRYPTION (i=%02X)", parameter)
+		}
+	default:
+		return fmt.Errorf("unsupported SCP03 security level: %02X", byte(level))
+	}
+	return nil
+}
+
+// Open performs the mutual authentication with INITIALIZE UPDATE and
+// EXTERNAL AUTHENTICATE, returning a client that protects the following
+// commands and responses with the security level requested.
+func (s *SCP03) Open(level SecurityLevel) (apdu.RawClient, error) {
+	hostChallenge, err := randomChallenge(s.random, 8)
+	if err != nil {
+		return nil, err
+	}
+	data, err := initializeUpdate(s.client, s.keys.Version, hostChallenge)
+	if err != nil {
+		return nil, fmt.Errorf("INITIALIZE UPDATE failed: %w", err)
+	}
+	init, err := ParseSCP03InitializeUpdateResponse(data)
+	if err != nil {
+		return nil, err
+	}
+	if init.Protocol != 0x03 {
+		return nil, fmt.Errorf("%w: %02X", ErrUnsupportedProtocol, init.Protocol)
+	}
+	if err := s.checkLevel(level, init.Parameter); err != nil {
+		return nil, err
+	}
+
+	if init.Parameter&SCP03PseudoRandomCardChallenge != 0 && s.securityDomain != nil {
+		if init.SequenceCounter == nil {
+			return nil, errors.New("sequence counter is missing")
+		}
+		expected, err := scp03KDF(s.keys.ENC, scp03ConstantCardChallenge, 64, append(append([]byte{}, init.SequenceCounter...), s.securityDomain...))
+		if err != nil {
+			return nil, err
+		}
+		if subtle.ConstantTimeCompare(expected, init.CardChallenge) != 1 {
+			return nil, ErrCardChallengeMismatch
+		}
+	}
+
+	context := append(append([]byte{}, hostChallenge...), init.CardChallenge...)
+	sessionKeys, err := DeriveSCP03SessionKeys(s.keys, context)
+	if err != nil {
+		return nil, err
+	}
+	cardCryptogram, err := scp03KDF(sessionKeys.MAC, scp03ConstantCardCryptogram, 64, context)
+	if err != nil {
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare(cardCryptogram, init.CardCryptogram) != 1 {
+		return nil, ErrCardCryptogramMismatch
+	}
+	hostCryptogram, err := scp03KDF(sessionKeys.MAC, scp03ConstantHostCryptogram, 64, context)
+	if err != nil {
+		return nil, err
+	}
+
+	channel, err := newSCP03Channel(s.client, sessionKeys)
+	if err != nil {
+		return nil, err
+	}
+	channel.dek = s.keys.DEK
+	channel.level = SecurityLevelCMAC
+	resp, err := channel.SendCommand(apdu.Command{
+		Class:       classGlobalPlatform,
+		Instruction: InstructionExternalAuthenticate,
+		Parameters: apdu.Parameters{
+			P1: byte(level),
+			P2: 0x00,
+		},
+		Data: hostCryptogram,
+	})
+	if err == nil {
+		err = resp.Trailer.GetError()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("EXTERNAL AUTHENTICATE failed: %w", err)
+	}
+	channel.level = level
+	return channel, nil
+}
+
+type scp03Channel struct {
+	client apdu.RawClient
+	enc    cipher.Block
+	mac    cipher.Block
+	rmac   cipher.Block
+	dek    []byte
+	level  SecurityLevel
+	// chaining is the full C-MAC of the previous command
+	chaining []byte
+	// counter is the encryption counter, incremented for each command
+	// after the mutual authentication
+	counter uint64
+}
+
+func newSCP03Channel(client apdu.RawClient, keys SCP03SessionKeys) (*scp03Channel, error) {
+	enc, err := aes.NewCipher(keys.ENC)
+	if err != nil {
+		return nil, err
+	}
+	mac, err := aes.NewCipher(keys.MAC)
+	if err != nil {
+		return nil, err
+	}
+	rmac, err := aes.NewCipher(keys.RMAC)
+	if err != nil {
+		return nil, err
+	}
+	return &scp03Channel{
+		client:   client,
+		enc:      enc,
+		mac:      mac,
+		rmac:     rmac,
+		chaining: make([]byte, aes.BlockSize),
+	}, nil
+}
+
+// iv computes the initial vector of the encryption of the command data, or
+// of the response data when the first byte of the counter block is 80.
+func (c *scp03Channel) iv(first byte) []byte {
+	block := make([]byte, aes.BlockSize)
+	block[0] = first
+	binary.BigEndian.PutUint64(block[8:], c.counter)
+	c.enc.Encrypt(block, block)
+	return block
+}
+
+func (c *scp03Channel) wrap(cmd apdu.Command) (apdu.Command, error) {
+	if !c.level.Has(SecurityLevelCMAC) {
+		return cmd, nil
+	}
+
+	data := cmd.Data
+	if c.level.Has(SecurityLevelCDecryption) {
+		c.counter++
+		if len(data) > 0 {
+			var err error
+			if data, err = utils.EncryptCBC(c.enc, c.iv(0x00), utils.Pad80Block(data, aes.BlockSize)); err != nil {
+				return cmd, err
+			}
+		}
+	}
+	if len(data) > 0xFF-8 {
+		return cmd, errors.New("command data is too long for the secure channel")
+	}
+
+	protected := cmd
+	protected.Class = cmd.Class | 0b0000_0100
+	input := append([]byte{}, c.chaining...)
+	input = append(input, byte(protected.Class), byte(cmd.Instruction), cmd.Parameters.P1, cmd.Parameters.P2, byte(len(data)+8))
+	input = append(input, data...)
+	c.chaining = utils.CMAC(c.mac, input)
+
+	protected.Data = append(append([]byte{}, data...), c.chaining[:8]...)
+	return protected, nil
+}
+
+// unwrap verifies the R-MAC and decrypts the response data. Error responses
+// without data are returned unchanged, since the card does not protect them.
+func (c *scp03Channel) unwrap(resp apdu.Response) (apdu.Response, error) {
+	if !c.level.Has(SecurityLevelRMAC) {
+		return resp, nil
+	}
+	if len(resp.Data) == 0 && resp.Trailer.GetError() != nil {
+		return resp, nil
+	}
+	if len(resp.Data) < 8 {
+		return resp, apdu.ErrSecureMessagingMissing
+	}
+
+	data := resp.Data[:len(resp.Data)-8]
+	input := append([]byte{}, c.chaining...)
+	input = append(input, data...)
+	input = append(input, byte(resp.Trailer>>8), byte(resp.Trailer))
+	expected := utils.CMAC(c.rmac, input)[:8]
+	if subtle.ConstantTimeCompare(expected, resp.Data[len(data):]) != 1 {
+		return resp, apdu.ErrInvalidResponseMAC
+	}
+
+	result := apdu.Response{
+		Data:    data,
+		Trailer: resp.Trailer,
+	}
+	if c.level.Has(SecurityLevelREncryption) && len(data) > 0 {
+		decrypted, err := utils.DecryptCBC(c.enc, c.iv(0x80), data)
+		if err != nil {
+			return result, err
+		}
+		if result.Data, err = utils.Unpad80(decrypted); err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+// EncryptKey encrypts an AES key with the static DEK in CBC mode with a zero
+// IV.
+func (c *scp03Channel) EncryptKey(key []byte) (KeyType, []byte, error) {
+	block, err := aes.NewCipher(c.dek)
+	if err != nil {
+		return KeyTypeAES, nil, err
+	}
+	encrypted, err := utils.EncryptCBC(block, make([]byte, aes.BlockSize), key)
+	return KeyTypeAES, encrypted, err
+}
+
+func (c *scp03Channel) SendCommand(cmd apdu.Command) (apdu.Response, error) {
+	protected, err := c.wrap(cmd)
+	if err != nil {
+		return apdu.Response{}, err
+	}
+	resp, err := c.client.SendCommand(protected)
+	if err != nil {
+		return resp, err
+	}
+	return c.unwrap(resp)
+}
@@ -0,0 +1,226 @@
+package globalplatform
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"testing"
+
+	"github.com/mniak/apdu"
+	"github.com/mniak/apdu/drivers/simulator"
+	"github.com/mniak/apdu/internal/test"
+	"github.com/mniak/apdu/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// scp03Card implements the card side of SCP03 apart from the client, only
+// sharing the AES CMAC primitive.
+type scp03Card struct {
+	t               *testing.T
+	keys            StaticKeys
+	parameter       byte
+	sequenceCounter []byte
+	securityDomain  []byte
+	statusData      []byte
+	tamperRMAC      bool
+
+	context  []byte
+	sENC     []byte
+	sMAC     []byte
+	sRMAC    []byte
+	level    SecurityLevel
+	chaining []byte
+	counter  uint64
+	received [][]byte
+}
+
+func aesCMAC(t *testing.T, key []byte, data ...[]byte) []byte {
+	block, err := aes.NewCipher(key)
+	require.NoError(t, err)
+	return utils.CMAC(block, bytes.Join(data, nil))
+}
+
+func (c *scp03Card) kdf(key []byte, constant byte, bits int, context []byte) []byte {
+	var result []byte
+	for i := byte(1); len(result)*8 < bits; i++ {
+		label := append(make([]byte, 11), constant, 0x00, byte(bits>>8), byte(bits), i)
+		result = append(result, aesCMAC(c.t, key, label, context)...)
+	}
+	return result[:bits/8]
+}
+
+func (c *scp03Card) counterIV(first byte) []byte {
+	block, err := aes.NewCipher(c.sENC)
+	require.NoError(c.t, err)
+	iv := make([]byte, 16)
+	iv[0] = first
+	binary.BigEndian.PutUint64(iv[8:], c.counter)
+	block.Encrypt(iv, iv)
+	return iv
+}
+
+func (c *scp03Card) verifyCMAC(cmd apdu.Command) ([]byte, bool) {
+	data := cmd.Data[:len(cmd.Data)-8]
+	header := []byte{byte(cmd.Class), byte(cmd.Instruction), cmd.Parameters.P1, cmd.Parameters.P2, byte(len(cmd.Data))}
+	c.chaining = aesCMAC(c.t, c.sMAC, c.chaining, header, data)
+	return data, bytes.Equal(c.chaining[:8], cmd.Data[len(data):])
+}
+
+func (c *scp03Card) simulate() apdu.Driver {
+	return simulator.New().
+		Handle(0x80, InstructionInitializeUpdate, func(cmd apdu.Command) apdu.Response {
+			cardChallenge := test.MustParseHex(c.t, "1122334455667788")
+			if c.parameter&SCP03PseudoRandomCardChallenge != 0 {
+				cardChallenge = c.kdf(c.keys.ENC, 0x02, 64, append(append([]byte{}, c.sequenceCounter...), c.securityDomain...))
+			}
+			c.context = append(append([]byte{}, cmd.Data...), cardChallenge...)
+			c.sENC = c.kdf(c.keys.ENC, 0x04, 128, c.context)
+			c.sMAC = c.kdf(c.keys.MAC, 0x06, 128, c.context)
+			c.sRMAC = c.kdf(c.keys.MAC, 0x07, 128, c.context)
+			c.chaining = make([]byte, 16)
+
+			data := bytes.Join([][]byte{
+				test.MustParseHex(c.t, "00010203040506070809"),
+				{0x30, 0x03, c.parameter},
+				cardChallenge,
+				c.kdf(c.sMAC, 0x00, 64, c.context),
+			}, nil)
+			if c.parameter&SCP03PseudoRandomCardChallenge != 0 {
+				data = append(data, c.sequenceCounter...)
+			}
+			return apdu.Response{Data: data, Trailer: 0x9000}
+		}).
+		Handle(0x84, InstructionExternalAuthenticate, func(cmd apdu.Command) apdu.Response {
+			data, ok := c.verifyCMAC(cmd)
+			if !ok {
+				return apdu.Response{Trailer: 0x6982}
+			}
+			if !bytes.Equal(data, c.kdf(c.sMAC, 0x01, 64, c.context)) {
+				return apdu.Response{Trailer: 0x6300}
+			}
+			c.level = SecurityLevel(cmd.Parameters.P1)
+			return apdu.Response{Trailer: 0x9000}
+		}).
+		Handle(0x84, InstructionGetStatus, func(cmd apdu.Command) apdu.Response {
+			if c.level.Has(SecurityLevelCDecryption) {
+				c.counter++
+			}
+			data, ok := c.verifyCMAC(cmd)
+			if !ok {
+				return apdu.Response{Trailer: 0x6982}
+			}
+			if c.level.Has(SecurityLevelCDecryption) && len(data) > 0 {
+				block, err := aes.NewCipher(c.sENC)
+				require.NoError(c.t, err)
+				decrypted := make([]byte, len(data))
+				cipher.NewCBCDecrypter(block, c.counterIV(0x00)).CryptBlocks(decrypted, data)
+				data = decrypted[:bytes.LastIndexByte(decrypted, 0x80)]
+			}
+			c.received = append(c.received, data)
+
+			response := c.statusData
+			if c.level.Has(SecurityLevelREncryption) {
+				block, err := aes.NewCipher(c.sENC)
+				require.NoError(c.t, err)
+				padded := utils.Pad80Block(response, 16)
+				response = make([]byte, len(padded))
+				cipher.NewCBCEncrypter(block, c.counterIV(0x80)).CryptBlocks(response, padded)
+			}
+			if c.level.Has(SecurityLevelRMAC) {
+				rmac := aesCMAC(c.t, c.sRMAC, c.chaining, response, []byte{0x90, 0x00})[:8]
+				if c.tamperRMAC {
+					rmac[0] ^= 0xFF
+				}
+				response = append(response, rmac...)
+			}
+			return apdu.Response{Data: response, Trailer: 0x9000}
+		})
+}
+
+func newSCP03Card(t *testing.T, parameter byte) *scp03Card {
+	return &scp03Card{
+		t:               t,
+		keys:            DefaultTestKeys(),
+		parameter:       parameter,
+		sequenceCounter: test.MustParseHex(t, "000007"),
+		securityDomain:  test.MustParseHex(t, "A000000151000000"),
+		statusData:      test.MustParseHex(t, "E3124F08A0000001510000009F700107C5019E"),
+	}
+}
+
+func TestSCP03(t *testing.T) {
+	getStatus := apdu.Command{
+		Class:       classGlobalPlatform,
+		Instruction: InstructionGetStatus,
+		Parameters:  apdu.Parameters{P1: 0x80, P2: 0x02},
+		Data:        test.MustParseHex(t, "4F00"),
+	}
+
+	testCases := []struct {
+		name      string
+		parameter byte
+		level     SecurityLevel
+	}{
+		{"i=00 C-MAC", 0x00, SecurityLevelCMAC},
+		{"i=00 C-DECRYPTION", 0x00, SecurityLevelCMAC | SecurityLevelCDecryption},
+		{"i=10 C-DECRYPTION", 0x10, SecurityLevelCMAC | SecurityLevelCDecryption},
+		{"i=70 R-MAC", 0x70, SecurityLevelCMAC | SecurityLevelRMAC},
+		{"i=70 R-ENCRYPTION", 0x70, SecurityLevelCMAC | SecurityLevelCDecryption | SecurityLevelRMAC | SecurityLevelREncryption},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			card := newSCP03Card(t, tc.parameter)
+			scp := NewSCP03(apdu.NewRawClient(card.simulate()), DefaultTestKeys()).
+				WithSecurityDomain(card.securityDomain).
+				WithRandom(bytes.NewReader(test.MustParseHex(t, "0102030405060708")))
+
+			channel, err := scp.Open(tc.level)
+			require.NoError(t, err)
+			assert.Equal(t, tc.level, card.level)
+
+			for i := 0; i < 3; i++ {
+				resp, err := channel.SendCommand(getStatus)
+				require.NoError(t, err)
+				require.Equal(t, apdu.Trailer(0x9000), resp.Trailer)
+				assert.Equal(t, card.statusData, resp.Data)
+			}
+			assert.Equal(t, [][]byte{getStatus.Data, getStatus.Data, getStatus.Data}, card.received)
+		})
+	}
+}
+
+func TestSCP03_Errors(t *testing.T) {
+	t.Run("Wrong keys", func(t *testing.T) {
+		card := newSCP03Card(t, 0x00)
+		card.keys.MAC = test.MustParseHex(t, "00112233445566778899AABBCCDDEEFF")
+		_, err := NewSCP03(apdu.NewRawClient(card.simulate()), DefaultTestKeys()).Open(SecurityLevelCMAC)
+		assert.ErrorIs(t, err, ErrCardCryptogramMismatch)
+	})
+	t.Run("Wrong security domain", func(t *testing.T) {
+		card := newSCP03Card(t, 0x10)
+		_, err := NewSCP03(apdu.NewRawClient(card.simulate()), DefaultTestKeys()).
+			WithSecurityDomain(test.MustParseHex(t, "A000000151535041")).
+			Open(SecurityLevelCMAC)
+		assert.ErrorIs(t, err, ErrCardChallengeMismatch)
+	})
+	t.Run("R-MAC not supported", func(t *testing.T) {
+		card := newSCP03Card(t, 0x10)
+		_, err := NewSCP03(apdu.NewRawClient(card.simulate()), DefaultTestKeys()).Open(SecurityLevelCMAC | SecurityLevelRMAC)
+		assert.Error(t, err)
+	})
+	t.Run("Invalid R-MAC", func(t *testing.T) {
+		card := newSCP03Card(t, 0x70)
+		card.tamperRMAC = true
+		channel, err := NewSCP03(apdu.NewRawClient(card.simulate()), DefaultTestKeys()).Open(SecurityLevelCMAC | SecurityLevelRMAC)
+		require.NoError(t, err)
+		_, err = channel.SendCommand(apdu.Command{
+			Class:       classGlobalPlatform,
+			Instruction: InstructionGetStatus,
+			Parameters:  apdu.Parameters{P1: 0x80, P2: 0x02},
+			Data:        test.MustParseHex(t, "4F00"),
+		})
+		assert.ErrorIs(t, err, apdu.ErrInvalidResponseMAC)
+	})
+}
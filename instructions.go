@@ -61,4 +61,6 @@ const (
 const (
 	EMVInstructionA8_GetProcessingOptions Instruction = 0xA8
 	EMVInstructionAE_GenerateAC           Instruction = 0xAE
+
+	EMVInstruction2A_ComputeCryptographicChecksum Instruction = 0x2A
 )
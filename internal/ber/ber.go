@@ -0,0 +1,205 @@
+package ber
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+// Tag is a BER-TLV tag with all of its bytes packed big-endian in an integer,
+// so that 9F4B is represented as 0x9F4B.
+type Tag uint32
+
+func (t Tag) Bytes() []byte {
+	var result []byte
+	for v := t; v > 0; v >>= 8 {
+		result = append([]byte{byte(v)}, result...)
+	}
+	if len(result) == 0 {
+		return []byte{0x00}
+	}
+	return result
+}
+
+func (t Tag) Constructed() bool {
+	return t.Bytes()[0]&0b0010_0000 != 0
+}
+
+func (t Tag) String() string {
+	return fmt.Sprintf("%X", t.Bytes())
+}
+
+type TLV struct {
+	Tag   Tag
+	Value []byte
+}
+
+func (tlv TLV) Bytes() []byte {
+	return Encode(tlv.Tag, tlv.Value)
+}
+
+// Children parses the value of a constructed data object.
+func (tlv TLV) Children() ([]TLV, error) {
+	if !tlv.Tag.Constructed() {
+		return nil, fmt.Errorf("tag %s is not constructed", tlv.Tag)
+	}
+	return Parse(tlv.Value)
+}
+
+var (
+	ErrUnexpectedEnd  = errors.New("unexpected end of BER-TLV data")
+	ErrInvalidLength  = errors.New("invalid BER-TLV length")
+	ErrTagTooLong     = errors.New("BER-TLV tag is too long")
+	ErrLengthTooLarge = errors.New("BER-TLV length is too large")
+)
+
+// Parse decodes a sequence of BER-TLV data objects. Padding bytes 00 and FF
+// found between data objects are skipped, as allowed by EMV Book 3 Annex B.
+func Parse(data []byte) ([]TLV, error) {
+	var result []TLV
+	for len(data) > 0 {
+		if data[0] == 0x00 || data[0] == 0xFF {
+			data = data[1:]
+			continue
+		}
+		tlv, rest, err := ParseOne(data)
+		if err != nil {
+			return result, err
+		}
+		result = append(result, tlv)
+		data = rest
+	}
+	return result, nil
+}
+
+// ParseOne decodes the first data object and returns the remaining bytes.
+func ParseOne(data []byte) (TLV, []byte, error) {
+	tag, data, err := parseTag(data)
+	if err != nil {
+		return TLV{}, nil, err
+	}
+	length, data, err := parseLength(data)
+	if err != nil {
+		return TLV{}, nil, err
+	}
+	if len(data) < length {
+		return TLV{}, nil, fmt.Errorf("%w: tag %s needs %d bytes but only %d remain", ErrUnexpectedEnd, tag, length, len(data))
+	}
+	return TLV{
+		Tag:   tag,
+		Value: data[:length],
+	}, data[length:], nil
+}
+
+func parseTag(data []byte) (Tag, []byte, error) {
+	if len(data) < 1 {
+		return 0, nil, ErrUnexpectedEnd
+	}
+	tag := Tag(data[0])
+	if data[0]&0x1F != 0x1F {
+		return tag, data[1:], nil
+	}
+	for i := 1; ; i++ {
+		if i >= len(data) {
+			return 0, nil, ErrUnexpectedEnd
+		}
+		if i > 3 {
+			return 0, nil, ErrTagTooLong
+		}
+		tag = tag<<8 | Tag(data[i])
+		if data[i]&0x80 == 0 {
+			return tag, data[i+1:], nil
+		}
+	}
+}
+
+func parseLength(data []byte) (int, []byte, error) {
+	if len(data) < 1 {
+		return 0, nil, ErrUnexpectedEnd
+	}
+	first := data[0]
+	if first < 0x80 {
+		return int(first), data[1:], nil
+	}
+	count := int(first & 0x7F)
+	if count == 0 {
+		return 0, nil, ErrInvalidLength
+	}
+	if count > 3 {
+		return 0, nil, ErrLengthTooLarge
+	}
+	if len(data) < 1+count {
+		return 0, nil, ErrUnexpectedEnd
+	}
+	var length int
+	for _, b := range data[1 : 1+count] {
+		length = length<<8 | int(b)
+	}
+	return length, data[1+count:], nil
+}
+
+// EncodeLength encodes a length using the definite form.
+func EncodeLength(length int) []byte {
+	switch {
+	case length < 0x80:
+		return []byte{byte(length)}
+	case length <= 0xFF:
+		return []byte{0x81, byte(length)}
+	case length <= 0xFFFF:
+		return []byte{0x82, byte(length >> 8), byte(length)}
+	default:
+		return []byte{0x83, byte(length >> 16), byte(length >> 8), byte(length)}
+	}
+}
+
+func Encode(tag Tag, value []byte) []byte {
+	var b bytes.Buffer
+	b.Write(tag.Bytes())
+	b.Write(EncodeLength(len(value)))
+	b.Write(value)
+	return b.Bytes()
+}
+
+// Find returns the first data object with the specified tag, searching
+// recursively inside constructed data objects.
+func Find(tlvs []TLV, tag Tag) (TLV, bool) {
+	for _, t := range tlvs {
+		if t.Tag == tag {
+			return t, true
+		}
+		if t.Tag.Constructed() {
+			children, err := t.Children()
+			if err != nil {
+				continue
+			}
+			if found, ok := Find(children, tag); ok {
+				return found, true
+			}
+		}
+	}
+	return TLV{}, false
+}
+
+// ParseDOL decodes a Data Object List, which is a sequence of tags and
+// lengths without values.
+func ParseDOL(data []byte) ([]TagLength, error) {
+	var result []TagLength
+	for len(data) > 0 {
+		tag, rest, err := parseTag(data)
+		if err != nil {
+			return result, err
+		}
+		length, rest, err := parseLength(rest)
+		if err != nil {
+			return result, err
+		}
+		result = append(result, TagLength{Tag: tag, Length: length})
+		data = rest
+	}
+	return result, nil
+}
+
+type TagLength struct {
+	Tag    Tag
+	Length int
+}
@@ -0,0 +1,59 @@
+package ber
+
+import (
+	"testing"
+
+	"github.com/mniak/apdu/internal/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	data := test.MustParseHex(t, "77 14 9F27 01 80 9F36 02 0042 00 00 9F26 03 112233 FF 5A 00")
+
+	tlvs, err := Parse(data)
+	require.NoError(t, err)
+	require.Len(t, tlvs, 1)
+	assert.Equal(t, Tag(0x77), tlvs[0].Tag)
+	assert.True(t, tlvs[0].Tag.Constructed())
+
+	children, err := tlvs[0].Children()
+	require.NoError(t, err)
+	require.Len(t, children, 4)
+	assert.Equal(t, Tag(0x9F27), children[0].Tag)
+	assert.Equal(t, []byte{0x80}, children[0].Value)
+	assert.Equal(t, Tag(0x9F36), children[1].Tag)
+	assert.Equal(t, []byte{0x00, 0x42}, children[1].Value)
+	assert.Equal(t, Tag(0x9F26), children[2].Tag)
+	assert.Equal(t, Tag(0x5A), children[3].Tag)
+	assert.Empty(t, children[3].Value)
+
+	found, ok := Find(tlvs, 0x9F26)
+	require.True(t, ok)
+	assert.Equal(t, []byte{0x11, 0x22, 0x33}, found.Value)
+}
+
+func TestParse_LongLength(t *testing.T) {
+	value := make([]byte, 200)
+	encoded := Encode(0x9F46, value)
+	test.AssertBytesEqual(t, "9F4681C8", encoded[:4])
+
+	tlvs, err := Parse(encoded)
+	require.NoError(t, err)
+	require.Len(t, tlvs, 1)
+	assert.Len(t, tlvs[0].Value, 200)
+}
+
+func TestParse_Truncated(t *testing.T) {
+	_, err := Parse(test.MustParseHex(t, "9F2605112233"))
+	assert.ErrorIs(t, err, ErrUnexpectedEnd)
+}
+
+func TestParseDOL(t *testing.T) {
+	dol, err := ParseDOL(test.MustParseHex(t, "9F0206 9F0306 9F1A02 95 05 5F2A02 9A03 9C01 9F3704"))
+	require.NoError(t, err)
+	assert.Equal(t, []TagLength{
+		{0x9F02, 6}, {0x9F03, 6}, {0x9F1A, 2}, {0x95, 5},
+		{0x5F2A, 2}, {0x9A, 3}, {0x9C, 1}, {0x9F37, 4},
+	}, dol)
+}
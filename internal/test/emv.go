@@ -0,0 +1,60 @@
+package test
+
+import (
+	"bytes"
+	"crypto/rsa"
+	"crypto/sha1"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// RSAExponent returns the public exponent of the key as it is stored in the
+// card.
+func RSAExponent(key *rsa.PrivateKey) []byte {
+	return big.NewInt(int64(key.E)).Bytes()
+}
+
+// SignEMV builds the data to be signed as header || body || hash || trailer,
+// padding the body with BB, and applies the private key, as in EMV Book 2.
+// The extra hash data is hashed after the body without being signed.
+func SignEMV(t *testing.T, key *rsa.PrivateKey, body []byte, extraHashData ...[]byte) []byte {
+	t.Helper()
+	length := key.Size()
+	body = append([]byte{}, body...)
+	for len(body) < length-22 {
+		body = append(body, 0xBB)
+	}
+	require.Len(t, body, length-22)
+
+	h := sha1.New()
+	h.Write(body)
+	for _, d := range extraHashData {
+		h.Write(d)
+	}
+	data := bytes.Join([][]byte{{0x6A}, body, h.Sum(nil), {0xBC}}, nil)
+
+	x := new(big.Int).SetBytes(data)
+	x.Exp(x, key.D, key.N)
+	return x.FillBytes(make([]byte, length))
+}
+
+// EMVCertificate signs the header followed by the lengths of the modulus and
+// the exponent of the subject and as much of its modulus as fits in the
+// signer key minus the overhead, which is 36 for issuer certificates and 42
+// for ICC certificates. It returns the certificate and the remainder of the
+// modulus.
+func EMVCertificate(t *testing.T, signer, subject *rsa.PrivateKey, header []byte, overhead int, extraHashData ...[]byte) ([]byte, []byte) {
+	t.Helper()
+	modulus := subject.N.Bytes()
+	exponent := RSAExponent(subject)
+	leftmost, remainder := modulus, []byte(nil)
+	if available := signer.Size() - overhead; len(modulus) > available {
+		leftmost, remainder = modulus[:available], modulus[available:]
+	}
+	body := append(append([]byte{}, header...), byte(len(modulus)), byte(len(exponent)))
+	body = append(body, leftmost...)
+	extra := append([][]byte{remainder, exponent}, extraHashData...)
+	return SignEMV(t, signer, body, extra...), remainder
+}
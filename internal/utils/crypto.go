@@ -0,0 +1,203 @@
+package utils
+
+import (
+	"crypto/cipher"
+	"crypto/des"
+	"errors"
+	"fmt"
+)
+
+// NewTripleDES creates a 3DES cipher accepting double length (K1 K2) and
+// triple length (K1 K2 K3) keys. Double length keys are expanded to K1 K2 K1.
+func NewTripleDES(key []byte) (cipher.Block, error) {
+	switch len(key) {
+	case 16:
+		expanded := make([]byte, 0, 24)
+		expanded = append(expanded, key...)
+		expanded = append(expanded, key[:8]...)
+		return des.NewTripleDESCipher(expanded)
+	case 24:
+		return des.NewTripleDESCipher(key)
+	default:
+		return nil, fmt.Errorf("invalid 3DES key length: %d", len(key))
+	}
+}
+
+// EncryptECB encrypts each block of data independently. The data length must
+// be a multiple of the block size.
+func EncryptECB(block cipher.Block, data []byte) ([]byte, error) {
+	size := block.BlockSize()
+	if len(data)%size != 0 {
+		return nil, errors.New("data length is not a multiple of the block size")
+	}
+	result := make([]byte, len(data))
+	for i := 0; i < len(data); i += size {
+		block.Encrypt(result[i:i+size], data[i:i+size])
+	}
+	return result, nil
+}
+
+// EncryptTripleDESECB is a shorthand for EncryptECB using NewTripleDES.
+func EncryptTripleDESECB(key, data []byte) ([]byte, error) {
+	block, err := NewTripleDES(key)
+	if err != nil {
+		return nil, err
+	}
+	return EncryptECB(block, data)
+}
+
+// RetailMAC computes the ISO/IEC 9797-1 MAC Algorithm 3 with DES, also known
+// as the retail MAC. The key must be double length and the data must already
+// be padded to a multiple of 8 bytes.
+func RetailMAC(key, data []byte) ([]byte, error) {
+	if len(key) != 16 {
+		return nil, fmt.Errorf("invalid retail MAC key length: %d", len(key))
+	}
+	if len(data) == 0 || len(data)%8 != 0 {
+		return nil, errors.New("data length is not a multiple of 8")
+	}
+	left, err := des.NewCipher(key[:8])
+	if err != nil {
+		return nil, err
+	}
+	right, err := des.NewCipher(key[8:])
+	if err != nil {
+		return nil, err
+	}
+
+	mac := make([]byte, 8)
+	for i := 0; i < len(data); i += 8 {
+		XORInPlace(mac, data[i:i+8])
+		left.Encrypt(mac, mac)
+	}
+	right.Decrypt(mac, mac)
+	left.Encrypt(mac, mac)
+	return mac, nil
+}
+
+// Pad00 pads the data with 0x00s until it is a multiple of the block size,
+// as specified by ISO/IEC 9797-1 padding method 1. Data that is already a
+// multiple of the block size (and not empty) is returned unchanged.
+func Pad00(data []byte) []byte {
+	const blockSize = 8
+	if len(data) > 0 && len(data)%blockSize == 0 {
+		return data
+	}
+	data = append(data, 0x00)
+	for len(data)%blockSize > 0 {
+		data = append(data, 0x00)
+	}
+	return data
+}
+
+// AdjustOddParity returns a copy of the key with the least significant bit of
+// each byte changed so that each byte has an odd number of bits set.
+func AdjustOddParity(key []byte) []byte {
+	result := make([]byte, len(key))
+	for i, b := range key {
+		b &^= 1
+		ones := 0
+		for v := b; v > 0; v >>= 1 {
+			ones += int(v & 1)
+		}
+		if ones%2 == 0 {
+			b |= 1
+		}
+		result[i] = b
+	}
+	return result
+}
+
+// Pad80Block pads the data with 0x80 and then 0x00s until it is a multiple of
+// the block size, always adding at least one byte (ISO/IEC 9797-1 padding
+// method 2). The data is not mutated.
+func Pad80Block(data []byte, blockSize int) []byte {
+	result := make([]byte, 0, len(data)+blockSize)
+	result = append(result, data...)
+	result = append(result, 0x80)
+	for len(result)%blockSize > 0 {
+		result = append(result, 0x00)
+	}
+	return result
+}
+
+// Unpad80 removes the padding added by Pad80Block.
+func Unpad80(data []byte) ([]byte, error) {
+	i := len(data) - 1
+	for i >= 0 && data[i] == 0x00 {
+		i--
+	}
+	if i < 0 || data[i] != 0x80 {
+		return nil, errors.New("invalid padding")
+	}
+	return data[:i], nil
+}
+
+// EncryptCBC encrypts the data, which must be a multiple of the block size.
+func EncryptCBC(block cipher.Block, iv, data []byte) ([]byte, error) {
+	if len(data)%block.BlockSize() != 0 {
+		return nil, errors.New("data length is not a multiple of the block size")
+	}
+	result := make([]byte, len(data))
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(result, data)
+	return result, nil
+}
+
+// DecryptCBC decrypts the data, which must be a multiple of the block size.
+func DecryptCBC(block cipher.Block, iv, data []byte) ([]byte, error) {
+	if len(data)%block.BlockSize() != 0 {
+		return nil, errors.New("data length is not a multiple of the block size")
+	}
+	result := make([]byte, len(data))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(result, data)
+	return result, nil
+}
+
+// CMAC computes the NIST SP 800-38B CMAC of the data, which can have any
+// length.
+func CMAC(block cipher.Block, data []byte) []byte {
+	size := block.BlockSize()
+	k1 := make([]byte, size)
+	block.Encrypt(k1, k1)
+	k1 = cmacSubkey(k1)
+	k2 := cmacSubkey(k1)
+
+	var last []byte
+	if len(data) > 0 && len(data)%size == 0 {
+		last = XOR(data[len(data)-size:], k1)
+		data = data[:len(data)-size]
+	} else {
+		full := len(data) - len(data)%size
+		last = XOR(Pad80Block(data[full:], size), k2)
+		data = data[:full]
+	}
+
+	mac := make([]byte, size)
+	for i := 0; i < len(data); i += size {
+		XORInPlace(mac, data[i:i+size])
+		block.Encrypt(mac, mac)
+	}
+	XORInPlace(mac, last)
+	block.Encrypt(mac, mac)
+	return mac
+}
+
+// cmacSubkey shifts the value one bit to the left, applying the constant of
+// the block size when the most significant bit is set.
+func cmacSubkey(value []byte) []byte {
+	result := make([]byte, len(value))
+	for i := range value {
+		result[i] = value[i] << 1
+		if i+1 < len(value) {
+			result[i] |= value[i+1] >> 7
+		}
+	}
+	if value[0]&0x80 != 0 {
+		if len(value) == 8 {
+			result[len(result)-1] ^= 0x1B
+		} else {
+			result[len(result)-1] ^= 0x87
+		}
+	}
+	return result
+}
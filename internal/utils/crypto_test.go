@@ -0,0 +1,67 @@
+package utils
+
+import (
+	"crypto/aes"
+	"testing"
+
+	"github.com/mniak/apdu/internal/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetailMAC(t *testing.T) {
+	// ISO/IEC 9797-1 Annex B, MAC Algorithm 3, padding method 1
+	key := test.MustParseHex(t, "0123456789ABCDEF FEDCBA9876543210")
+	data := Pad00([]byte("Now is the time for all "))
+
+	mac, err := RetailMAC(key, data)
+	require.NoError(t, err)
+	test.AssertBytesEqual(t, "A1C72E74EA3FA9B6", mac)
+}
+
+func TestEncryptTripleDESECB(t *testing.T) {
+	key := test.MustParseHex(t, "0123456789ABCDEF0123456789ABCDEF")
+	data := test.MustParseHex(t, "4E6F772069732074")
+
+	// Double length keys with equal halves behave as single DES
+	encrypted, err := EncryptTripleDESECB(key, data)
+	require.NoError(t, err)
+	test.AssertBytesEqual(t, "3FA40E8A984D4815", encrypted)
+}
+
+func TestAdjustOddParity(t *testing.T) {
+	adjusted := AdjustOddParity([]byte{0x00, 0x01, 0x02, 0x03, 0xFE, 0xFF})
+	assert.Equal(t, []byte{0x01, 0x01, 0x02, 0x02, 0xFE, 0xFE}, adjusted)
+}
+
+func TestPad00(t *testing.T) {
+	assert.Equal(t, []byte{1, 2, 0, 0, 0, 0, 0, 0}, Pad00([]byte{1, 2}))
+	assert.Len(t, Pad00(make([]byte, 8)), 8)
+	assert.Len(t, Pad00(nil), 8)
+}
+
+func TestPad80Block(t *testing.T) {
+	assert.Equal(t, []byte{1, 2, 0x80, 0, 0, 0, 0, 0}, Pad80Block([]byte{1, 2}, 8))
+	assert.Len(t, Pad80Block(make([]byte, 16), 16), 32)
+
+	unpadded, err := Unpad80(Pad80Block([]byte{1, 2, 0x80, 0}, 16))
+	require.NoError(t, err)
+	assert.Equal(t, []byte{1, 2, 0x80, 0}, unpadded)
+
+	_, err = Unpad80([]byte{1, 2, 0, 0})
+	assert.Error(t, err)
+	_, err = Unpad80([]byte{0x80, 2})
+	assert.Error(t, err)
+}
+
+func TestCMAC(t *testing.T) {
+	// RFC 4493, section 4
+	block, err := aes.NewCipher(test.MustParseHex(t, "2B7E151628AED2A6ABF7158809CF4F3C"))
+	require.NoError(t, err)
+	message := test.MustParseHex(t, "6BC1BEE22E409F96E93D7E117393172A AE2D8A571E03AC9C9EB76FAC45AF8E51 30C81C46A35CE411E5FBC1191A0A52EF F69F2445DF4F9B17AD2B417BE66C3710")
+
+	test.AssertBytesEqual(t, "BB1D6929E95937287FA37D129B756746", CMAC(block, nil))
+	test.AssertBytesEqual(t, "070A16B46B4D4144F79BDD9DD04A287C", CMAC(block, message[:16]))
+	test.AssertBytesEqual(t, "DFA66747DE9AE63030CA32611497C827", CMAC(block, message[:40]))
+	test.AssertBytesEqual(t, "51F0BEBF7E3B9D92FC49741779363CFE", CMAC(block, message))
+}
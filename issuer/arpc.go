@@ -0,0 +1,93 @@
+package issuer
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/mniak/apdu/internal/utils"
+)
+
+// GenerateARPCMethod1 computes the Authorisation Response Cryptogram as
+// specified in EMV Book 2, section 8.2.1:
+// ARPC := DES3(SK)[ARQC xor (ARC || 00 00 00 00 00 00)]
+func GenerateARPCMethod1(sessionKey, arqc, arc []byte) ([]byte, error) {
+	if len(arqc) != 8 {
+		return nil, errors.New("ARQC must have 8 bytes")
+	}
+	if len(arc) != 2 {
+		return nil, errors.New("authorisation response code must have 2 bytes")
+	}
+	data := utils.XOR(arqc, utils.PadRight(arc, 0x00, 8))
+	return utils.EncryptTripleDESECB(sessionKey, data)
+}
+
+// GenerateARPCMethod2 computes the Authorisation Response Cryptogram as
+// specified in EMV Book 2, section 8.2.2: the 4 leftmost bytes of
+// MAC(SK)[ARQC || CSU || Proprietary Authentication Data].
+func GenerateARPCMethod2(sessionKey, arqc, csu, proprietaryAuthenticationData []byte) ([]byte, error) {
+	if len(arqc) != 8 {
+		return nil, errors.New("ARQC must have 8 bytes")
+	}
+	if len(csu) != 4 {
+		return nil, errors.New("card status update must have 4 bytes")
+	}
+	if len(proprietaryAuthenticationData) > 8 {
+		return nil, errors.New("proprietary authentication data must have at most 8 bytes")
+	}
+
+	var data []byte
+	data = append(data, arqc...)
+	data = append(data, csu...)
+	data = append(data, proprietaryAuthenticationData...)
+	mac, err := utils.RetailMAC(sessionKey, PaddingMethod2.Pad(data))
+	if err != nil {
+		return nil, err
+	}
+	return mac[:4], nil
+}
+
+// IssuerAuthenticationDataMethod1 verifies the ARQC and builds the Issuer
+// Authentication Data (91) as ARPC || ARC.
+func (cv CryptogramVersion) IssuerAuthenticationDataMethod1(issuerMasterKey []byte, input ARQCInput, arc []byte) ([]byte, error) {
+	sessionKey, arqc, err := cv.verifiedSessionKey(issuerMasterKey, input)
+	if err != nil {
+		return nil, err
+	}
+	arpc, err := GenerateARPCMethod1(sessionKey, arqc, arc)
+	if err != nil {
+		return nil, err
+	}
+	return append(arpc, arc...), nil
+}
+
+// IssuerAuthenticationDataMethod2 verifies the ARQC and builds the Issuer
+// Authentication Data (91) as ARPC || CSU || Proprietary Authentication Data.
+func (cv CryptogramVersion) IssuerAuthenticationDataMethod2(issuerMasterKey []byte, input ARQCInput, csu, proprietaryAuthenticationData []byte) ([]byte, error) {
+	sessionKey, arqc, err := cv.verifiedSessionKey(issuerMasterKey, input)
+	if err != nil {
+		return nil, err
+	}
+	arpc, err := GenerateARPCMethod2(sessionKey, arqc, csu, proprietaryAuthenticationData)
+	if err != nil {
+		return nil, err
+	}
+	result := append(arpc, csu...)
+	return append(result, proprietaryAuthenticationData...), nil
+}
+
+func (cv CryptogramVersion) verifiedSessionKey(issuerMasterKey []byte, input ARQCInput) ([]byte, []byte, error) {
+	if err := cv.VerifyARQC(issuerMasterKey, input); err != nil {
+		return nil, nil, err
+	}
+	atc, err := hex.DecodeString(input.Response.ApplicationTransactionCounter())
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid application transaction counter: %w", err)
+	}
+	arqc, err := hex.DecodeString(input.Response.ApplicationCryptogram())
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid application cryptogram: %w", err)
+	}
+	sessionKey, err := cv.SessionKey(issuerMasterKey, input.PAN, input.PANSequenceNumber, atc, input.UnpredictableNumber)
+	return sessionKey, arqc, err
+}
@@ -0,0 +1,34 @@
+package issuer
+
+import (
+	"testing"
+
+	"github.com/mniak/apdu/internal/test"
+	"github.com/mniak/apdu/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateARPCMethod1(t *testing.T) {
+	arqc := test.MustParseHex(t, "1122334455667788")
+	arpc, err := GenerateARPCMethod1(testIssuerMasterKey, arqc, []byte("00"))
+	require.NoError(t, err)
+
+	expected, err := utils.EncryptTripleDESECB(testIssuerMasterKey, test.MustParseHex(t, "2112334455667788"))
+	require.NoError(t, err)
+	assert.Equal(t, expected, arpc)
+}
+
+func TestGenerateARPCMethod2(t *testing.T) {
+	arqc := test.MustParseHex(t, "1122334455667788")
+	csu := test.MustParseHex(t, "00800000")
+	arpc, err := GenerateARPCMethod2(testIssuerMasterKey, arqc, csu, nil)
+	require.NoError(t, err)
+
+	mac, err := utils.RetailMAC(testIssuerMasterKey, test.MustParseHex(t, "1122334455667788 00800000 80000000"))
+	require.NoError(t, err)
+	assert.Equal(t, mac[:4], arpc)
+
+	_, err = GenerateARPCMethod2(testIssuerMasterKey, arqc, csu, make([]byte, 9))
+	assert.Error(t, err)
+}
@@ -0,0 +1,195 @@
+package issuer
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/mniak/apdu"
+	"github.com/mniak/apdu/internal/utils"
+)
+
+var ErrCryptogramMismatch = errors.New("application cryptogram does not match")
+
+type SessionKeyDerivation int
+
+const (
+	// NoSessionKey uses the ICC master key directly, as in Visa CVN 10.
+	NoSessionKey SessionKeyDerivation = iota
+	CommonSessionKey
+	EMV2000SessionKey
+	MastercardSessionKey
+)
+
+type PaddingMethod int
+
+const (
+	// PaddingMethod1 pads with 00s (ISO/IEC 9797-1 method 1).
+	PaddingMethod1 PaddingMethod = 1
+	// PaddingMethod2 pads with 80 followed by 00s (ISO/IEC 9797-1 method 2).
+	PaddingMethod2 PaddingMethod = 2
+)
+
+func (p PaddingMethod) Pad(data []byte) []byte {
+	data = append([]byte{}, data...)
+	if p == PaddingMethod1 {
+		return utils.Pad00(data)
+	}
+	return utils.Pad80(data, false)
+}
+
+// CryptogramVersion describes how an issuer computes the application
+// cryptograms of a card: which keys are used and which data is MACed.
+type CryptogramVersion struct {
+	MasterKeyDerivation  MasterKeyDerivationOption
+	SessionKeyDerivation SessionKeyDerivation
+	// TreeParameters is only used by the EMV2000 session key derivation.
+	TreeParameters TreeParameters
+	Padding        PaddingMethod
+	// IssuerApplicationData selects the part of the Issuer Application Data
+	// that is appended to the cryptogram data.
+	IssuerApplicationData func(iad []byte) []byte
+}
+
+var (
+	VisaCVN10 = CryptogramVersion{
+		MasterKeyDerivation:   OptionA,
+		SessionKeyDerivation:  NoSessionKey,
+		Padding:               PaddingMethod1,
+		IssuerApplicationData: VisaCVR,
+	}
+	VisaCVN18 = CryptogramVersion{
+		MasterKeyDerivation:   OptionAuto,
+		SessionKeyDerivation:  CommonSessionKey,
+		Padding:               PaddingMethod2,
+		IssuerApplicationData: FullIAD,
+	}
+	MastercardCVN10 = CryptogramVersion{
+		MasterKeyDerivation:   OptionA,
+		SessionKeyDerivation:  MastercardSessionKey,
+		Padding:               PaddingMethod2,
+		IssuerApplicationData: MastercardCVR,
+	}
+	MastercardCVN16 = CryptogramVersion{
+		MasterKeyDerivation:   OptionA,
+		SessionKeyDerivation:  CommonSessionKey,
+		Padding:               PaddingMethod2,
+		IssuerApplicationData: MastercardCVR,
+	}
+	EMVCommonCoreDefinitions = CryptogramVersion{
+		MasterKeyDerivation:   OptionAuto,
+		SessionKeyDerivation:  CommonSessionKey,
+		Padding:               PaddingMethod2,
+		IssuerApplicationData: FullIAD,
+	}
+)
+
+func FullIAD(iad []byte) []byte {
+	return iad
+}
+
+// VisaCVR returns the Card Verification Results, including its length byte,
+// from the Visa Issuer Application Data format 0.
+func VisaCVR(iad []byte) []byte {
+	if len(iad) < 7 {
+		return nil
+	}
+	return iad[3:7]
+}
+
+// MastercardCVR returns the Card Verification Results from the M/Chip Issuer
+// Application Data.
+func MastercardCVR(iad []byte) []byte {
+	if len(iad) < 8 {
+		return nil
+	}
+	return iad[2:8]
+}
+
+// SessionKey derives the key used to compute the cryptogram of a single
+// transaction from the Issuer Master Key.
+func (cv CryptogramVersion) SessionKey(issuerMasterKey []byte, pan, panSequenceNumber string, atc, unpredictableNumber []byte) ([]byte, error) {
+	iccMasterKey, err := DeriveICCMasterKey(issuerMasterKey, pan, panSequenceNumber, cv.MasterKeyDerivation)
+	if err != nil {
+		return nil, err
+	}
+
+	switch cv.SessionKeyDerivation {
+	case NoSessionKey:
+		return iccMasterKey, nil
+	case CommonSessionKey:
+		return DeriveCommonSessionKey(iccMasterKey, atc)
+	case EMV2000SessionKey:
+		return DeriveEMV2000SessionKey(iccMasterKey, atc, cv.TreeParameters)
+	case MastercardSessionKey:
+		return DeriveMastercardSessionKey(iccMasterKey, atc, unpredictableNumber)
+	default:
+		return nil, fmt.Errorf("invalid session key derivation: %d", cv.SessionKeyDerivation)
+	}
+}
+
+// CryptogramData assembles the data recommended by EMV Book 2, section 8.1.1:
+// the terminal data requested by CDOL1, the AIP, the ATC and the Issuer
+// Application Data, or part of it.
+func (cv CryptogramVersion) CryptogramData(cdolData, aip, atc, iad []byte) []byte {
+	var b bytes.Buffer
+	b.Write(cdolData)
+	b.Write(aip)
+	b.Write(atc)
+	if cv.IssuerApplicationData != nil {
+		b.Write(cv.IssuerApplicationData(iad))
+	}
+	return b.Bytes()
+}
+
+// Compute pads the data and calculates the application cryptogram.
+func (cv CryptogramVersion) Compute(sessionKey, data []byte) ([]byte, error) {
+	return utils.RetailMAC(sessionKey, cv.Padding.Pad(data))
+}
+
+type ARQCInput struct {
+	Response          apdu.GenerateACResponse
+	CDOL1Data         []byte
+	AIP               apdu.AIP
+	PAN               string
+	PANSequenceNumber string
+	// UnpredictableNumber is only needed by the Mastercard session key derivation.
+	UnpredictableNumber []byte
+}
+
+// ComputeCryptogram calculates the cryptogram that the card should have
+// returned in the GENERATE AC response.
+func (cv CryptogramVersion) ComputeCryptogram(issuerMasterKey []byte, input ARQCInput) ([]byte, error) {
+	atc, err := hex.DecodeString(input.Response.ApplicationTransactionCounter())
+	if err != nil || len(atc) != 2 {
+		return nil, errors.New("invalid application transaction counter in the response")
+	}
+
+	sessionKey, err := cv.SessionKey(issuerMasterKey, input.PAN, input.PANSequenceNumber, atc, input.UnpredictableNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	data := cv.CryptogramData(input.CDOL1Data, input.AIP, atc, input.Response.IssuerApplicationData())
+	return cv.Compute(sessionKey, data)
+}
+
+// VerifyARQC checks the cryptogram returned by the card against the one
+// computed with the Issuer Master Key. The same check applies to TCs and AACs.
+func (cv CryptogramVersion) VerifyARQC(issuerMasterKey []byte, input ARQCInput) error {
+	received, err := hex.DecodeString(input.Response.ApplicationCryptogram())
+	if err != nil || len(received) != 8 {
+		return errors.New("invalid application cryptogram in the response")
+	}
+
+	expected, err := cv.ComputeCryptogram(issuerMasterKey, input)
+	if err != nil {
+		return err
+	}
+	if subtle.ConstantTimeCompare(expected, received) != 1 {
+		return ErrCryptogramMismatch
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package issuer
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/mniak/apdu"
+	"github.com/mniak/apdu/internal/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyARQC(t *testing.T) {
+	cdol1Data := test.MustParseHex(t, "000000001000 000000000000 0076 8000048000 0986 240101 00 CAFEBABE")
+	aip := apdu.AIP{0x39, 0x00}
+	atc := []byte{0x00, 0x42}
+	iad := test.MustParseHex(t, "06010A03A0A000 0F 00000000000000000000000000000000")
+
+	versions := map[string]CryptogramVersion{
+		"Visa CVN 10":       VisaCVN10,
+		"Visa CVN 18":       VisaCVN18,
+		"Mastercard CVN 10": MastercardCVN10,
+		"Mastercard CVN 16": MastercardCVN16,
+		"EMV2000 tree": {
+			MasterKeyDerivation:   OptionA,
+			SessionKeyDerivation:  EMV2000SessionKey,
+			TreeParameters:        EMV2000Tree,
+			Padding:               PaddingMethod2,
+			IssuerApplicationData: FullIAD,
+		},
+	}
+	for name, cv := range versions {
+		t.Run(name, func(t *testing.T) {
+			sessionKey, err := cv.SessionKey(testIssuerMasterKey, "5413330089600010", "01", atc, []byte{0xCA, 0xFE, 0xBA, 0xBE})
+			require.NoError(t, err)
+			ac, err := cv.Compute(sessionKey, cv.CryptogramData(cdol1Data, aip, atc, iad))
+			require.NoError(t, err)
+
+			var format1 bytes.Buffer
+			format1.WriteByte(0x80)
+			format1.Write(atc)
+			format1.Write(ac)
+			format1.Write(iad)
+
+			input := ARQCInput{
+				Response: apdu.GenerateACResponse{
+					Format1: format1.Bytes(),
+				},
+				CDOL1Data:           cdol1Data,
+				AIP:                 aip,
+				PAN:                 "5413330089600010",
+				PANSequenceNumber:   "01",
+				UnpredictableNumber: []byte{0xCA, 0xFE, 0xBA, 0xBE},
+			}
+			require.NoError(t, cv.VerifyARQC(testIssuerMasterKey, input))
+
+			t.Run("Different terminal data", func(t *testing.T) {
+				changed := input
+				changed.CDOL1Data = append([]byte{}, cdol1Data...)
+				changed.CDOL1Data[5] = 0x01
+				assert.ErrorIs(t, cv.VerifyARQC(testIssuerMasterKey, changed), ErrCryptogramMismatch)
+			})
+			t.Run("Different PAN sequence number", func(t *testing.T) {
+				changed := input
+				changed.PANSequenceNumber = "02"
+				assert.ErrorIs(t, cv.VerifyARQC(testIssuerMasterKey, changed), ErrCryptogramMismatch)
+			})
+		})
+	}
+}
+
+func TestPaddingMethod(t *testing.T) {
+	test.AssertBytesEqual(t, "0102000000000000", PaddingMethod1.Pad([]byte{1, 2}))
+	test.AssertBytesEqual(t, "0102800000000000", PaddingMethod2.Pad([]byte{1, 2}))
+	test.AssertBytesEqual(t, "01020304050607088000000000000000", PaddingMethod2.Pad([]byte{1, 2, 3, 4, 5, 6, 7, 8}))
+}
@@ -0,0 +1,231 @@
+package issuer
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/mniak/apdu/internal/utils"
+)
+
+type MasterKeyDerivationOption int
+
+const (
+	// OptionAuto uses option A for PANs up to 16 digits and option B otherwise.
+	OptionAuto MasterKeyDerivationOption = iota
+	OptionA
+	OptionB
+)
+
+// DeriveICCMasterKey derives the ICC Master Key from the Issuer Master Key
+// as specified in EMV Book 2, Annex A1.4. The PAN and the PAN Sequence Number
+// are given as digits and any F padding is ignored. An empty PAN Sequence
+// Number is treated as 00.
+func DeriveICCMasterKey(issuerMasterKey []byte, pan, panSequenceNumber string, option MasterKeyDerivationOption) ([]byte, error) {
+	pan = strings.TrimRight(strings.ToUpper(pan), "F")
+	panSequenceNumber = strings.TrimRight(strings.ToUpper(panSequenceNumber), "F")
+	if panSequenceNumber == "" {
+		panSequenceNumber = "00"
+	}
+	digits := pan + panSequenceNumber
+	if strings.Trim(digits, "0123456789") != "" {
+		return nil, fmt.Errorf("PAN and PAN sequence number must only contain digits: %q", digits)
+	}
+
+	if option == OptionAuto {
+		option = OptionA
+		if len(pan) > 16 {
+			option = OptionB
+		}
+	}
+
+	var y string
+	switch option {
+	case OptionA:
+		y = fmt.Sprintf("%016s", digits)
+		y = y[len(y)-16:]
+	case OptionB:
+		y = optionBDigits(digits)
+	default:
+		return nil, fmt.Errorf("invalid master key derivation option: %d", option)
+	}
+
+	yBytes, err := hex.DecodeString(y)
+	if err != nil {
+		return nil, err
+	}
+
+	data := append(yBytes, utils.InvertBits(yBytes)...)
+	key, err := utils.EncryptTripleDESECB(issuerMasterKey, data)
+	if err != nil {
+		return nil, err
+	}
+	return utils.AdjustOddParity(key), nil
+}
+
+// optionBDigits hashes the PAN and PAN Sequence Number and decimalizes the
+// result into 16 digits.
+func optionBDigits(digits string) string {
+	if len(digits)%2 != 0 {
+		digits = "0" + digits
+	}
+	x, _ := hex.DecodeString(digits)
+	sum := sha1.Sum(x)
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+
+	var sb strings.Builder
+	for _, ch := range hash {
+		if ch >= '0' && ch <= '9' {
+			sb.WriteRune(ch)
+			if sb.Len() == 16 {
+				return sb.String()
+			}
+		}
+	}
+	for _, ch := range hash {
+		if ch >= 'A' && ch <= 'F' {
+			sb.WriteRune(ch - 'A' + '0')
+			if sb.Len() == 16 {
+				return sb.String()
+			}
+		}
+	}
+	return sb.String()
+}
+
+// DeriveCommonSessionKey derives the session key for application
+// cryptograms as specified in EMV Book 2, Annex A1.3 (EMV Common Session Key
+// Derivation), using the ATC as diversification value.
+func DeriveCommonSessionKey(masterKey, atc []byte) ([]byte, error) {
+	if len(atc) != 2 {
+		return nil, errors.New("ATC must have 2 bytes")
+	}
+	r := make([]byte, 8)
+	copy(r, atc)
+	return deriveCommonSessionKey(masterKey, r)
+}
+
+// DeriveSecureMessagingSessionKey derives the session key for secure
+// messaging with the EMV Common Session Key Derivation, using the Application
+// Cryptogram as diversification value.
+func DeriveSecureMessagingSessionKey(masterKey, applicationCryptogram []byte) ([]byte, error) {
+	if len(applicationCryptogram) != 8 {
+		return nil, errors.New("application cryptogram must have 8 bytes")
+	}
+	return deriveCommonSessionKey(masterKey, applicationCryptogram)
+}
+
+// deriveCommonSessionKey computes
+// DES3(MK)[R0 R1 F0 R3 R4 R5 R6 R7] || DES3(MK)[R0 R1 0F R3 R4 R5 R6 R7]
+func deriveCommonSessionKey(masterKey, r []byte) ([]byte, error) {
+	data := make([]byte, 16)
+	copy(data[0:], r)
+	data[2] = 0xF0
+	copy(data[8:], r)
+	data[10] = 0x0F
+
+	key, err := utils.EncryptTripleDESECB(masterKey, data)
+	if err != nil {
+		return nil, err
+	}
+	return utils.AdjustOddParity(key), nil
+}
+
+// DeriveMastercardSessionKey derives the session key using the Mastercard
+// proprietary session key derivation (SKD), which diversifies the key with
+// the ATC and the Unpredictable Number.
+func DeriveMastercardSessionKey(masterKey, atc, unpredictableNumber []byte) ([]byte, error) {
+	if len(atc) != 2 {
+		return nil, errors.New("ATC must have 2 bytes")
+	}
+	if len(unpredictableNumber) != 4 {
+		return nil, errors.New("unpredictable number must have 4 bytes")
+	}
+	data := make([]byte, 16)
+	copy(data[0:], atc)
+	data[2] = 0xF0
+	copy(data[4:], unpredictableNumber)
+	copy(data[8:], atc)
+	data[10] = 0x0F
+	copy(data[12:], unpredictableNumber)
+
+	key, err := utils.EncryptTripleDESECB(masterKey, data)
+	if err != nil {
+		return nil, err
+	}
+	return utils.AdjustOddParity(key), nil
+}
+
+type TreeParameters struct {
+	BranchFactor int
+	Height       int
+	IV           []byte
+}
+
+// EMV2000Tree are the parameters commonly used with the EMV2000 tree
+// derivation, which cover all the values of a 2 bytes ATC.
+var EMV2000Tree = TreeParameters{
+	BranchFactor: 4,
+	Height:       8,
+	IV:           make([]byte, 16),
+}
+
+// DeriveEMV2000SessionKey derives the session key using the tree-based
+// derivation introduced by EMV2000 (EMV 4.0 Book 2, Annex A1.3).
+func DeriveEMV2000SessionKey(masterKey, atc []byte, params TreeParameters) ([]byte, error) {
+	if len(atc) != 2 {
+		return nil, errors.New("ATC must have 2 bytes")
+	}
+	if params.BranchFactor < 2 || params.Height < 2 || len(params.IV) != 16 {
+		return nil, errors.New("invalid tree parameters")
+	}
+	counter := int(atc[0])<<8 | int(atc[1])
+	leaves := 1
+	for i := 0; i < params.Height; i++ {
+		leaves *= params.BranchFactor
+	}
+	if counter >= leaves {
+		return nil, fmt.Errorf("ATC %d does not fit in a tree with %d leaves", counter, leaves)
+	}
+
+	// Each level of the tree needs the key of the previous level (parent)
+	// and the one before it (grandparent).
+	var derive func(j, height int) ([]byte, []byte, error)
+	derive = func(j, height int) ([]byte, []byte, error) {
+		if height == 0 {
+			return masterKey, params.IV, nil
+		}
+		parent, grandparent, err := derive(j/params.BranchFactor, height-1)
+		if err != nil {
+			return nil, nil, err
+		}
+		key, err := treeFunction(parent, grandparent, j%params.BranchFactor)
+		return key, parent, err
+	}
+
+	// SK := IK(H, ATC) xor IK(H-2, ATC div b^2)
+	parent, grandparent, err := derive(counter/params.BranchFactor, params.Height-1)
+	if err != nil {
+		return nil, err
+	}
+	key, err := treeFunction(parent, grandparent, counter%params.BranchFactor)
+	if err != nil {
+		return nil, err
+	}
+	return utils.AdjustOddParity(utils.XOR(key, grandparent)), nil
+}
+
+// treeFunction is the function f(X, Y, j) of the tree derivation:
+// DES3(X)[YL xor j] || DES3(X)[YR xor j xor F0]
+func treeFunction(x, y []byte, j int) ([]byte, error) {
+	jBlock := make([]byte, 8)
+	jBlock[7] = byte(j)
+
+	left := utils.XOR(y[:8], jBlock)
+	jBlock[7] ^= 0xF0
+	right := utils.XOR(y[8:], jBlock)
+
+	return utils.EncryptTripleDESECB(x, append(left, right...))
+}
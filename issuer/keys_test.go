@@ -0,0 +1,89 @@
+package issuer
+
+import (
+	"testing"
+
+	"github.com/mniak/apdu/internal/test"
+	"github.com/mniak/apdu/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testIssuerMasterKey = []byte{
+	0x01, 0x23, 0x45, 0x67, 0x89, 0xAB, 0xCD, 0xEF,
+	0xFE, 0xDC, 0xBA, 0x98, 0x76, 0x54, 0x32, 0x10,
+}
+
+func TestDeriveICCMasterKey_OptionA(t *testing.T) {
+	key, err := DeriveICCMasterKey(testIssuerMasterKey, "5413330089600010F", "01", OptionAuto)
+	require.NoError(t, err)
+
+	// Rightmost 16 digits of PAN || PAN Sequence Number
+	y := test.MustParseHex(t, "1333008960001001")
+	left, err := utils.EncryptTripleDESECB(testIssuerMasterKey, y)
+	require.NoError(t, err)
+	right, err := utils.EncryptTripleDESECB(testIssuerMasterKey, utils.InvertBits(y))
+	require.NoError(t, err)
+
+	assert.Equal(t, utils.AdjustOddParity(append(left, right...)), key)
+}
+
+func TestDeriveICCMasterKey_OptionB(t *testing.T) {
+	assert.Equal(t, "3759613721069136", optionBDigits("541333008960001012301"))
+
+	key, err := DeriveICCMasterKey(testIssuerMasterKey, "5413330089600010123", "01", OptionAuto)
+	require.NoError(t, err)
+
+	y := test.MustParseHex(t, "3759613721069136")
+	expected, err := utils.EncryptTripleDESECB(testIssuerMasterKey, append(y, utils.InvertBits(y)...))
+	require.NoError(t, err)
+	assert.Equal(t, utils.AdjustOddParity(expected), key)
+}
+
+func TestDeriveCommonSessionKey(t *testing.T) {
+	key, err := DeriveCommonSessionKey(testIssuerMasterKey, []byte{0x00, 0x42})
+	require.NoError(t, err)
+
+	expected, err := utils.EncryptTripleDESECB(testIssuerMasterKey, test.MustParseHex(t, "0042F000000000000042 0F0000000000"))
+	require.NoError(t, err)
+	assert.Equal(t, utils.AdjustOddParity(expected), key)
+}
+
+func TestDeriveMastercardSessionKey(t *testing.T) {
+	key, err := DeriveMastercardSessionKey(testIssuerMasterKey, []byte{0x00, 0x42}, []byte{0xCA, 0xFE, 0xBA, 0xBE})
+	require.NoError(t, err)
+
+	expected, err := utils.EncryptTripleDESECB(testIssuerMasterKey, test.MustParseHex(t, "0042F000CAFEBABE 00420F00CAFEBABE"))
+	require.NoError(t, err)
+	assert.Equal(t, utils.AdjustOddParity(expected), key)
+}
+
+func TestDeriveEMV2000SessionKey(t *testing.T) {
+	params := TreeParameters{
+		BranchFactor: 2,
+		Height:       2,
+		IV:           make([]byte, 16),
+	}
+
+	// With height 2, IK(1, j div 2) is derived from the master key and the
+	// IV, and the session key is IK(2, j) xor IK(0, 0), which is the master key
+	for atc := 0; atc < 4; atc++ {
+		parent, err := treeFunction(testIssuerMasterKey, params.IV, atc/2)
+		require.NoError(t, err)
+		expected, err := treeFunction(parent, testIssuerMasterKey, atc%2)
+		require.NoError(t, err)
+
+		key, err := DeriveEMV2000SessionKey(testIssuerMasterKey, []byte{0x00, byte(atc)}, params)
+		require.NoError(t, err)
+		assert.Equal(t, utils.AdjustOddParity(utils.XOR(expected, testIssuerMasterKey)), key)
+	}
+
+	_, err := DeriveEMV2000SessionKey(testIssuerMasterKey, []byte{0x00, 0x04}, params)
+	assert.Error(t, err)
+
+	keyA, err := DeriveEMV2000SessionKey(testIssuerMasterKey, []byte{0x12, 0x34}, EMV2000Tree)
+	require.NoError(t, err)
+	keyB, err := DeriveEMV2000SessionKey(testIssuerMasterKey, []byte{0x12, 0x35}, EMV2000Tree)
+	require.NoError(t, err)
+	assert.NotEqual(t, keyA, keyB)
+}
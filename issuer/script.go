@@ -0,0 +1,184 @@
+package issuer
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/mniak/apdu"
+	"github.com/mniak/apdu/internal/utils"
+)
+
+const (
+	classProprietarySM   apdu.Class = 0x84
+	classInterindustrySM apdu.Class = 0x04
+)
+
+// Post-issuance commands defined in EMV Book 3, section 6.5
+const (
+	InstructionApplicationBlock   apdu.Instruction = 0x1E
+	InstructionApplicationUnblock apdu.Instruction = 0x18
+	InstructionCardBlock          apdu.Instruction = 0x16
+	InstructionPINChangeUnblock   apdu.Instruction = 0x24
+)
+
+// ScriptBuilder creates issuer script commands protected with EMV secure
+// messaging format 2 (EMV Book 2, section 9) for the transaction identified by
+// the ATC and the Application Cryptogram returned by the card.
+type ScriptBuilder struct {
+	// IntegrityKey is the session key for secure messaging integrity (SK SMI).
+	IntegrityKey []byte
+	// ConfidentialityKey is the session key for secure messaging
+	// confidentiality (SK SMC).
+	ConfidentialityKey []byte
+
+	ATC                   []byte
+	ApplicationCryptogram []byte
+
+	// MACLength is the number of bytes of the MAC appended to the command
+	// data, from 4 to 8.
+	MACLength int
+}
+
+// NewScriptBuilder derives the ICC master keys for secure messaging from the
+// issuer master keys and then the session keys using the Application
+// Cryptogram.
+func NewScriptBuilder(issuerMasterKeySMI, issuerMasterKeySMC []byte, pan, panSequenceNumber string, atc, applicationCryptogram []byte) (ScriptBuilder, error) {
+	builder := ScriptBuilder{
+		ATC:                   atc,
+		ApplicationCryptogram: applicationCryptogram,
+		MACLength:             8,
+	}
+
+	iccKeySMI, err := DeriveICCMasterKey(issuerMasterKeySMI, pan, panSequenceNumber, OptionAuto)
+	if err != nil {
+		return builder, err
+	}
+	builder.IntegrityKey, err = DeriveSecureMessagingSessionKey(iccKeySMI, applicationCryptogram)
+	if err != nil {
+		return builder, err
+	}
+
+	iccKeySMC, err := DeriveICCMasterKey(issuerMasterKeySMC, pan, panSequenceNumber, OptionAuto)
+	if err != nil {
+		return builder, err
+	}
+	builder.ConfidentialityKey, err = DeriveSecureMessagingSessionKey(iccKeySMC, applicationCryptogram)
+	return builder, err
+}
+
+// MAC computes the MAC over the command header (with Lc including the MAC
+// length), the ATC, the Application Cryptogram and the command data.
+func (b ScriptBuilder) MAC(cmd apdu.Command) ([]byte, error) {
+	if b.MACLength < 4 || b.MACLength > 8 {
+		return nil, fmt.Errorf("invalid MAC length: %d", b.MACLength)
+	}
+	lc := len(cmd.Data) + b.MACLength
+	if lc > 0xFF {
+		return nil, errors.New("command data is too long")
+	}
+
+	data := []byte{byte(cmd.Class), byte(cmd.Instruction), cmd.Parameters.P1, cmd.Parameters.P2, byte(lc)}
+	data = append(data, b.ATC...)
+	data = append(data, b.ApplicationCryptogram...)
+	data = append(data, cmd.Data...)
+
+	mac, err := utils.RetailMAC(b.IntegrityKey, PaddingMethod2.Pad(data))
+	if err != nil {
+		return nil, err
+	}
+	return mac[:b.MACLength], nil
+}
+
+// Encipher protects the data for confidentiality. Data that is not a
+// multiple of 8 bytes is prefixed with its length and padded with 80 00...
+func (b ScriptBuilder) Encipher(data []byte) ([]byte, error) {
+	if len(data)%8 != 0 {
+		data = append([]byte{byte(len(data))}, data...)
+		data = utils.Pad80(data, true)
+	}
+	return utils.EncryptTripleDESECB(b.ConfidentialityKey, data)
+}
+
+func (b ScriptBuilder) build(class apdu.Class, instruction apdu.Instruction, p1, p2 byte, data []byte) (apdu.Command, error) {
+	cmd := apdu.Command{
+		Class:       class,
+		Instruction: instruction,
+		Parameters: apdu.Parameters{
+			P1: p1,
+			P2: p2,
+		},
+		Data: data,
+	}
+	mac, err := b.MAC(cmd)
+	if err != nil {
+		return cmd, err
+	}
+	cmd.Data = append(append([]byte{}, data...), mac...)
+	return cmd, nil
+}
+
+func (b ScriptBuilder) ApplicationBlock() (apdu.Command, error) {
+	return b.build(classProprietarySM, InstructionApplicationBlock, 0x00, 0x00, nil)
+}
+
+func (b ScriptBuilder) ApplicationUnblock() (apdu.Command, error) {
+	return b.build(classProprietarySM, InstructionApplicationUnblock, 0x00, 0x00, nil)
+}
+
+func (b ScriptBuilder) CardBlock() (apdu.Command, error) {
+	return b.build(classProprietarySM, InstructionCardBlock, 0x00, 0x00, nil)
+}
+
+// PINUnblock resets the PIN try counter without changing the PIN.
+func (b ScriptBuilder) PINUnblock() (apdu.Command, error) {
+	return b.build(classProprietarySM, InstructionPINChangeUnblock, 0x00, 0x00, nil)
+}
+
+// PINChange resets the PIN try counter and sets a new PIN, without requiring
+// the current PIN. The new PIN is formatted as an ISO 9564 format 2 PIN block
+// and enciphered with the confidentiality session key.
+func (b ScriptBuilder) PINChange(newPIN string) (apdu.Command, error) {
+	block, err := PINBlock(newPIN)
+	if err != nil {
+		return apdu.Command{}, err
+	}
+	enciphered, err := b.Encipher(block)
+	if err != nil {
+		return apdu.Command{}, err
+	}
+	return b.build(classProprietarySM, InstructionPINChangeUnblock, 0x00, 0x02, enciphered)
+}
+
+// PutData updates a primitive data object of the card, like the Lower
+// Consecutive Offline Limit (9F14). The tag is sent in P1-P2, so it can have
+// at most two bytes.
+func (b ScriptBuilder) PutData(tag apdu.Tag, value []byte) (apdu.Command, error) {
+	if tag > 0xFFFF {
+		return apdu.Command{}, fmt.Errorf("tag %s does not fit in P1-P2", tag)
+	}
+	return b.build(classInterindustrySM, apdu.InstructionDA_PutData, byte(tag>>8), byte(tag), value)
+}
+
+// UpdateRecord replaces the contents of a record of a file.
+func (b ScriptBuilder) UpdateRecord(sfi, recordNumber int, record []byte) (apdu.Command, error) {
+	return b.build(classInterindustrySM, apdu.InstructionDC_UpdateRecord, byte(recordNumber), byte(sfi<<3)|0b100, record)
+}
+
+// PINBlock formats the PIN as an ISO 9564 format 2 PIN block: control field
+// 2, PIN length, PIN digits and F filler.
+func PINBlock(pin string) ([]byte, error) {
+	if len(pin) < 4 || len(pin) > 12 {
+		return nil, fmt.Errorf("invalid PIN length: %d", len(pin))
+	}
+	nibbles := []byte{0x2, byte(len(pin))}
+	for _, ch := range pin {
+		if ch < '0' || ch > '9' {
+			return nil, errors.New("the PIN must contain only digits")
+		}
+		nibbles = append(nibbles, byte(ch-'0'))
+	}
+	for len(nibbles) < 16 {
+		nibbles = append(nibbles, 0xF)
+	}
+	return utils.NibblesToBytes(nibbles), nil
+}
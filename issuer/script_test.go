@@ -0,0 +1,106 @@
+package issuer
+
+import (
+	"testing"
+
+	"github.com/mniak/apdu"
+	"github.com/mniak/apdu/internal/test"
+	"github.com/mniak/apdu/internal/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestScriptBuilder(t *testing.T) ScriptBuilder {
+	t.Helper()
+	builder, err := NewScriptBuilder(
+		testIssuerMasterKey,
+		utils.InvertBits(testIssuerMasterKey),
+		"5413330089600010", "01",
+		[]byte{0x00, 0x42},
+		test.MustParseHex(t, "1122334455667788"),
+	)
+	require.NoError(t, err)
+	return builder
+}
+
+func TestNewScriptBuilder(t *testing.T) {
+	builder := newTestScriptBuilder(t)
+
+	iccKey, err := DeriveICCMasterKey(testIssuerMasterKey, "5413330089600010", "01", OptionA)
+	require.NoError(t, err)
+	expected, err := utils.EncryptTripleDESECB(iccKey, test.MustParseHex(t, "1122F04455667788 11220F4455667788"))
+	require.NoError(t, err)
+	assert.Equal(t, utils.AdjustOddParity(expected), builder.IntegrityKey)
+	assert.NotEqual(t, builder.IntegrityKey, builder.ConfidentialityKey)
+}
+
+func TestScriptBuilder_Commands(t *testing.T) {
+	builder := newTestScriptBuilder(t)
+
+	testCases := []struct {
+		name   string
+		build  func() (apdu.Command, error)
+		header string
+	}{
+		{"Application block", builder.ApplicationBlock, "841E0000"},
+		{"Application unblock", builder.ApplicationUnblock, "84180000"},
+		{"Card block", builder.CardBlock, "84160000"},
+		{"PIN unblock", builder.PINUnblock, "84240000"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cmd, err := tc.build()
+			require.NoError(t, err)
+			test.AssertBytesEqual(t, tc.header, cmd.ShortBytes()[:4])
+			require.Len(t, cmd.Data, 8)
+
+			macInput := append(test.MustParseHex(t, tc.header+"08 0042 1122334455667788"), 0x80)
+			expected, err := utils.RetailMAC(builder.IntegrityKey, utils.PadRight(macInput, 0x00, 16))
+			require.NoError(t, err)
+			assert.Equal(t, expected, cmd.Data)
+		})
+	}
+}
+
+func TestScriptBuilder_PINChange(t *testing.T) {
+	builder := newTestScriptBuilder(t)
+	builder.MACLength = 4
+
+	cmd, err := builder.PINChange("1234")
+	require.NoError(t, err)
+	assert.Equal(t, byte(0x02), cmd.Parameters.P2)
+	require.Len(t, cmd.Data, 12)
+
+	block, err := utils.NewTripleDES(builder.ConfidentialityKey)
+	require.NoError(t, err)
+	plaintext := make([]byte, 8)
+	block.Decrypt(plaintext, cmd.Data[:8])
+	test.AssertBytesEqual(t, "241234FFFFFFFFFF", plaintext)
+
+	mac, err := builder.MAC(apdu.Command{Class: 0x84, Instruction: 0x24, Parameters: apdu.Parameters{P2: 0x02}, Data: cmd.Data[:8]})
+	require.NoError(t, err)
+	assert.Equal(t, mac, cmd.Data[8:])
+}
+
+func TestScriptBuilder_Template(t *testing.T) {
+	builder := newTestScriptBuilder(t)
+	putData, err := builder.PutData(0x9F14, []byte{0x05})
+	require.NoError(t, err)
+	_, err = builder.PutData(0xDF8101, []byte{0x05})
+	assert.Error(t, err)
+	updateRecord, err := builder.UpdateRecord(2, 1, test.MustParseHex(t, "70035F2000"))
+	require.NoError(t, err)
+	test.AssertBytesEqual(t, "04DC0114", updateRecord.ShortBytes()[:4])
+
+	script := apdu.IssuerScript{
+		Template: apdu.TagIssuerScriptTemplate2,
+		ID:       []byte{0x00, 0x00, 0x00, 0x01},
+		Commands: []apdu.Command{putData, updateRecord},
+	}
+	parsed, err := apdu.ParseIssuerScripts(script.Bytes())
+	require.NoError(t, err)
+	require.Len(t, parsed, 1)
+	assert.NoError(t, parsed[0].Err())
+	assert.Equal(t, script.ID, parsed[0].ID)
+	assert.Equal(t, script.Commands, parsed[0].Commands)
+}
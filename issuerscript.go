@@ -0,0 +1,200 @@
+package apdu
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/mniak/apdu/internal/ber"
+)
+
+const (
+	TagIssuerScriptTemplate1  Tag = 0x71
+	TagIssuerScriptTemplate2  Tag = 0x72
+	TagIssuerScriptIdentifier Tag = 0x9F18
+	TagIssuerScriptCommand    Tag = 0x86
+	TagIssuerScriptResults    Tag = 0x9F5B
+)
+
+// IssuerScript is an Issuer Script Template sent by the issuer in the
+// authorisation response. Scripts of template 71 are delivered to the card
+// before the final GENERATE AC and scripts of template 72 after it.
+type IssuerScript struct {
+	Template Tag
+	ID       []byte
+	Commands []Command
+
+	// err is set when the template is not correctly formatted, in which case
+	// the script is reported as failed without being sent to the card.
+	err error
+}
+
+func (s IssuerScript) Err() error {
+	return s.err
+}
+
+// Bytes encodes the script as an Issuer Script Template, to be sent in the
+// authorisation response.
+func (s IssuerScript) Bytes() []byte {
+	var content bytes.Buffer
+	if len(s.ID) > 0 {
+		content.Write(ber.Encode(TagIssuerScriptIdentifier, s.ID))
+	}
+	for _, cmd := range s.Commands {
+		content.Write(ber.Encode(TagIssuerScriptCommand, cmd.ShortBytes()))
+	}
+	return ber.Encode(s.Template, content.Bytes())
+}
+
+// ParseIssuerScripts decodes the sequence of Issuer Script Templates 1 (71)
+// and 2 (72) received from the issuer. Other data objects are ignored.
+func ParseIssuerScripts(data []byte) ([]IssuerScript, error) {
+	tlvs, err := ber.Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse issuer scripts: %w", err)
+	}
+
+	var result []IssuerScript
+	for _, tlv := range tlvs {
+		template := tlv.Tag
+		if template != TagIssuerScriptTemplate1 && template != TagIssuerScriptTemplate2 {
+			continue
+		}
+		result = append(result, parseIssuerScript(template, tlv.Value))
+	}
+	return result, nil
+}
+
+func parseIssuerScript(template Tag, data []byte) IssuerScript {
+	script := IssuerScript{
+		Template: template,
+	}
+	children, err := ber.Parse(data)
+	if err != nil {
+		script.err = err
+		return script
+	}
+	for _, child := range children {
+		switch child.Tag {
+		case TagIssuerScriptIdentifier:
+			if len(child.Value) != 4 {
+				script.err = fmt.Errorf("issuer script identifier must have 4 bytes but has %d", len(child.Value))
+				return script
+			}
+			script.ID = child.Value
+		case TagIssuerScriptCommand:
+			cmd, err := ParseCommand(child.Value)
+			if err != nil {
+				script.err = err
+				return script
+			}
+			script.Commands = append(script.Commands, cmd)
+		default:
+			script.err = fmt.Errorf("unexpected tag %s in issuer script", child.Tag)
+			return script
+		}
+	}
+	return script
+}
+
+type IssuerScriptResultCode byte
+
+const (
+	ScriptNotPerformed IssuerScriptResultCode = 0x0
+	ScriptFailed       IssuerScriptResultCode = 0x1
+	ScriptSuccessful   IssuerScriptResultCode = 0x2
+)
+
+type IssuerScriptResult struct {
+	Result IssuerScriptResultCode
+	// FailedCommand is the sequence number, starting from 1, of the command
+	// that failed. It is zero when no command failed.
+	FailedCommand int
+	ScriptID      []byte
+}
+
+// Bytes encodes the result as specified in EMV Book 4, Annex A5.
+func (r IssuerScriptResult) Bytes() []byte {
+	sequence := r.FailedCommand
+	if sequence > 0xF {
+		sequence = 0xF
+	}
+	result := []byte{byte(r.Result)<<4 | byte(sequence)}
+	id := make([]byte, 4)
+	copy(id, r.ScriptID)
+	return append(result, id...)
+}
+
+type IssuerScriptResults []IssuerScriptResult
+
+// Bytes returns the value of the Issuer Script Results (9F5B).
+func (rs IssuerScriptResults) Bytes() []byte {
+	var b bytes.Buffer
+	for _, r := range rs {
+		b.Write(r.Bytes())
+	}
+	return b.Bytes()
+}
+
+func (rs IssuerScriptResults) Failed() bool {
+	for _, r := range rs {
+		if r.Result != ScriptSuccessful {
+			return true
+		}
+	}
+	return false
+}
+
+// issuerScriptCommandSucceeded checks the status words as specified in EMV
+// Book 3, section 10.10: 90xx, 62xx and 63xx mean the command was processed.
+func issuerScriptCommandSucceeded(t Trailer) bool {
+	switch t.SW1() {
+	case 0x90, 0x62, 0x63:
+		return true
+	}
+	return false
+}
+
+// ExecuteIssuerScripts sends to the card the commands of the scripts with the
+// given template, in the order received. When a command fails, the remaining
+// commands of that script are skipped and processing continues with the next
+// script. The TVR and TSI are updated accordingly.
+func ExecuteIssuerScripts(client RawClient, scripts []IssuerScript, template Tag, tvr *TVR, tsi *TSI) (IssuerScriptResults, error) {
+	var results IssuerScriptResults
+	for _, script := range scripts {
+		if script.Template != template {
+			continue
+		}
+		tsi.Set(TSIScriptProcessingPerformed)
+
+		result := IssuerScriptResult{
+			Result:   ScriptSuccessful,
+			ScriptID: script.ID,
+		}
+		if script.err != nil {
+			result.Result = ScriptFailed
+		}
+		for i, cmd := range script.Commands {
+			if result.Result != ScriptSuccessful {
+				break
+			}
+			resp, err := client.SendCommand(cmd)
+			if err != nil {
+				return results, err
+			}
+			if !issuerScriptCommandSucceeded(resp.Trailer) {
+				result.Result = ScriptFailed
+				result.FailedCommand = i + 1
+			}
+		}
+
+		if result.Result == ScriptFailed {
+			if template == TagIssuerScriptTemplate1 {
+				tvr.Set(TVRScriptProcessingFailedBeforeFinalGenerateAC)
+			} else {
+				tvr.Set(TVRScriptProcessingFailedAfterFinalGenerateAC)
+			}
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
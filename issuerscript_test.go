@@ -0,0 +1,100 @@
+package apdu
+
+import (
+	"testing"
+
+	"github.com/mniak/apdu/internal/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	gomock "go.uber.org/mock/gomock"
+)
+
+func TestParseCommand(t *testing.T) {
+	testCases := []struct {
+		name     string
+		hex      string
+		expected Command
+	}{
+		{
+			name:     "Case 1",
+			hex:      "84180000",
+			expected: Command{Class: 0x84, Instruction: 0x18},
+		},
+		{
+			name:     "Case 2",
+			hex:      "8418000000",
+			expected: Command{Class: 0x84, Instruction: 0x18, MaxReponseLength: 0x00},
+		},
+		{
+			name:     "Case 2 with Le",
+			hex:      "00B2010C1C",
+			expected: Command{Class: 0x00, Instruction: 0xB2, Parameters: Parameters{0x01, 0x0C}, MaxReponseLength: 0x1C},
+		},
+		{
+			name:     "Case 3",
+			hex:      "841E0000081122334455667788",
+			expected: Command{Class: 0x84, Instruction: 0x1E, Data: test.MustParseHex(t, "1122334455667788")},
+		},
+		{
+			name:     "Case 4",
+			hex:      "80CA9F170200FF00",
+			expected: Command{Class: 0x80, Instruction: 0xCA, Parameters: Parameters{0x9F, 0x17}, Data: []byte{0x00, 0xFF}, MaxReponseLength: 0x00},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cmd, err := ParseCommand(test.MustParseHex(t, tc.hex))
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, cmd)
+		})
+	}
+
+	_, err := ParseCommand(test.MustParseHex(t, "841E000008112233"))
+	assert.Error(t, err)
+}
+
+func TestExecuteIssuerScripts(t *testing.T) {
+	scripts, err := ParseIssuerScripts(test.MustParseHex(t, ""+
+		"71 15 9F1804 11223344 86 05 841E000000 86 05 8418000000"+
+		"72 07 86 05 8416000000"+
+		"71 03 860100"))
+	require.NoError(t, err)
+	require.Len(t, scripts, 3)
+	assert.Equal(t, TagIssuerScriptTemplate1, scripts[0].Template)
+	test.AssertBytesEqual(t, "11223344", scripts[0].ID)
+	assert.Len(t, scripts[0].Commands, 2)
+	assert.Equal(t, TagIssuerScriptTemplate2, scripts[1].Template)
+	assert.Error(t, scripts[2].Err())
+
+	ctrl := gomock.NewController(t)
+	mockClient := NewMockRawClient(ctrl)
+	gomock.InOrder(
+		mockClient.EXPECT().
+			SendCommand(gomock.Any()).
+			Do(func(cmd Command) { assert.Equal(t, Instruction(0x1E), cmd.Instruction) }).
+			Return(Response{Trailer: 0x9000}, nil),
+		mockClient.EXPECT().
+			SendCommand(gomock.Any()).
+			Do(func(cmd Command) { assert.Equal(t, Instruction(0x18), cmd.Instruction) }).
+			Return(Response{Trailer: 0x6985}, nil),
+		mockClient.EXPECT().
+			SendCommand(gomock.Any()).
+			Do(func(cmd Command) { assert.Equal(t, Instruction(0x16), cmd.Instruction) }).
+			Return(Response{Trailer: 0x6283}, nil),
+	)
+
+	var tvr TVR
+	var tsi TSI
+	results, err := ExecuteIssuerScripts(mockClient, scripts, TagIssuerScriptTemplate1, &tvr, &tsi)
+	require.NoError(t, err)
+	test.AssertBytesEqual(t, "12112233441000000000", results.Bytes())
+	assert.True(t, tvr.Has(TVRScriptProcessingFailedBeforeFinalGenerateAC))
+	assert.False(t, tvr.Has(TVRScriptProcessingFailedAfterFinalGenerateAC))
+	assert.True(t, tsi.Has(TSIScriptProcessingPerformed))
+
+	results, err = ExecuteIssuerScripts(mockClient, scripts, TagIssuerScriptTemplate2, &tvr, &tsi)
+	require.NoError(t, err)
+	test.AssertBytesEqual(t, "2000000000", results.Bytes())
+	assert.False(t, tvr.Has(TVRScriptProcessingFailedAfterFinalGenerateAC))
+	assert.Equal(t, TVR{0x00, 0x00, 0x00, 0x00, 0x20}, tvr)
+}
@@ -0,0 +1,236 @@
+package javacard
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/mniak/apdu/internal/ber"
+)
+
+var (
+	ErrComponentMissing = errors.New("CAP component is missing")
+	ErrInvalidComponent = errors.New("invalid CAP component")
+)
+
+// Component tags defined in Java Card Virtual Machine Specification, 6.1
+const (
+	ComponentHeader       byte = 1
+	ComponentDirectory    byte = 2
+	ComponentApplet       byte = 3
+	ComponentImport       byte = 4
+	ComponentConstantPool byte = 5
+	ComponentClass        byte = 6
+	ComponentMethod       byte = 7
+	ComponentStaticField  byte = 8
+	ComponentRefLocation  byte = 9
+	ComponentExport       byte = 10
+	ComponentDescriptor   byte = 11
+	ComponentDebug        byte = 12
+)
+
+// loadOrder is the order in which the components are sent to the card,
+// which is not the order of their tags. The Debug component is never loaded.
+var loadOrder = []string{
+	"Header",
+	"Directory",
+	"Import",
+	"Applet",
+	"Class",
+	"Method",
+	"StaticField",
+	"Export",
+	"ConstantPool",
+	"RefLocation",
+	"Descriptor",
+}
+
+// Applet is an applet declared in the Applet component.
+type Applet struct {
+	AID                 []byte
+	InstallMethodOffset uint16
+}
+
+// CAPFile is a converted Java Card package, read from a .cap archive.
+type CAPFile struct {
+	PackageAID   []byte
+	PackageName  string
+	MajorVersion byte
+	MinorVersion byte
+	Applets      []Applet
+	components   map[string][]byte
+}
+
+// OpenCAP reads the CAP file from the path.
+func OpenCAP(name string) (*CAPFile, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	return ParseCAP(data)
+}
+
+// ParseCAP reads the components of a CAP file from the ZIP archive. Every
+// file named <package>/javacard/<Component>.cap is considered a component.
+func ParseCAP(data []byte) (*CAPFile, error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+
+	result := CAPFile{
+		components: make(map[string][]byte),
+	}
+	for _, f := range archive.File {
+		dir, file := path.Split(f.Name)
+		if path.Base(dir) != "javacard" || path.Ext(file) != ".cap" {
+			continue
+		}
+		name := strings.TrimSuffix(file, ".cap")
+		if _, exists := result.components[name]; exists {
+			return nil, fmt.Errorf("CAP file contains more than one %s component", name)
+		}
+		content, err := readZipFile(f)
+		if err != nil {
+			return nil, err
+		}
+		result.components[name] = content
+	}
+
+	if err := result.parseHeader(); err != nil {
+		return nil, err
+	}
+	if err := result.parseApplets(); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func readZipFile(f *zip.File) ([]byte, error) {
+	r, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+// componentInfo returns the info of the component after checking its tag
+// and size.
+func (c *CAPFile) componentInfo(name string, tag byte) ([]byte, error) {
+	data, ok := c.components[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrComponentMissing, name)
+	}
+	if len(data) < 3 || data[0] != tag {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidComponent, name)
+	}
+	size := int(binary.BigEndian.Uint16(data[1:3]))
+	if len(data) != 3+size {
+		return nil, fmt.Errorf("%w: %s has %d bytes but declares %d", ErrInvalidComponent, name, len(data)-3, size)
+	}
+	return data[3:], nil
+}
+
+func (c *CAPFile) parseHeader() error {
+	info, err := c.componentInfo("Header", ComponentHeader)
+	if err != nil {
+		return err
+	}
+	// magic (4), minor (1), major (1), flags (1), package minor (1),
+	// package major (1), AID length (1)
+	if len(info) < 10 || binary.BigEndian.Uint32(info) != 0xDECAFFED {
+		return fmt.Errorf("%w: Header", ErrInvalidComponent)
+	}
+	c.MinorVersion = info[7]
+	c.MajorVersion = info[8]
+	aidLength := int(info[9])
+	info = info[10:]
+	if len(info) < aidLength {
+		return fmt.Errorf("%w: Header", ErrInvalidComponent)
+	}
+	c.PackageAID = info[:aidLength]
+	info = info[aidLength:]
+
+	// The package name is only present since CAP format 2.2
+	if len(info) > 0 && len(info) >= 1+int(info[0]) {
+		c.PackageName = string(info[1 : 1+int(info[0])])
+	}
+	return nil
+}
+
+func (c *CAPFile) parseApplets() error {
+	if _, ok := c.components["Applet"]; !ok {
+		return nil
+	}
+	info, err := c.componentInfo("Applet", ComponentApplet)
+	if err != nil {
+		return err
+	}
+	if len(info) < 1 {
+		return fmt.Errorf("%w: Applet", ErrInvalidComponent)
+	}
+	count := int(info[0])
+	info = info[1:]
+	for i := 0; i < count; i++ {
+		if len(info) < 1 || len(info) < 1+int(info[0])+2 {
+			return fmt.Errorf("%w: Applet", ErrInvalidComponent)
+		}
+		aidLength := int(info[0])
+		c.Applets = append(c.Applets, Applet{
+			AID:                 info[1 : 1+aidLength],
+			InstallMethodOffset: binary.BigEndian.Uint16(info[1+aidLength:]),
+		})
+		info = info[1+aidLength+2:]
+	}
+	return nil
+}
+
+// Component returns the bytes of a component, including its tag and size,
+// or nil when the CAP file does not contain it.
+func (c *CAPFile) Component(name string) []byte {
+	return c.components[name]
+}
+
+// LoadFileDataBlock is the concatenation of the components in the load
+// order, as sent to the card.
+func (c *CAPFile) LoadFileDataBlock() []byte {
+	var result []byte
+	for _, name := range loadOrder {
+		result = append(result, c.components[name]...)
+	}
+	return result
+}
+
+// Hash computes the Load File Data Block Hash sent in INSTALL [for load],
+// which is signed to produce DAP signatures.
+func (c *CAPFile) Hash(h hash.Hash) []byte {
+	h.Reset()
+	h.Write(c.LoadFileDataBlock())
+	return h.Sum(nil)
+}
+
+// DAPBlock is the signature of the Load File Data Block Hash by a Security
+// Domain with the Data Authentication Pattern privilege.
+type DAPBlock struct {
+	SecurityDomainAID []byte
+	Signature         []byte
+}
+
+// LoadFile encodes the load file sent with LOAD: the DAP blocks (tag E2)
+// followed by the Load File Data Block (tag C4).
+func (c *CAPFile) LoadFile(daps ...DAPBlock) []byte {
+	var result []byte
+	for _, dap := range daps {
+		value := append(ber.Encode(0x4F, dap.SecurityDomainAID), ber.Encode(0xC3, dap.Signature)...)
+		result = append(result, ber.Encode(0xE2, value)...)
+	}
+	return append(result, ber.Encode(0xC4, c.LoadFileDataBlock())...)
+}
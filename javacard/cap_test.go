@@ -0,0 +1,97 @@
+package javacard
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha1"
+	"testing"
+
+	"github.com/mniak/apdu/internal/ber"
+	"github.com/mniak/apdu/internal/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const helloLoadFileDataBlock = "010025DECAFFED010204000109A00000006203010C0111636F6D2F6578616D706C652F68656C6C6F" +
+	"02001600000000000000000000000000000000000000000000" +
+	"04000C0100010107A0000000620101" +
+	"03000E010AA00000006203010C01010012" +
+	"06000400010203" +
+	"0700050011223344" +
+	"08000A00000000000000000000" +
+	"0500050001010000" +
+	"09000400000000" +
+	"0B00020000"
+
+func TestOpenCAP(t *testing.T) {
+	cap, err := OpenCAP("testdata/hello.cap")
+	require.NoError(t, err)
+
+	test.AssertBytesEqual(t, "A00000006203010C01", cap.PackageAID)
+	assert.Equal(t, "com/example/hello", cap.PackageName)
+	assert.Equal(t, byte(1), cap.MajorVersion)
+	assert.Equal(t, byte(0), cap.MinorVersion)
+	require.Len(t, cap.Applets, 1)
+	test.AssertBytesEqual(t, "A00000006203010C0101", cap.Applets[0].AID)
+	assert.Equal(t, uint16(0x12), cap.Applets[0].InstallMethodOffset)
+
+	assert.Equal(t, test.MustParseHex(t, helloLoadFileDataBlock), cap.LoadFileDataBlock())
+	assert.NotNil(t, cap.Component("Debug"))
+	assert.Nil(t, cap.Component("Export"))
+}
+
+func TestCAPFile_LoadFile(t *testing.T) {
+	cap, err := OpenCAP("testdata/hello.cap")
+	require.NoError(t, err)
+
+	tlvs, err := ber.Parse(cap.LoadFile(DAPBlock{
+		SecurityDomainAID: test.MustParseHex(t, "A000000151000000"),
+		Signature:         test.MustParseHex(t, "0102030405060708"),
+	}))
+	require.NoError(t, err)
+	require.Len(t, tlvs, 2)
+	test.AssertBytesEqual(t, "4F08A000000151000000C3080102030405060708", tlvs[0].Value)
+	assert.Equal(t, ber.Tag(0xC4), tlvs[1].Tag)
+	assert.Equal(t, cap.LoadFileDataBlock(), tlvs[1].Value)
+
+	expected := sha1.Sum(cap.LoadFileDataBlock())
+	assert.Equal(t, expected[:], cap.Hash(sha1.New()))
+}
+
+func TestParseCAP_Errors(t *testing.T) {
+	archive := func(files map[string][]byte) []byte {
+		var b bytes.Buffer
+		w := zip.NewWriter(&b)
+		for name, content := range files {
+			f, err := w.Create(name)
+			require.NoError(t, err)
+			_, err = f.Write(content)
+			require.NoError(t, err)
+		}
+		require.NoError(t, w.Close())
+		return b.Bytes()
+	}
+
+	t.Run("Missing header", func(t *testing.T) {
+		_, err := ParseCAP(archive(map[string][]byte{
+			"pkg/javacard/Directory.cap": test.MustParseHex(t, "0200020000"),
+		}))
+		assert.ErrorIs(t, err, ErrComponentMissing)
+	})
+	t.Run("Wrong size", func(t *testing.T) {
+		_, err := ParseCAP(archive(map[string][]byte{
+			"pkg/javacard/Header.cap": test.MustParseHex(t, "010030DECAFFED"),
+		}))
+		assert.ErrorIs(t, err, ErrInvalidComponent)
+	})
+	t.Run("Wrong magic", func(t *testing.T) {
+		_, err := ParseCAP(archive(map[string][]byte{
+			"pkg/javacard/Header.cap": test.MustParseHex(t, "01000BCAFEBABE0102040001 01A0"),
+		}))
+		assert.ErrorIs(t, err, ErrInvalidComponent)
+	})
+	t.Run("Not a ZIP", func(t *testing.T) {
+		_, err := ParseCAP([]byte("not a zip"))
+		assert.Error(t, err)
+	})
+}
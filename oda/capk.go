@@ -0,0 +1,181 @@
+package oda
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrCAPublicKeyNotFound = errors.New("CA public key not found")
+	ErrCAPublicKeyExpired  = errors.New("CA public key is expired")
+	ErrChecksumMismatch    = errors.New("CA public key checksum does not match")
+	ErrCertificateExpired  = errors.New("certificate is expired")
+	ErrCertificateRevoked  = errors.New("certificate is revoked")
+)
+
+// CAPublicKey is a Certification Authority Public Key as distributed by the
+// payment systems, identified by the RID and the CA Public Key Index (8F).
+type CAPublicKey struct {
+	RID   []byte
+	Index byte
+	PublicKey
+	HashAlgorithm byte
+	KeyAlgorithm  byte
+	Checksum      []byte
+	// ExpirationDate is the last day the key is valid. The zero value means
+	// the key does not expire.
+	ExpirationDate time.Time
+}
+
+// CalculateChecksum computes the SHA-1 over RID, index, modulus and exponent.
+func (k CAPublicKey) CalculateChecksum() []byte {
+	h := sha1.New()
+	h.Write(k.RID)
+	h.Write([]byte{k.Index})
+	h.Write(k.Modulus)
+	h.Write(k.Exponent)
+	return h.Sum(nil)
+}
+
+// VerifyChecksum checks the key against its checksum. Keys distributed
+// without a checksum are accepted.
+func (k CAPublicKey) VerifyChecksum() error {
+	if len(k.Checksum) == 0 {
+		return nil
+	}
+	if !bytes.Equal(k.Checksum, k.CalculateChecksum()) {
+		return fmt.Errorf("%w: RID %X index %02X", ErrChecksumMismatch, k.RID, k.Index)
+	}
+	return nil
+}
+
+func (k CAPublicKey) Expired(now time.Time) bool {
+	if k.ExpirationDate.IsZero() {
+		return false
+	}
+	return now.After(k.ExpirationDate.AddDate(0, 0, 1).Add(-time.Nanosecond))
+}
+
+// Revocation is an entry of the Certification Revocation List, which
+// identifies an Issuer Public Key Certificate by the CA public key that signed
+// it and by its serial number.
+type Revocation struct {
+	RID          []byte
+	Index        byte
+	SerialNumber []byte
+}
+
+type caKeyID struct {
+	rid   string
+	index byte
+}
+
+type revocationID struct {
+	caKeyID
+	serial string
+}
+
+type CAPublicKeyStore struct {
+	keys    map[caKeyID]CAPublicKey
+	revoked map[revocationID]struct{}
+	now     func() time.Time
+}
+
+func NewCAPublicKeyStore() *CAPublicKeyStore {
+	return &CAPublicKeyStore{
+		keys:    make(map[caKeyID]CAPublicKey),
+		revoked: make(map[revocationID]struct{}),
+		now:     time.Now,
+	}
+}
+
+// WithClock replaces the function used to get the current time when checking
+// expiration dates.
+func (s *CAPublicKeyStore) WithClock(now func() time.Time) *CAPublicKeyStore {
+	s.now = now
+	return s
+}
+
+// Add verifies the checksum of the key and adds it to the store, replacing
+// any key with the same RID and index.
+func (s *CAPublicKeyStore) Add(key CAPublicKey) error {
+	if len(key.RID) != 5 {
+		return fmt.Errorf("invalid RID length: %d", len(key.RID))
+	}
+	if err := key.VerifyChecksum(); err != nil {
+		return err
+	}
+	s.keys[caKeyID{string(key.RID), key.Index}] = key
+	return nil
+}
+
+// Get returns the key identified by the RID and index. Since the RID is the
+// first 5 bytes of the AID, the AID can be used directly.
+func (s *CAPublicKeyStore) Get(rid []byte, index byte) (CAPublicKey, error) {
+	if len(rid) > 5 {
+		rid = rid[:5]
+	}
+	key, ok := s.keys[caKeyID{string(rid), index}]
+	if !ok {
+		return key, fmt.Errorf("%w: RID %X index %02X", ErrCAPublicKeyNotFound, rid, index)
+	}
+	if key.Expired(s.now()) {
+		return key, fmt.Errorf("%w: RID %X index %02X", ErrCAPublicKeyExpired, rid, index)
+	}
+	return key, nil
+}
+
+// GetByIndexHex is like Get but receives the index as the hex string read
+// from tag 8F.
+func (s *CAPublicKeyStore) GetByIndexHex(rid []byte, index string) (CAPublicKey, error) {
+	decoded, err := hex.DecodeString(index)
+	if err != nil || len(decoded) != 1 {
+		return CAPublicKey{}, fmt.Errorf("invalid CA public key index: %q", index)
+	}
+	return s.Get(rid, decoded[0])
+}
+
+func (s *CAPublicKeyStore) Keys() []CAPublicKey {
+	result := make([]CAPublicKey, 0, len(s.keys))
+	for _, k := range s.keys {
+		result = append(result, k)
+	}
+	return result
+}
+
+func (s *CAPublicKeyStore) Revoke(r Revocation) {
+	s.revoked[revocationID{caKeyID{string(r.RID), r.Index}, string(r.SerialNumber)}] = struct{}{}
+}
+
+func (s *CAPublicKeyStore) IsRevoked(rid []byte, index byte, serialNumber []byte) bool {
+	if len(rid) > 5 {
+		rid = rid[:5]
+	}
+	_, revoked := s.revoked[revocationID{caKeyID{string(rid), index}, string(serialNumber)}]
+	return revoked
+}
+
+// RecoverIssuerPublicKey looks up the CA public key and recovers the Issuer
+// Public Key, additionally checking the certificate expiration date and the
+// revocation list.
+func (s *CAPublicKeyStore) RecoverIssuerPublicKey(rid []byte, index byte, certificate, remainder, exponent []byte, pan string) (IssuerPublicKeyCertificate, error) {
+	ca, err := s.Get(rid, index)
+	if err != nil {
+		return IssuerPublicKeyCertificate{}, err
+	}
+	cert, err := RecoverIssuerPublicKey(ca.PublicKey, certificate, remainder, exponent, pan)
+	if err != nil {
+		return cert, err
+	}
+	if cert.Expired(s.now()) {
+		return cert, ErrCertificateExpired
+	}
+	if s.IsRevoked(rid, index, cert.SerialNumber) {
+		return cert, ErrCertificateRevoked
+	}
+	return cert, nil
+}
@@ -0,0 +1,245 @@
+package oda
+
+import (
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// keyRecord is the textual representation of a CA public key shared by all
+// the file formats. Binary values are hex encoded.
+type keyRecord struct {
+	RID            string `json:"rid" xml:"RID"`
+	Index          string `json:"index" xml:"Index"`
+	Modulus        string `json:"modulus" xml:"Modulus"`
+	Exponent       string `json:"exponent" xml:"Exponent"`
+	Checksum       string `json:"checksum,omitempty" xml:"Checksum"`
+	ExpirationDate string `json:"expirationDate,omitempty" xml:"ExpiryDate"`
+	HashAlgorithm  string `json:"hashAlgorithm,omitempty" xml:"HashAlgorithm"`
+	KeyAlgorithm   string `json:"keyAlgorithm,omitempty" xml:"KeyAlgorithm"`
+}
+
+type revocationRecord struct {
+	RID          string `json:"rid" xml:"RID"`
+	Index        string `json:"index" xml:"Index"`
+	SerialNumber string `json:"serialNumber" xml:"SerialNumber"`
+}
+
+func decodeHex(field, value string, expectedLength int) ([]byte, error) {
+	value = strings.Join(strings.Fields(value), "")
+	decoded, err := hex.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", field, err)
+	}
+	if expectedLength > 0 && len(decoded) != expectedLength {
+		return nil, fmt.Errorf("invalid %s: expected %d bytes but got %d", field, expectedLength, len(decoded))
+	}
+	return decoded, nil
+}
+
+func decodeOptionalByte(field, value string, defaultValue byte) (byte, error) {
+	if strings.TrimSpace(value) == "" {
+		return defaultValue, nil
+	}
+	decoded, err := decodeHex(field, value, 1)
+	if err != nil {
+		return 0, err
+	}
+	return decoded[0], nil
+}
+
+// parseDate accepts dates as YYYY-MM-DD or in the EMV format YYMMDD. An empty
+// string is interpreted as no date.
+func parseDate(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	switch len(value) {
+	case 0:
+		return time.Time{}, nil
+	case 6:
+		return time.Parse("060102", value)
+	default:
+		return time.Parse(time.DateOnly, value)
+	}
+}
+
+func (r keyRecord) key() (CAPublicKey, error) {
+	var key CAPublicKey
+	var err error
+	if key.RID, err = decodeHex("RID", r.RID, 5); err != nil {
+		return key, err
+	}
+	index, err := decodeHex("index", r.Index, 1)
+	if err != nil {
+		return key, err
+	}
+	key.Index = index[0]
+	if key.Modulus, err = decodeHex("modulus", r.Modulus, 0); err != nil {
+		return key, err
+	}
+	if key.Exponent, err = decodeHex("exponent", r.Exponent, 0); err != nil {
+		return key, err
+	}
+	if key.Checksum, err = decodeHex("checksum", r.Checksum, 0); err != nil {
+		return key, err
+	}
+	if key.HashAlgorithm, err = decodeOptionalByte("hash algorithm", r.HashAlgorithm, HashAlgorithmSHA1); err != nil {
+		return key, err
+	}
+	if key.KeyAlgorithm, err = decodeOptionalByte("key algorithm", r.KeyAlgorithm, KeyAlgorithmRSA); err != nil {
+		return key, err
+	}
+	if key.ExpirationDate, err = parseDate(r.ExpirationDate); err != nil {
+		return key, fmt.Errorf("invalid expiration date: %w", err)
+	}
+	return key, nil
+}
+
+func (r revocationRecord) revocation() (Revocation, error) {
+	var rev Revocation
+	var err error
+	if rev.RID, err = decodeHex("RID", r.RID, 5); err != nil {
+		return rev, err
+	}
+	index, err := decodeHex("index", r.Index, 1)
+	if err != nil {
+		return rev, err
+	}
+	rev.Index = index[0]
+	if rev.SerialNumber, err = decodeHex("serial number", r.SerialNumber, 3); err != nil {
+		return rev, err
+	}
+	return rev, nil
+}
+
+func (s *CAPublicKeyStore) addRecords(records []keyRecord, revocations []revocationRecord) error {
+	for i, r := range records {
+		key, err := r.key()
+		if err != nil {
+			return fmt.Errorf("key #%d: %w", i+1, err)
+		}
+		if err := s.Add(key); err != nil {
+			return fmt.Errorf("key #%d: %w", i+1, err)
+		}
+	}
+	for i, r := range revocations {
+		rev, err := r.revocation()
+		if err != nil {
+			return fmt.Errorf("revocation #%d: %w", i+1, err)
+		}
+		s.Revoke(rev)
+	}
+	return nil
+}
+
+// LoadJSON loads keys and revocations from a JSON document like:
+//
+//	{
+//	  "keys": [{"rid": "A000000003", "index": "92", "modulus": "...", "exponent": "03",
+//	            "checksum": "...", "expirationDate": "2028-12-31"}],
+//	  "revocations": [{"rid": "A000000003", "index": "92", "serialNumber": "000001"}]
+//	}
+func (s *CAPublicKeyStore) LoadJSON(r io.Reader) error {
+	var document struct {
+		Keys        []keyRecord        `json:"keys"`
+		Revocations []revocationRecord `json:"revocations"`
+	}
+	if err := json.NewDecoder(r).Decode(&document); err != nil {
+		return err
+	}
+	return s.addRecords(document.Keys, document.Revocations)
+}
+
+// LoadXML loads keys exported by terminal management systems as a list of
+// CAPK elements, and revocations as Revocation elements, whatever the name of
+// the root element:
+//
+//	<CAPKs>
+//	  <CAPK>
+//	    <RID>A000000003</RID>
+//	    <Index>92</Index>
+//	    <Modulus>...</Modulus>
+//	    <Exponent>03</Exponent>
+//	    <Checksum>...</Checksum>
+//	    <ExpiryDate>281231</ExpiryDate>
+//	  </CAPK>
+//	  <Revocation>
+//	    <RID>A000000003</RID>
+//	    <Index>92</Index>
+//	    <SerialNumber>000001</SerialNumber>
+//	  </Revocation>
+//	</CAPKs>
+func (s *CAPublicKeyStore) LoadXML(r io.Reader) error {
+	var document struct {
+		Keys        []keyRecord        `xml:"CAPK"`
+		Revocations []revocationRecord `xml:"Revocation"`
+	}
+	if err := xml.NewDecoder(r).Decode(&document); err != nil {
+		return err
+	}
+	return s.addRecords(document.Keys, document.Revocations)
+}
+
+// LoadCSV loads keys from a CSV file whose first line is a header. The columns
+// are identified by name, case insensitive: RID, Index, Modulus, Exponent,
+// Checksum, ExpiryDate, HashAlgorithm, KeyAlgorithm and SerialNumber. Only the
+// first four are mandatory. A line with a SerialNumber revokes the issuer
+// certificate with that serial number for the key, instead of adding a key.
+func (s *CAPublicKeyStore) LoadCSV(r io.Reader) error {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	reader.Comment = '#'
+
+	header, err := reader.Read()
+	if err != nil {
+		return err
+	}
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, mandatory := range []string{"rid", "index", "modulus", "exponent"} {
+		if _, ok := columns[mandatory]; !ok {
+			return fmt.Errorf("CSV column %q is missing", mandatory)
+		}
+	}
+
+	lines, err := reader.ReadAll()
+	if err != nil {
+		return err
+	}
+	var records []keyRecord
+	var revocations []revocationRecord
+	for _, line := range lines {
+		get := func(name string) string {
+			idx, ok := columns[name]
+			if !ok || idx >= len(line) {
+				return ""
+			}
+			return line[idx]
+		}
+		if serialNumber := get("serialnumber"); serialNumber != "" {
+			revocations = append(revocations, revocationRecord{
+				RID:          get("rid"),
+				Index:        get("index"),
+				SerialNumber: serialNumber,
+			})
+			continue
+		}
+		records = append(records, keyRecord{
+			RID:            get("rid"),
+			Index:          get("index"),
+			Modulus:        get("modulus"),
+			Exponent:       get("exponent"),
+			Checksum:       get("checksum"),
+			ExpirationDate: get("expirydate"),
+			HashAlgorithm:  get("hashalgorithm"),
+			KeyAlgorithm:   get("keyalgorithm"),
+		})
+	}
+	return s.addRecords(records, revocations)
+}
@@ -0,0 +1,133 @@
+package oda
+
+import (
+	"crypto/rsa"
+	"encoding/hex"
+	"encoding/json"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testRID = []byte{0xF0, 0x00, 0x00, 0x00, 0x01}
+
+// loadFixtureCAKey loads the private part of a test CA key, so that tests
+// can issue certificates verifiable with the keys in the fixture files.
+func loadFixtureCAKey(t *testing.T, index byte) *rsa.PrivateKey {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", "capk_private.json"))
+	require.NoError(t, err)
+
+	var document struct {
+		Keys []struct {
+			Index           string `json:"index"`
+			Modulus         string `json:"modulus"`
+			Exponent        string `json:"exponent"`
+			PrivateExponent string `json:"privateExponent"`
+		} `json:"keys"`
+	}
+	require.NoError(t, json.Unmarshal(data, &document))
+	for _, k := range document.Keys {
+		if k.Index != hex.EncodeToString([]byte{index}) {
+			continue
+		}
+		parse := func(s string) *big.Int {
+			n, ok := new(big.Int).SetString(s, 16)
+			require.True(t, ok)
+			return n
+		}
+		return &rsa.PrivateKey{
+			PublicKey: rsa.PublicKey{
+				N: parse(k.Modulus),
+				E: int(parse(k.Exponent).Int64()),
+			},
+			D: parse(k.PrivateExponent),
+		}
+	}
+	require.FailNow(t, "fixture key not found")
+	return nil
+}
+
+func loadFixtureStore(t *testing.T, name string) *CAPublicKeyStore {
+	t.Helper()
+	f, err := os.Open(filepath.Join("testdata", name))
+	require.NoError(t, err)
+	defer f.Close()
+
+	store := NewCAPublicKeyStore().WithClock(func() time.Time {
+		return time.Date(2030, 6, 15, 0, 0, 0, 0, time.UTC)
+	})
+	switch filepath.Ext(name) {
+	case ".json":
+		require.NoError(t, store.LoadJSON(f))
+	case ".xml":
+		require.NoError(t, store.LoadXML(f))
+	case ".csv":
+		require.NoError(t, store.LoadCSV(f))
+	}
+	return store
+}
+
+func TestCAPublicKeyStore_Loaders(t *testing.T) {
+	for _, name := range []string{"capk.json", "capk.xml", "capk.csv"} {
+		t.Run(name, func(t *testing.T) {
+			store := loadFixtureStore(t, name)
+			assert.Len(t, store.Keys(), 3)
+
+			key, err := store.Get(testRID, 0x01)
+			require.NoError(t, err)
+			assert.Len(t, key.Modulus, 176)
+			assert.Equal(t, []byte{0x03}, key.Exponent)
+			assert.Equal(t, time.Date(2049, 12, 31, 0, 0, 0, 0, time.UTC), key.ExpirationDate)
+
+			key, err = store.GetByIndexHex(append(testRID, 0x10, 0x10), "02")
+			require.NoError(t, err)
+			assert.Len(t, key.Modulus, 248)
+			assert.Equal(t, []byte{0x01, 0x00, 0x01}, key.Exponent)
+
+			_, err = store.Get(testRID, 0x03)
+			assert.ErrorIs(t, err, ErrCAPublicKeyExpired)
+
+			_, err = store.Get(testRID, 0x04)
+			assert.ErrorIs(t, err, ErrCAPublicKeyNotFound)
+		})
+	}
+}
+
+func TestCAPublicKeyStore_ChecksumMismatch(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "capk.csv"))
+	require.NoError(t, err)
+	lines := strings.Split(string(data), "\n")
+	lines[1] = strings.Replace(lines[1], ",03,", ",010001,", 1)
+
+	err = NewCAPublicKeyStore().LoadCSV(strings.NewReader(strings.Join(lines, "\n")))
+	assert.ErrorIs(t, err, ErrChecksumMismatch)
+}
+
+func TestCAPublicKeyStore_RecoverIssuerPublicKey(t *testing.T) {
+	store := loadFixtureStore(t, "capk.json")
+	ca := loadFixtureCAKey(t, 0x01)
+	issuer := generateKey(t, 1024)
+
+	t.Run("Valid", func(t *testing.T) {
+		certificate, remainder := issueIssuerCertificate(t, ca, issuer, "000001")
+		cert, err := store.RecoverIssuerPublicKey(testRID, 0x01, certificate, remainder, publicKeyOf(issuer).Exponent, testPAN)
+		require.NoError(t, err)
+		assert.Equal(t, publicKeyOf(issuer), cert.PublicKey)
+	})
+	t.Run("Revoked", func(t *testing.T) {
+		certificate, remainder := issueIssuerCertificate(t, ca, issuer, "000099")
+		for _, name := range []string{"capk.json", "capk.xml", "capk.csv"} {
+			t.Run(name, func(t *testing.T) {
+				_, err := loadFixtureStore(t, name).RecoverIssuerPublicKey(testRID, 0x01, certificate, remainder, publicKeyOf(issuer).Exponent, testPAN)
+				assert.ErrorIs(t, err, ErrCertificateRevoked)
+			})
+		}
+	})
+}
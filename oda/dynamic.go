@@ -0,0 +1,187 @@
+package oda
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"fmt"
+
+	"github.com/mniak/apdu"
+	"github.com/mniak/apdu/internal/ber"
+)
+
+type SignedDynamicData struct {
+	HashAlgorithm  byte
+	ICCDynamicData []byte
+}
+
+// ICCDynamicNumber returns the ICC Dynamic Number, which is the first element
+// of the ICC Dynamic Data.
+func (d SignedDynamicData) ICCDynamicNumber() []byte {
+	if len(d.ICCDynamicData) < 1 {
+		return nil
+	}
+	length := int(d.ICCDynamicData[0])
+	if len(d.ICCDynamicData) < 1+length {
+		return nil
+	}
+	return d.ICCDynamicData[1 : 1+length]
+}
+
+// RecoverSignedDynamicData recovers and validates the Signed Dynamic
+// Application Data as specified in EMV Book 2, section 6.5.2. For DDA the
+// terminalDynamicData is the data sent in the INTERNAL AUTHENTICATE command
+// and for CDA it is the Unpredictable Number.
+func RecoverSignedDynamicData(icc PublicKey, sdad, terminalDynamicData []byte) (SignedDynamicData, error) {
+	body, hash, err := recoverData(icc, sdad, formatSignedDynamicData)
+	if err != nil {
+		return SignedDynamicData{}, err
+	}
+
+	// Format (1), Hash Algorithm (1), ICC Dynamic Data Length (1),
+	// ICC Dynamic Data (LDD), Pad Pattern (NIC-LDD-25)
+	if len(body) < 3 {
+		return SignedDynamicData{}, ErrInvalidLength
+	}
+	dynamicDataLength := int(body[2])
+	if len(body) < 3+dynamicDataLength {
+		return SignedDynamicData{}, ErrInvalidLength
+	}
+
+	if err := checkHash(body[1], hash, body, terminalDynamicData); err != nil {
+		return SignedDynamicData{}, err
+	}
+
+	return SignedDynamicData{
+		HashAlgorithm:  body[1],
+		ICCDynamicData: body[3 : 3+dynamicDataLength],
+	}, nil
+}
+
+// VerifyDDA validates the response of INTERNAL AUTHENTICATE, which can be in
+// format 1 (tag 80) or format 2 (tag 77 containing tag 9F4B).
+func VerifyDDA(icc PublicKey, response, ddolData []byte) (SignedDynamicData, error) {
+	tlvs, err := ber.Parse(response)
+	if err != nil {
+		return SignedDynamicData{}, err
+	}
+
+	var sdad []byte
+	if format1, ok := ber.Find(tlvs, 0x80); ok {
+		sdad = format1.Value
+	} else if format2, ok := ber.Find(tlvs, 0x9F4B); ok {
+		sdad = format2.Value
+	} else {
+		return SignedDynamicData{}, ErrMissingSignedDynamicData
+	}
+
+	return RecoverSignedDynamicData(icc, sdad, ddolData)
+}
+
+// PerformDDA sends INTERNAL AUTHENTICATE with the DDOL data and verifies the
+// signature returned by the card.
+func PerformDDA(client apdu.LowLevelCommands, icc PublicKey, ddolData []byte) (SignedDynamicData, error) {
+	response, err := client.InternalAuthenticate(ddolData)
+	if err != nil {
+		return SignedDynamicData{}, err
+	}
+	return VerifyDDA(icc, response, ddolData)
+}
+
+type CDAInput struct {
+	UnpredictableNumber []byte
+	PDOLData            []byte
+	CDOL1Data           []byte
+	// CDOL2Data is only present for the second GENERATE AC.
+	CDOL2Data []byte
+}
+
+type CDAResult struct {
+	ICCDynamicNumber          []byte
+	CryptogramInformationData apdu.CryptogramInformationData
+	ApplicationCryptogram     []byte
+	TransactionDataHashCode   []byte
+}
+
+// VerifyCDA validates the format 2 response of a GENERATE AC sent with the CDA
+// signature requested, as specified in EMV Book 2, section 6.6.2, and
+// extracts the Application Cryptogram from the signed data.
+func VerifyCDA(icc PublicKey, response []byte, input CDAInput) (CDAResult, error) {
+	template, rest, err := ber.ParseOne(response)
+	if err != nil {
+		return CDAResult{}, err
+	}
+	if template.Tag != 0x77 || len(rest) > 0 {
+		return CDAResult{}, fmt.Errorf("%w: response is not a template 77", ErrInvalidFormat)
+	}
+
+	var sdad, cid []byte
+	hash := sha1.New()
+	hash.Write(input.PDOLData)
+	hash.Write(input.CDOL1Data)
+	hash.Write(input.CDOL2Data)
+
+	data := template.Value
+	for len(data) > 0 {
+		tlv, rest, err := ber.ParseOne(data)
+		if err != nil {
+			return CDAResult{}, err
+		}
+		raw := data[:len(data)-len(rest)]
+		data = rest
+
+		switch tlv.Tag {
+		case 0x9F4B:
+			sdad = tlv.Value
+			continue
+		case 0x9F27:
+			cid = tlv.Value
+		}
+		hash.Write(raw)
+	}
+	if sdad == nil {
+		return CDAResult{}, ErrMissingSignedDynamicData
+	}
+
+	signed, err := RecoverSignedDynamicData(icc, sdad, input.UnpredictableNumber)
+	if err != nil {
+		return CDAResult{}, err
+	}
+
+	// ICC Dynamic Number Length (1), ICC Dynamic Number (2-8),
+	// Cryptogram Information Data (1), TC or ARQC (8),
+	// Transaction Data Hash Code (20)
+	dynamicNumber := signed.ICCDynamicNumber()
+	offset := 1 + len(dynamicNumber)
+	if dynamicNumber == nil || len(signed.ICCDynamicData) < offset+29 {
+		return CDAResult{}, ErrInvalidLength
+	}
+	result := CDAResult{
+		ICCDynamicNumber:          dynamicNumber,
+		CryptogramInformationData: apdu.CryptogramInformationData(signed.ICCDynamicData[offset]),
+		ApplicationCryptogram:     signed.ICCDynamicData[offset+1 : offset+9],
+		TransactionDataHashCode:   signed.ICCDynamicData[offset+9 : offset+29],
+	}
+
+	if len(cid) != 1 || cid[0] != byte(result.CryptogramInformationData) {
+		return result, ErrCryptogramInformationDiffer
+	}
+	if !bytes.Equal(hash.Sum(nil), result.TransactionDataHashCode) {
+		return result, ErrTransactionDataHashMismatch
+	}
+	return result, nil
+}
+
+// PerformCDA sends GENERATE AC with the CDA signature requested and verifies
+// the response. When CDOL2Data is present in the input, it is considered a
+// second GENERATE AC and the CDOL2 data is sent instead of the CDOL1 data.
+func PerformCDA(client apdu.LowLevelCommands, icc PublicKey, cryptogramType apdu.ApplicationCryptogramType, input CDAInput) (CDAResult, error) {
+	transactionData := input.CDOL1Data
+	if len(input.CDOL2Data) > 0 {
+		transactionData = input.CDOL2Data
+	}
+	response, err := client.GenerateACWithCDA(cryptogramType, transactionData)
+	if err != nil {
+		return CDAResult{}, err
+	}
+	return VerifyCDA(icc, response, input)
+}
@@ -0,0 +1,21 @@
+package oda
+
+import (
+	"errors"
+)
+
+var (
+	ErrInvalidLength               = errors.New("data length does not match the key modulus length")
+	ErrInvalidHeader               = errors.New("recovered data header is not 6A")
+	ErrInvalidTrailer              = errors.New("recovered data trailer is not BC")
+	ErrInvalidFormat               = errors.New("recovered data format is not the expected one")
+	ErrUnsupportedHashAlgorithm    = errors.New("hash algorithm is not supported")
+	ErrUnsupportedKeyAlgorithm     = errors.New("public key algorithm is not supported")
+	ErrHashMismatch                = errors.New("hash result does not match the recovered one")
+	ErrIssuerIdentifierMismatch    = errors.New("issuer identifier does not match the PAN")
+	ErrPANMismatch                 = errors.New("PAN in the certificate does not match the card PAN")
+	ErrMissingRemainder            = errors.New("public key remainder is missing")
+	ErrMissingSignedDynamicData    = errors.New("signed dynamic application data is missing")
+	ErrCryptogramInformationDiffer = errors.New("cryptogram information data differs from the signed one")
+	ErrTransactionDataHashMismatch = errors.New("transaction data hash code does not match")
+)
@@ -0,0 +1,186 @@
+package oda
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"testing"
+	"time"
+
+	"github.com/mniak/apdu/internal/ber"
+	"github.com/mniak/apdu/internal/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testPAN = "5413330089600010"
+
+func generateKey(t *testing.T, bits int) *rsa.PrivateKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, bits)
+	require.NoError(t, err)
+	return key
+}
+
+func publicKeyOf(key *rsa.PrivateKey) PublicKey {
+	return PublicKey{
+		Modulus:  key.N.Bytes(),
+		Exponent: test.RSAExponent(key),
+	}
+}
+
+type testCard struct {
+	ca, issuer, icc *rsa.PrivateKey
+
+	issuerCertificate, issuerRemainder []byte
+	iccCertificate, iccRemainder       []byte
+	staticData                         []byte
+}
+
+func issueIssuerCertificate(t *testing.T, ca, issuer *rsa.PrivateKey, serialNumber string) ([]byte, []byte) {
+	t.Helper()
+	return test.EMVCertificate(t, ca, issuer, test.MustParseHex(t, "02 541333FF 1249"+serialNumber+"01 01"), 36)
+}
+
+func newTestCard(t *testing.T) testCard {
+	var card testCard
+	card.ca = loadFixtureCAKey(t, 0x01)
+	card.issuer = generateKey(t, 1152)
+	card.icc = generateKey(t, 1024)
+
+	card.issuerCertificate, card.issuerRemainder = issueIssuerCertificate(t, card.ca, card.issuer, "000001")
+
+	card.staticData = test.MustParseHex(t, "5A085413330089600010 5F24031249315F25030401015F3401015F280200568C159F02069F03069F1A0295055F2A029A039C019F3704")
+	card.iccCertificate, card.iccRemainder = test.EMVCertificate(t, card.issuer, card.icc,
+		test.MustParseHex(t, "04 5413330089600010FFFF 1249 000002 01 01"), 42, card.staticData)
+
+	return card
+}
+
+func (card testCard) signDynamicData(t *testing.T, iccDynamicData, terminalDynamicData []byte) []byte {
+	body := append([]byte{0x05, 0x01, byte(len(iccDynamicData))}, iccDynamicData...)
+	return test.SignEMV(t, card.icc, body, terminalDynamicData)
+}
+
+func TestCertificateRecovery(t *testing.T) {
+	card := newTestCard(t)
+	ca := publicKeyOf(card.ca)
+
+	issuerCert, err := RecoverIssuerPublicKey(ca, card.issuerCertificate, card.issuerRemainder, publicKeyOf(card.issuer).Exponent, testPAN)
+	require.NoError(t, err)
+	assert.Equal(t, publicKeyOf(card.issuer), issuerCert.PublicKey)
+	assert.Equal(t, "541333", issuerCert.IssuerIdentifier)
+	assert.False(t, issuerCert.Expired(time.Date(2049, 12, 31, 23, 0, 0, 0, time.UTC)))
+	assert.True(t, issuerCert.Expired(time.Date(2050, 1, 1, 0, 0, 0, 0, time.UTC)))
+
+	iccCert, err := RecoverICCPublicKey(issuerCert.PublicKey, card.iccCertificate, card.iccRemainder, publicKeyOf(card.icc).Exponent, card.staticData, testPAN)
+	require.NoError(t, err)
+	assert.Equal(t, publicKeyOf(card.icc), iccCert.PublicKey)
+	assert.Equal(t, testPAN, iccCert.PAN)
+
+	t.Run("Wrong PAN", func(t *testing.T) {
+		_, err := RecoverIssuerPublicKey(ca, card.issuerCertificate, card.issuerRemainder, publicKeyOf(card.issuer).Exponent, "4111111111111111")
+		assert.ErrorIs(t, err, ErrIssuerIdentifierMismatch)
+
+		_, err = RecoverICCPublicKey(issuerCert.PublicKey, card.iccCertificate, card.iccRemainder, publicKeyOf(card.icc).Exponent, card.staticData, "5413330089600011")
+		assert.ErrorIs(t, err, ErrPANMismatch)
+	})
+	t.Run("Tampered static data", func(t *testing.T) {
+		tampered := append([]byte{}, card.staticData...)
+		tampered[3] ^= 0x01
+		_, err := RecoverICCPublicKey(issuerCert.PublicKey, card.iccCertificate, card.iccRemainder, publicKeyOf(card.icc).Exponent, tampered, testPAN)
+		assert.ErrorIs(t, err, ErrHashMismatch)
+	})
+	t.Run("Wrong key", func(t *testing.T) {
+		_, err := RecoverIssuerPublicKey(publicKeyOf(card.issuer), card.iccCertificate, nil, publicKeyOf(card.icc).Exponent, testPAN)
+		assert.Error(t, err)
+	})
+}
+
+func TestVerifyDDA(t *testing.T) {
+	card := newTestCard(t)
+	ddolData := test.MustParseHex(t, "01020304")
+	sdad := card.signDynamicData(t, test.MustParseHex(t, "08 1122334455667788"), ddolData)
+
+	t.Run("Format 1", func(t *testing.T) {
+		result, err := VerifyDDA(publicKeyOf(card.icc), ber.Encode(0x80, sdad), ddolData)
+		require.NoError(t, err)
+		test.AssertBytesEqual(t, "1122334455667788", result.ICCDynamicNumber())
+	})
+	t.Run("Format 2", func(t *testing.T) {
+		result, err := VerifyDDA(publicKeyOf(card.icc), ber.Encode(0x77, ber.Encode(0x9F4B, sdad)), ddolData)
+		require.NoError(t, err)
+		test.AssertBytesEqual(t, "1122334455667788", result.ICCDynamicNumber())
+	})
+	t.Run("Wrong DDOL data", func(t *testing.T) {
+		_, err := VerifyDDA(publicKeyOf(card.icc), ber.Encode(0x80, sdad), test.MustParseHex(t, "01020305"))
+		assert.ErrorIs(t, err, ErrHashMismatch)
+	})
+}
+
+func TestVerifyCDA(t *testing.T) {
+	card := newTestCard(t)
+	input := CDAInput{
+		UnpredictableNumber: test.MustParseHex(t, "CAFEBABE"),
+		PDOLData:            test.MustParseHex(t, "0076"),
+		CDOL1Data:           test.MustParseHex(t, "000000001000 000000000000 0076 0000000000 0986 240101 00 CAFEBABE"),
+	}
+	cid := ber.Encode(0x9F27, []byte{0x80})
+	atc := ber.Encode(0x9F36, test.MustParseHex(t, "0042"))
+	iad := ber.Encode(0x9F10, test.MustParseHex(t, "0110A00000"))
+
+	h := sha1.New()
+	h.Write(input.PDOLData)
+	h.Write(input.CDOL1Data)
+	h.Write(cid)
+	h.Write(atc)
+	h.Write(iad)
+
+	var dynamicData bytes.Buffer
+	dynamicData.Write(test.MustParseHex(t, "04 01020304 80 0102030405060708"))
+	dynamicData.Write(h.Sum(nil))
+	sdad := card.signDynamicData(t, dynamicData.Bytes(), input.UnpredictableNumber)
+
+	buildResponse := func(cid []byte) []byte {
+		var content bytes.Buffer
+		content.Write(cid)
+		content.Write(atc)
+		content.Write(ber.Encode(0x9F4B, sdad))
+		content.Write(iad)
+		return ber.Encode(0x77, content.Bytes())
+	}
+
+	result, err := VerifyCDA(publicKeyOf(card.icc), buildResponse(cid), input)
+	require.NoError(t, err)
+	test.AssertBytesEqual(t, "01020304", result.ICCDynamicNumber)
+	test.AssertBytesEqual(t, "0102030405060708", result.ApplicationCryptogram)
+	assert.True(t, result.CryptogramInformationData.ARQC())
+
+	t.Run("Different CID", func(t *testing.T) {
+		_, err := VerifyCDA(publicKeyOf(card.icc), buildResponse(ber.Encode(0x9F27, []byte{0x40})), input)
+		assert.ErrorIs(t, err, ErrCryptogramInformationDiffer)
+	})
+	t.Run("Different transaction data", func(t *testing.T) {
+		changed := input
+		changed.PDOLData = test.MustParseHex(t, "0986")
+		_, err := VerifyCDA(publicKeyOf(card.icc), buildResponse(cid), changed)
+		assert.ErrorIs(t, err, ErrTransactionDataHashMismatch)
+	})
+	t.Run("Different unpredictable number", func(t *testing.T) {
+		changed := input
+		changed.UnpredictableNumber = test.MustParseHex(t, "CAFEBABA")
+		_, err := VerifyCDA(publicKeyOf(card.icc), buildResponse(cid), changed)
+		assert.ErrorIs(t, err, ErrHashMismatch)
+	})
+}
+
+func TestStaticData(t *testing.T) {
+	var sd StaticData
+	require.NoError(t, sd.AddRecord(2, test.MustParseHex(t, "70 04 5A02 1234")))
+	require.NoError(t, sd.AddRecord(11, test.MustParseHex(t, "70 03 9F0800")))
+	sd.AddSDATagListValue(test.MustParseHex(t, "3900"))
+	test.AssertBytesEqual(t, "5A02123470039F08003900", sd.Bytes())
+
+	assert.Error(t, sd.AddRecord(1, test.MustParseHex(t, "71 00")))
+}
@@ -0,0 +1,37 @@
+package oda
+
+import (
+	"errors"
+	"math/big"
+)
+
+type PublicKey struct {
+	Modulus  []byte
+	Exponent []byte
+}
+
+func (k PublicKey) Length() int {
+	return len(k.Modulus)
+}
+
+// recover applies the RSA public key operation to the data, which must have
+// the same length as the modulus.
+func (k PublicKey) recover(data []byte) ([]byte, error) {
+	if len(k.Modulus) == 0 || len(k.Exponent) == 0 {
+		return nil, errors.New("public key is empty")
+	}
+	if len(data) != len(k.Modulus) {
+		return nil, ErrInvalidLength
+	}
+
+	n := new(big.Int).SetBytes(k.Modulus)
+	e := new(big.Int).SetBytes(k.Exponent)
+	x := new(big.Int).SetBytes(data)
+	if x.Cmp(n) >= 0 {
+		return nil, ErrInvalidLength
+	}
+	x.Exp(x, e, n)
+
+	result := make([]byte, len(k.Modulus))
+	return x.FillBytes(result), nil
+}
@@ -0,0 +1,209 @@
+package oda
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	HashAlgorithmSHA1 = 0x01
+	KeyAlgorithmRSA   = 0x01
+)
+
+const (
+	formatIssuerPublicKeyCertificate = 0x02
+	formatSignedStaticData           = 0x03
+	formatICCPublicKeyCertificate    = 0x04
+	formatSignedDynamicData          = 0x05
+)
+
+// recoverData recovers the signed data with the public key and checks
+// header, trailer, format and hash algorithm. It returns the recovered
+// data between header and the hash result, and the hash result.
+func recoverData(key PublicKey, signed []byte, format byte) ([]byte, []byte, error) {
+	recovered, err := key.recover(signed)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(recovered) < 22 {
+		return nil, nil, ErrInvalidLength
+	}
+	if recovered[0] != 0x6A {
+		return nil, nil, ErrInvalidHeader
+	}
+	if recovered[len(recovered)-1] != 0xBC {
+		return nil, nil, ErrInvalidTrailer
+	}
+	if recovered[1] != format {
+		return nil, nil, fmt.Errorf("%w: expected %02X but got %02X", ErrInvalidFormat, format, recovered[1])
+	}
+
+	body := recovered[1 : len(recovered)-21]
+	hash := recovered[len(recovered)-21 : len(recovered)-1]
+	return body, hash, nil
+}
+
+func checkHash(algorithm byte, expected []byte, data ...[]byte) error {
+	if algorithm != HashAlgorithmSHA1 {
+		return fmt.Errorf("%w: %02X", ErrUnsupportedHashAlgorithm, algorithm)
+	}
+	h := sha1.New()
+	for _, d := range data {
+		h.Write(d)
+	}
+	if !bytes.Equal(h.Sum(nil), expected) {
+		return ErrHashMismatch
+	}
+	return nil
+}
+
+// parseExpirationDate parses a date in the format MMYY (n 4) and returns the
+// last instant of that month.
+func parseExpirationDate(mmyy []byte) (time.Time, error) {
+	str := fmt.Sprintf("%02X", mmyy)
+	t, err := time.Parse("0106", str)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid expiration date %s: %w", str, err)
+	}
+	return t.AddDate(0, 1, 0).Add(-time.Nanosecond), nil
+}
+
+func trimPadding(digits string) string {
+	return strings.TrimRight(strings.ToUpper(digits), "F")
+}
+
+type certificateInfo struct {
+	ExpirationDate     time.Time
+	SerialNumber       []byte
+	HashAlgorithm      byte
+	PublicKeyAlgorithm byte
+}
+
+// Expired reports whether the certificate is no longer valid at the given
+// instant. A certificate is valid up to the last day of its expiration month.
+func (c certificateInfo) Expired(now time.Time) bool {
+	return now.After(c.ExpirationDate)
+}
+
+type IssuerPublicKeyCertificate struct {
+	certificateInfo
+	IssuerIdentifier string
+	PublicKey        PublicKey
+}
+
+// RecoverIssuerPublicKey recovers and validates the Issuer Public Key from its
+// certificate as specified in EMV Book 2, section 5.3 and 6.3. The
+// certificate expiration date is not checked, use the Expired method on the
+// result.
+func RecoverIssuerPublicKey(ca PublicKey, certificate, remainder, exponent []byte, pan string) (IssuerPublicKeyCertificate, error) {
+	body, hash, err := recoverData(ca, certificate, formatIssuerPublicKeyCertificate)
+	if err != nil {
+		return IssuerPublicKeyCertificate{}, err
+	}
+
+	// Format (1), Issuer Identifier (4), Expiration Date (2), Serial Number (3),
+	// Hash Algorithm (1), Public Key Algorithm (1), Public Key Length (1),
+	// Public Key Exponent Length (1), Public Key or leftmost digits (NCA-36)
+	const headerLength = 14
+	if len(body) < headerLength {
+		return IssuerPublicKeyCertificate{}, ErrInvalidLength
+	}
+
+	if err := checkHash(body[10], hash, body, remainder, exponent); err != nil {
+		return IssuerPublicKeyCertificate{}, err
+	}
+
+	var result IssuerPublicKeyCertificate
+	result.IssuerIdentifier = trimPadding(fmt.Sprintf("%02X", body[1:5]))
+	if !strings.HasPrefix(trimPadding(pan), result.IssuerIdentifier) || len(result.IssuerIdentifier) < 3 {
+		return result, ErrIssuerIdentifierMismatch
+	}
+	result.ExpirationDate, err = parseExpirationDate(body[5:7])
+	if err != nil {
+		return result, err
+	}
+	result.SerialNumber = body[7:10]
+	result.HashAlgorithm = body[10]
+	result.PublicKeyAlgorithm = body[11]
+	if result.PublicKeyAlgorithm != KeyAlgorithmRSA {
+		return result, fmt.Errorf("%w: %02X", ErrUnsupportedKeyAlgorithm, result.PublicKeyAlgorithm)
+	}
+
+	result.PublicKey, err = assembleKey(body[14:], int(body[12]), remainder, exponent, int(body[13]))
+	return result, err
+}
+
+type ICCPublicKeyCertificate struct {
+	certificateInfo
+	PAN       string
+	PublicKey PublicKey
+}
+
+// RecoverICCPublicKey recovers and validates the ICC Public Key from its
+// certificate as specified in EMV Book 2, section 6.4. The staticData is the
+// Static Data to be Authenticated, built from the records identified by the
+// AFL and the SDA Tag List. The certificate expiration date is not checked,
+// use the Expired method on the result.
+func RecoverICCPublicKey(issuer PublicKey, certificate, remainder, exponent, staticData []byte, pan string) (ICCPublicKeyCertificate, error) {
+	body, hash, err := recoverData(issuer, certificate, formatICCPublicKeyCertificate)
+	if err != nil {
+		return ICCPublicKeyCertificate{}, err
+	}
+
+	// Format (1), Application PAN (10), Expiration Date (2), Serial Number (3),
+	// Hash Algorithm (1), Public Key Algorithm (1), Public Key Length (1),
+	// Public Key Exponent Length (1), Public Key or leftmost digits (NI-42)
+	const headerLength = 20
+	if len(body) < headerLength {
+		return ICCPublicKeyCertificate{}, ErrInvalidLength
+	}
+
+	if err := checkHash(body[16], hash, body, remainder, exponent, staticData); err != nil {
+		return ICCPublicKeyCertificate{}, err
+	}
+
+	var result ICCPublicKeyCertificate
+	result.PAN = trimPadding(fmt.Sprintf("%02X", body[1:11]))
+	if result.PAN != trimPadding(pan) {
+		return result, ErrPANMismatch
+	}
+	result.ExpirationDate, err = parseExpirationDate(body[11:13])
+	if err != nil {
+		return result, err
+	}
+	result.SerialNumber = body[13:16]
+	result.HashAlgorithm = body[16]
+	result.PublicKeyAlgorithm = body[17]
+	if result.PublicKeyAlgorithm != KeyAlgorithmRSA {
+		return result, fmt.Errorf("%w: %02X", ErrUnsupportedKeyAlgorithm, result.PublicKeyAlgorithm)
+	}
+
+	result.PublicKey, err = assembleKey(body[20:], int(body[18]), remainder, exponent, int(body[19]))
+	return result, err
+}
+
+// assembleKey joins the leftmost digits of the key found in the certificate
+// with the remainder, discarding the BB padding when the key is shorter than
+// the space available in the certificate.
+func assembleKey(leftmost []byte, keyLength int, remainder, exponent []byte, exponentLength int) (PublicKey, error) {
+	if len(exponent) != exponentLength {
+		return PublicKey{}, fmt.Errorf("public key exponent has %d bytes but the certificate says %d", len(exponent), exponentLength)
+	}
+
+	var modulus []byte
+	if keyLength <= len(leftmost) {
+		modulus = leftmost[:keyLength]
+	} else {
+		if len(leftmost)+len(remainder) != keyLength {
+			return PublicKey{}, ErrMissingRemainder
+		}
+		modulus = append(append([]byte{}, leftmost...), remainder...)
+	}
+	return PublicKey{
+		Modulus:  modulus,
+		Exponent: exponent,
+	}, nil
+}
@@ -0,0 +1,47 @@
+package oda
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/mniak/apdu/internal/ber"
+)
+
+// StaticData accumulates the Static Data to be Authenticated (EMV Book 3,
+// section 10.3) from the records read during application selection.
+type StaticData struct {
+	buffer bytes.Buffer
+}
+
+// AddRecord appends a record read from a file and marked by the AFL as
+// participating in offline data authentication.
+//
+// For files with SFI in the range 1 to 10, only the content of the template
+// 70 is used. For files with SFI in the range 11 to 30, the whole record is
+// used.
+func (s *StaticData) AddRecord(sfi int, record []byte) error {
+	if sfi > 10 {
+		s.buffer.Write(record)
+		return nil
+	}
+
+	tlv, rest, err := ber.ParseOne(record)
+	if err != nil {
+		return err
+	}
+	if tlv.Tag != 0x70 || len(rest) > 0 {
+		return fmt.Errorf("record of SFI %d is not coded as a template 70", sfi)
+	}
+	s.buffer.Write(tlv.Value)
+	return nil
+}
+
+// AddSDATagListValue appends the value of a data object listed in the Static
+// Data Authentication Tag List (9F4A). EMV only allows the AIP to be listed.
+func (s *StaticData) AddSDATagListValue(value []byte) {
+	s.buffer.Write(value)
+}
+
+func (s *StaticData) Bytes() []byte {
+	return s.buffer.Bytes()
+}
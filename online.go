@@ -0,0 +1,102 @@
+package apdu
+
+import (
+	"errors"
+)
+
+const (
+	TagAuthorisationResponseCode Tag = 0x8A
+	TagIssuerAuthenticationData  Tag = 0x91
+	TagTVR                       Tag = 0x95
+	TagTSI                       Tag = 0x9B
+)
+
+// OnlineResponse is the data received from the issuer in the authorisation
+// response that must be delivered to the card.
+type OnlineResponse struct {
+	Approved                  bool
+	AuthorisationResponseCode []byte
+	IssuerAuthenticationData  []byte
+	IssuerScripts             []IssuerScript
+}
+
+type OnlineCompletion struct {
+	Response GenerateACResponse
+
+	// IssuerAuthenticationPerformed indicates that the Issuer Authentication
+	// Data was sent to the card, either with EXTERNAL AUTHENTICATE or inside
+	// the CDOL2 data.
+	IssuerAuthenticationPerformed bool
+	// IssuerAuthenticationFailed indicates that the card rejected the EXTERNAL
+	// AUTHENTICATE command.
+	IssuerAuthenticationFailed bool
+
+	ScriptResults IssuerScriptResults
+	TVR           TVR
+	TSI           TSI
+}
+
+// CompleteOnlineTransaction runs the completion steps of EMV Book 3, sections
+// 10.9 to 10.11: issuer authentication, scripts of template 71, the final
+// GENERATE AC and scripts of template 72. EXTERNAL AUTHENTICATE is only sent
+// when the AIP indicates that issuer authentication is supported. The TVR (95)
+// and TSI (9B) found in the terminal data are updated and returned in the
+// result.
+func (c _HighLevelClient) CompleteOnlineTransaction(aip AIP, cdol2 DataObjectList, terminalData map[Tag][]byte, response OnlineResponse) (OnlineCompletion, error) {
+	result := OnlineCompletion{
+		TVR: ParseTVR(terminalData[TagTVR]),
+		TSI: ParseTSI(terminalData[TagTSI]),
+	}
+
+	values := make(map[Tag][]byte, len(terminalData)+2)
+	for tag, value := range terminalData {
+		values[tag] = value
+	}
+	if len(response.AuthorisationResponseCode) > 0 {
+		values[TagAuthorisationResponseCode] = response.AuthorisationResponseCode
+	}
+
+	// When the card does not request the Issuer Authentication Data in CDOL2,
+	// it is delivered with EXTERNAL AUTHENTICATE.
+	if len(response.IssuerAuthenticationData) > 0 {
+		if cdol2.Contains(TagIssuerAuthenticationData) {
+			result.IssuerAuthenticationPerformed = true
+			result.TSI.Set(TSIIssuerAuthenticationPerformed)
+			values[TagIssuerAuthenticationData] = response.IssuerAuthenticationData
+		} else if aip.IssuerAuthenticationSupported() {
+			result.IssuerAuthenticationPerformed = true
+			result.TSI.Set(TSIIssuerAuthenticationPerformed)
+			err := c.ExternalAuthenticate(response.IssuerAuthenticationData)
+			if errors.Is(err, ErrVerificationFailed) {
+				result.IssuerAuthenticationFailed = true
+				result.TVR.Set(TVRIssuerAuthenticationFailed)
+			} else if err != nil {
+				return result, err
+			}
+		}
+	}
+
+	scriptResults, err := ExecuteIssuerScripts(c.Raw, response.IssuerScripts, TagIssuerScriptTemplate1, &result.TVR, &result.TSI)
+	result.ScriptResults = append(result.ScriptResults, scriptResults...)
+	if err != nil {
+		return result, err
+	}
+	values[TagTVR] = result.TVR[:]
+	values[TagTSI] = result.TSI[:]
+
+	cryptogramType := AAC
+	if response.Approved {
+		cryptogramType = TC
+	}
+
+	result.Response, err = unmarshal[GenerateACResponse](
+		c.Low.GenerateAC(cryptogramType, cdol2.Build(values)),
+	)
+	if err != nil {
+		return result, err
+	}
+
+	scriptResults, err = ExecuteIssuerScripts(c.Raw, response.IssuerScripts, TagIssuerScriptTemplate2, &result.TVR, &result.TSI)
+	result.ScriptResults = append(result.ScriptResults, scriptResults...)
+	return result, err
+}
@@ -0,0 +1,136 @@
+package apdu
+
+import (
+	"testing"
+
+	"github.com/mniak/apdu/internal/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	gomock "go.uber.org/mock/gomock"
+)
+
+func TestCompleteOnlineTransaction(t *testing.T) {
+	iad := test.MustParseHex(t, "1122334455667788 3030")
+	genACResponse := Response{
+		Data:    test.MustParseHex(t, "800B 40 0042 0102030405060708"),
+		Trailer: 0x9000,
+	}
+
+	t.Run("Issuer authentication with EXTERNAL AUTHENTICATE", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		mockClient := NewMockRawClient(ctrl)
+		gomock.InOrder(
+			mockClient.EXPECT().
+				SendCommand(gomock.Any()).
+				Do(func(cmd Command) {
+					assert.Equal(t, Instruction82_ExternalMutualAuthenticate, cmd.Instruction)
+					assert.Equal(t, iad, cmd.Data)
+				}).
+				Return(Response{Trailer: 0x6300}, nil),
+			mockClient.EXPECT().
+				SendCommand(gomock.Any()).
+				Do(func(cmd Command) {
+					assert.Equal(t, EMVInstructionAE_GenerateAC, cmd.Instruction)
+					assert.Equal(t, byte(0x40), cmd.Parameters.P1)
+					test.AssertBytesEqual(t, "3030CAFEBABE", cmd.Data)
+				}).
+				Return(genACResponse, nil),
+		)
+
+		client := _HighLevelClient{Raw: mockClient, Low: _LowLevelClient{RawClient: mockClient}}
+		cdol2 := DataObjectList{{Tag: 0x8A, Length: 2}, {Tag: 0x9F37, Length: 4}}
+		result, err := client.CompleteOnlineTransaction(AIP{0x3C, 0x00}, cdol2, map[Tag][]byte{
+			0x9F37: {0xCA, 0xFE, 0xBA, 0xBE},
+		}, OnlineResponse{
+			Approved:                  true,
+			AuthorisationResponseCode: []byte("00"),
+			IssuerAuthenticationData:  iad,
+		})
+		require.NoError(t, err)
+		assert.True(t, result.IssuerAuthenticationPerformed)
+		assert.True(t, result.IssuerAuthenticationFailed)
+	})
+
+	t.Run("Issuer authentication data in CDOL2", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		mockClient := NewMockRawClient(ctrl)
+		mockClient.EXPECT().
+			SendCommand(gomock.Any()).
+			Do(func(cmd Command) {
+				assert.Equal(t, EMVInstructionAE_GenerateAC, cmd.Instruction)
+				assert.Equal(t, byte(0x00), cmd.Parameters.P1)
+				test.AssertBytesEqual(t, "3035112233445566778830300000", cmd.Data)
+			}).
+			Return(genACResponse, nil)
+
+		client := _HighLevelClient{Raw: mockClient, Low: _LowLevelClient{RawClient: mockClient}}
+		cdol2 := DataObjectList{{Tag: 0x8A, Length: 2}, {Tag: 0x91, Length: 10}, {Tag: 0x95, Length: 2}}
+		result, err := client.CompleteOnlineTransaction(AIP{0x3C, 0x00}, cdol2, nil, OnlineResponse{
+			Approved:                  false,
+			AuthorisationResponseCode: []byte("05"),
+			IssuerAuthenticationData:  iad,
+		})
+		require.NoError(t, err)
+		assert.True(t, result.IssuerAuthenticationPerformed)
+		assert.False(t, result.IssuerAuthenticationFailed)
+	})
+
+	t.Run("Issuer authentication not supported", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		mockClient := NewMockRawClient(ctrl)
+		mockClient.EXPECT().
+			SendCommand(gomock.Any()).
+			Do(func(cmd Command) {
+				assert.Equal(t, EMVInstructionAE_GenerateAC, cmd.Instruction)
+			}).
+			Return(genACResponse, nil)
+
+		client := _HighLevelClient{Raw: mockClient, Low: _LowLevelClient{RawClient: mockClient}}
+		cdol2 := DataObjectList{{Tag: 0x8A, Length: 2}}
+		result, err := client.CompleteOnlineTransaction(AIP{0x38, 0x00}, cdol2, nil, OnlineResponse{
+			Approved:                  true,
+			AuthorisationResponseCode: []byte("00"),
+			IssuerAuthenticationData:  iad,
+		})
+		require.NoError(t, err)
+		assert.False(t, result.IssuerAuthenticationPerformed)
+		assert.False(t, result.TSI.Has(TSIIssuerAuthenticationPerformed))
+	})
+
+	t.Run("Commands are sent in the order of EMV Book 3", func(t *testing.T) {
+		scripts, err := ParseIssuerScripts(test.MustParseHex(t, ""+
+			"72 07 86 05 8416000000"+
+			"71 07 86 05 841E000000"))
+		require.NoError(t, err)
+
+		ctrl := gomock.NewController(t)
+		mockClient := NewMockRawClient(ctrl)
+		var sent []Instruction
+		mockClient.EXPECT().
+			SendCommand(gomock.Any()).
+			DoAndReturn(func(cmd Command) (Response, error) {
+				sent = append(sent, cmd.Instruction)
+				if cmd.Instruction == EMVInstructionAE_GenerateAC {
+					return genACResponse, nil
+				}
+				return Response{Trailer: 0x9000}, nil
+			}).
+			Times(4)
+
+		client := _HighLevelClient{Raw: mockClient, Low: _LowLevelClient{RawClient: mockClient}}
+		cdol2 := DataObjectList{{Tag: 0x8A, Length: 2}}
+		_, err = client.CompleteOnlineTransaction(AIP{0x3C, 0x00}, cdol2, nil, OnlineResponse{
+			Approved:                  true,
+			AuthorisationResponseCode: []byte("00"),
+			IssuerAuthenticationData:  iad,
+			IssuerScripts:             scripts,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []Instruction{
+			Instruction82_ExternalMutualAuthenticate,
+			0x1E,
+			EMVInstructionAE_GenerateAC,
+			0x16,
+		}, sent)
+	})
+}
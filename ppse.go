@@ -0,0 +1,215 @@
+package apdu
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/mniak/apdu/internal/ber"
+)
+
+const (
+	PSEName  = "1PAY.SYS.DDF01"
+	PPSEName = "2PAY.SYS.DDF01"
+)
+
+// Kernel IDs defined in EMV Book B.
+const (
+	Kernel1 byte = 1 + iota
+	Kernel2
+	Kernel3
+	Kernel4
+	Kernel5
+	Kernel6
+	Kernel7
+)
+
+// KernelIdentifier is the value of the tag 9F2A.
+type KernelIdentifier []byte
+
+// International tells whether the kernel is an international kernel, whose ID
+// is the short kernel ID only.
+func (id KernelIdentifier) International() bool {
+	return len(id) > 0 && id[0]>>6 == 0b00
+}
+
+// ShortKernelID returns the bits 6 to 1 of the first byte.
+func (id KernelIdentifier) ShortKernelID() byte {
+	if len(id) == 0 {
+		return 0
+	}
+	return id[0] & 0b0011_1111
+}
+
+// RequestedKernelID returns the Kernel ID used in the combination selection:
+// the first byte for international kernels and the first three bytes for
+// domestic kernels.
+func (id KernelIdentifier) RequestedKernelID() []byte {
+	switch {
+	case len(id) == 0:
+		return nil
+	case id.International() || len(id) < 3:
+		return []byte{id[0]}
+	default:
+		return id[:3]
+	}
+}
+
+var defaultKernels = []struct {
+	rid    []byte
+	kernel byte
+}{
+	{[]byte{0xA0, 0x00, 0x00, 0x00, 0x04}, Kernel2},
+	{[]byte{0xA0, 0x00, 0x00, 0x00, 0x03}, Kernel3},
+	{[]byte{0xA0, 0x00, 0x00, 0x00, 0x25}, Kernel4},
+	{[]byte{0xA0, 0x00, 0x00, 0x00, 0x65}, Kernel5},
+	{[]byte{0xA0, 0x00, 0x00, 0x01, 0x52}, Kernel6},
+	{[]byte{0xA0, 0x00, 0x00, 0x03, 0x33}, Kernel7},
+}
+
+// DefaultKernelID returns the kernel used when the directory entry does not
+// have a Kernel Identifier, based on the RID of the ADF name, or zero.
+func DefaultKernelID(adfName []byte) byte {
+	for _, d := range defaultKernels {
+		if bytes.HasPrefix(adfName, d.rid) {
+			return d.kernel
+		}
+	}
+	return 0
+}
+
+// DirectoryEntry is an Application Template (61) of the PPSE.
+type DirectoryEntry struct {
+	ADFName           []byte
+	Label             string
+	PriorityIndicator byte
+	KernelIdentifier  KernelIdentifier
+	ExtendedSelection []byte
+}
+
+// Priority returns the application priority, where 1 is the highest and 0
+// means no priority.
+func (e DirectoryEntry) Priority() int {
+	return int(e.PriorityIndicator & 0x0F)
+}
+
+// RequestedKernelID returns the Kernel ID from the Kernel Identifier or the
+// default kernel for the RID when it is absent or its short kernel ID is zero.
+func (e DirectoryEntry) RequestedKernelID() []byte {
+	if e.KernelIdentifier.ShortKernelID() != 0 {
+		return e.KernelIdentifier.RequestedKernelID()
+	}
+	if kernel := DefaultKernelID(e.ADFName); kernel != 0 {
+		return []byte{kernel}
+	}
+	return nil
+}
+
+// ParsePPSE reads the directory entries from the FCI returned by the selection of the PPSE.
+func ParsePPSE(fci []byte) ([]DirectoryEntry, error) {
+	tlvs, err := ber.Parse(fci)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse PPSE: %w", err)
+	}
+	discretionaryData, found := ber.Find(tlvs, 0xBF0C)
+	if !found {
+		return nil, errors.New("PPSE FCI does not contain the issuer discretionary data (BF0C)")
+	}
+	children, err := discretionaryData.Children()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse PPSE directory: %w", err)
+	}
+
+	var entries []DirectoryEntry
+	for _, child := range children {
+		if child.Tag != 0x61 {
+			continue
+		}
+		fields, err := child.Children()
+		if err != nil {
+			return entries, fmt.Errorf("failed to parse PPSE directory entry: %w", err)
+		}
+		var entry DirectoryEntry
+		for _, f := range fields {
+			switch f.Tag {
+			case 0x4F:
+				entry.ADFName = f.Value
+			case 0x50:
+				entry.Label = string(f.Value)
+			case 0x87:
+				if len(f.Value) > 0 {
+					entry.PriorityIndicator = f.Value[0]
+				}
+			case 0x9F2A:
+				entry.KernelIdentifier = f.Value
+			case 0x9F29:
+				entry.ExtendedSelection = f.Value
+			}
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// Combination is an AID and kernel supported by the terminal, as configured
+// for the contactless entry point.
+type Combination struct {
+	AID      []byte
+	KernelID []byte
+	// ExtendedSelectionSupported allows the Extended Selection (9F29) to be
+	// appended to the ADF name in the final selection.
+	ExtendedSelectionSupported bool
+}
+
+// Candidate is an entry of the candidate list built by combination selection.
+type Candidate struct {
+	DirectoryEntry
+	Combination Combination
+	// SelectName is the DF name used in the final selection.
+	SelectName []byte
+}
+
+// KernelID returns the short kernel ID that must process the transaction.
+func (c Candidate) KernelID() byte {
+	return KernelIdentifier(c.Combination.KernelID).ShortKernelID()
+}
+
+// SelectCombinations builds the candidate list of EMV Book B, section 3.3.2,
+// matching the directory entries against the combinations supported by the
+// terminal. The candidates are sorted by priority keeping the directory order
+// for the same priority.
+func SelectCombinations(entries []DirectoryEntry, combinations []Combination) []Candidate {
+	var candidates []Candidate
+	for _, entry := range entries {
+		if len(entry.ADFName) < 5 || len(entry.ADFName) > 16 {
+			continue
+		}
+		requested := entry.RequestedKernelID()
+		for _, combination := range combinations {
+			if !bytes.HasPrefix(entry.ADFName, combination.AID) {
+				continue
+			}
+			if len(requested) > 0 && !bytes.Equal(requested, combination.KernelID) {
+				continue
+			}
+			selectName := entry.ADFName
+			if combination.ExtendedSelectionSupported && len(entry.ExtendedSelection) > 0 {
+				selectName = append(append([]byte{}, entry.ADFName...), entry.ExtendedSelection...)
+			}
+			candidates = append(candidates, Candidate{
+				DirectoryEntry: entry,
+				Combination:    combination,
+				SelectName:     selectName,
+			})
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		pi, pj := candidates[i].Priority(), candidates[j].Priority()
+		if pi == 0 || pj == 0 {
+			return pi != 0 && pj == 0
+		}
+		return pi < pj
+	})
+	return candidates
+}
@@ -0,0 +1,92 @@
+package apdu
+
+import (
+	"testing"
+
+	"github.com/mniak/apdu/internal/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	gomock "go.uber.org/mock/gomock"
+)
+
+const ppseFCIHex = "6F5D 840E325041592E5359532E4444463031 A54B BF0C48" +
+	"611C 4F07A0000000041010 500A4D415354455243415244 870102 9F2A0102" +
+	"6112 4F07A0000000031010 500456495341 870101" +
+	"6114 4F07A0000000251010 9F2A03C11234 9F2902ABCD"
+
+func TestParsePPSE(t *testing.T) {
+	entries, err := ParsePPSE(test.MustParseHex(t, ppseFCIHex))
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+
+	assert.Equal(t, test.MustParseHex(t, "A0000000041010"), entries[0].ADFName)
+	assert.Equal(t, "MASTERCARD", entries[0].Label)
+	assert.Equal(t, 2, entries[0].Priority())
+	assert.Equal(t, []byte{Kernel2}, entries[0].RequestedKernelID())
+
+	assert.Equal(t, "VISA", entries[1].Label)
+	assert.Empty(t, entries[1].KernelIdentifier)
+	assert.Equal(t, []byte{Kernel3}, entries[1].RequestedKernelID())
+
+	assert.False(t, entries[2].KernelIdentifier.International())
+	assert.Equal(t, []byte{0xC1, 0x12, 0x34}, entries[2].RequestedKernelID())
+	assert.Equal(t, []byte{0xAB, 0xCD}, entries[2].ExtendedSelection)
+}
+
+func TestDirectoryEntry_RequestedKernelID(t *testing.T) {
+	entry := DirectoryEntry{
+		ADFName:          test.MustParseHex(t, "A0000000041010"),
+		KernelIdentifier: KernelIdentifier{0x00},
+	}
+	assert.Equal(t, []byte{Kernel2}, entry.RequestedKernelID())
+
+	entry.ADFName = test.MustParseHex(t, "F0000000011010")
+	assert.Nil(t, entry.RequestedKernelID())
+}
+
+func TestSelectCombinations(t *testing.T) {
+	entries, err := ParsePPSE(test.MustParseHex(t, ppseFCIHex))
+	require.NoError(t, err)
+
+	candidates := SelectCombinations(entries, []Combination{
+		{AID: test.MustParseHex(t, "A0000000041010"), KernelID: []byte{Kernel3}},
+		{AID: test.MustParseHex(t, "A0000000041010"), KernelID: []byte{Kernel2}},
+		{AID: test.MustParseHex(t, "A000000003"), KernelID: []byte{Kernel3}},
+		{AID: test.MustParseHex(t, "A0000000251010"), KernelID: []byte{0xC1, 0x12, 0x34}, ExtendedSelectionSupported: true},
+	})
+	require.Len(t, candidates, 3)
+
+	assert.Equal(t, "VISA", candidates[0].Label)
+	assert.Equal(t, Kernel3, candidates[0].KernelID())
+	test.AssertBytesEqual(t, "A0000000031010", candidates[0].SelectName)
+
+	assert.Equal(t, "MASTERCARD", candidates[1].Label)
+	assert.Equal(t, Kernel2, candidates[1].KernelID())
+
+	test.AssertBytesEqual(t, "A0000000251010ABCD", candidates[2].SelectName)
+	assert.Equal(t, byte(0x01), candidates[2].KernelID())
+}
+
+func TestSelectPPSE(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mockClient := NewMockRawClient(ctrl)
+	mockClient.EXPECT().
+		SendCommand(gomock.Any()).
+		Do(func(cmd Command) {
+			assert.Equal(t, []byte(PPSEName), cmd.Data)
+		}).
+		Return(Response{
+			Data:    test.MustParseHex(t, ppseFCIHex),
+			Trailer: 0x9000,
+		}, nil)
+
+	client := _HighLevelClient{Raw: mockClient, Low: _LowLevelClient{RawClient: mockClient}}
+	records, err := client.GetPSE(true)
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+
+	applications := records[0].EMVProprietaryTemplates[0].ApplicationTemplates
+	require.Len(t, applications, 3)
+	assert.Equal(t, "VISA", *applications[1].Label)
+	assert.Equal(t, 1, *applications[1].PriorityIndicator)
+}
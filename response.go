@@ -35,3 +35,8 @@ func (r Response) HasMoreData() bool {
 func (r Response) HasWrongLength() bool {
 	return r.Trailer.SW1() == 0x6C
 }
+
+// Bytes encodes the response data followed by the status words.
+func (r Response) Bytes() []byte {
+	return append(append([]byte{}, r.Data...), r.Trailer.SW1(), r.Trailer.SW2())
+}
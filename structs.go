@@ -52,34 +52,36 @@ type RecordTemplate struct {
 type EMVProprietaryTemplate struct {
 	ApplicationTemplates []ApplicationTemplate `tlv:"61"`
 
-	Track1DiscretionaryData    string  `tlv:"9f1f"`
-	Track2EquivalentData       []byte  `tlv:"57"`
-	CardholderName             string  `tlv:"5f20"`
-	PAN                        string  `tlv:"5a,hex"`
-	PANSequenceNumber          string  `tlv:"5f34,hex"`
-	ExpirationDate             string  `tlv:"5f24,hex"`
-	UsageControl               string  `tlv:"9f07,hex"`
-	IssuerCountryCode          string  `tlv:"5f28,hex"`
-	EffectiveDate              string  `tlv:"5f25,hex"`
-	ServiceCode                string  `tlv:"5f30,hex"`
-	IssuerActionCodeDenial     string  `tlv:"9f0e,hex"`
-	IssuerActionCodeOnline     string  `tlv:"9f0f,hex"`
-	IssuerActionCodeDefault    string  `tlv:"9f0d,hex"`
-	CAPublicKeyIndex1          string  `tlv:"8f,hex"`
-	IssuerPublicKeyExponent    string  `tlv:"9f32,hex"`
-	IssuerPublicKeyCertificate string  `tlv:"90,hex"`
-	CurrencyCode               string  `tlv:"9f42,hex"`
-	CurrencyExponent           string  `tlv:"9f44,hex"`
-	CDOL1                      tlv.TL  `tlv:"8c"`
-	CDOL1Hex                   string  `tlv:"8c,hex"`
-	CDOL2                      tlv.TL  `tlv:"8d,hex"`
-	CDOL2Hex                   string  `tlv:"8d,hex"`
-	VersionNumber1             string  `tlv:"9f08,hex"`
-	ICCPublicKeyCertificate    string  `tlv:"9f46,hex"`
-	ICCPublicKeyExponent       string  `tlv:"9f47,hex"`
-	DDOL                       string  `tlv:"9f49,hex"`
-	CVMList                    CVMList `tlv:"8e"`
-	CVMListBytes               []byte  `tlv:"8e"`
+	Track1DiscretionaryData      string  `tlv:"9f1f"`
+	Track2EquivalentData         []byte  `tlv:"57"`
+	CardholderName               string  `tlv:"5f20"`
+	PAN                          string  `tlv:"5a,hex"`
+	PANSequenceNumber            string  `tlv:"5f34,hex"`
+	ExpirationDate               string  `tlv:"5f24,hex"`
+	UsageControl                 string  `tlv:"9f07,hex"`
+	IssuerCountryCode            string  `tlv:"5f28,hex"`
+	EffectiveDate                string  `tlv:"5f25,hex"`
+	ServiceCode                  string  `tlv:"5f30,hex"`
+	IssuerActionCodeDenial       string  `tlv:"9f0e,hex"`
+	IssuerActionCodeOnline       string  `tlv:"9f0f,hex"`
+	IssuerActionCodeDefault      string  `tlv:"9f0d,hex"`
+	CAPublicKeyIndex1            string  `tlv:"8f,hex"`
+	IssuerPublicKeyExponent      string  `tlv:"9f32,hex"`
+	IssuerPublicKeyCertificate   string  `tlv:"90,hex"`
+	CurrencyCode                 string  `tlv:"9f42,hex"`
+	CurrencyExponent             string  `tlv:"9f44,hex"`
+	CDOL1                        tlv.TL  `tlv:"8c"`
+	CDOL1Hex                     string  `tlv:"8c,hex"`
+	CDOL2                        tlv.TL  `tlv:"8d,hex"`
+	CDOL2Hex                     string  `tlv:"8d,hex"`
+	VersionNumber1               string  `tlv:"9f08,hex"`
+	ICCPublicKeyCertificate      string  `tlv:"9f46,hex"`
+	ICCPublicKeyExponent         string  `tlv:"9f47,hex"`
+	DDOL                         string  `tlv:"9f49,hex"`
+	CVMList                      CVMList `tlv:"8e"`
+	CVMListBytes                 []byte  `tlv:"8e"`
+	LowerConsecutiveOfflineLimit string  `tlv:"9f14,hex"`
+	UpperConsecutiveOfflineLimit string  `tlv:"9f23,hex"`
 
 	UnknownTag9F69 []byte  `tlv:"9f69"`
 	RawTLV         tlv.TLV `tlv:"raw"`
@@ -213,7 +215,12 @@ func (cv CVRule) ConditionString(amount1, amount2 int) string {
 	return "Invalid"
 }
 
+// Merge fills the empty fields with the values of other.
+//
+// Deprecated: use CardData, which keeps every data object read from the card
+// and detects duplicates.
 func (et EMVProprietaryTemplate) Merge(other EMVProprietaryTemplate) EMVProprietaryTemplate {
+	et.Track1DiscretionaryData = utils.CoalesceString(et.Track1DiscretionaryData, other.Track1DiscretionaryData)
 	if len(et.Track2EquivalentData) == 0 {
 		et.Track2EquivalentData = other.Track2EquivalentData
 	}
@@ -224,6 +231,7 @@ func (et EMVProprietaryTemplate) Merge(other EMVProprietaryTemplate) EMVPropriet
 	et.UsageControl = utils.CoalesceString(et.UsageControl, other.UsageControl)
 	et.IssuerCountryCode = utils.CoalesceString(et.IssuerCountryCode, other.IssuerCountryCode)
 	et.EffectiveDate = utils.CoalesceString(et.EffectiveDate, other.EffectiveDate)
+	et.ServiceCode = utils.CoalesceString(et.ServiceCode, other.ServiceCode)
 	et.IssuerActionCodeDenial = utils.CoalesceString(et.IssuerActionCodeDenial, other.IssuerActionCodeDenial)
 	et.IssuerActionCodeOnline = utils.CoalesceString(et.IssuerActionCodeOnline, other.IssuerActionCodeOnline)
 	et.IssuerActionCodeDefault = utils.CoalesceString(et.IssuerActionCodeDefault, other.IssuerActionCodeDefault)
@@ -239,6 +247,7 @@ func (et EMVProprietaryTemplate) Merge(other EMVProprietaryTemplate) EMVPropriet
 	if len(et.CDOL2) == 0 {
 		et.CDOL2 = other.CDOL2
 	}
+	et.CDOL2Hex = utils.CoalesceString(et.CDOL2Hex, other.CDOL2Hex)
 	et.VersionNumber1 = utils.CoalesceString(et.VersionNumber1, other.VersionNumber1)
 	et.ICCPublicKeyCertificate = utils.CoalesceString(et.ICCPublicKeyCertificate, other.ICCPublicKeyCertificate)
 	et.ICCPublicKeyExponent = utils.CoalesceString(et.ICCPublicKeyExponent, other.ICCPublicKeyExponent)
@@ -247,6 +256,8 @@ func (et EMVProprietaryTemplate) Merge(other EMVProprietaryTemplate) EMVPropriet
 	if len(et.CVMList.CVRules) == 0 {
 		et.CVMList = other.CVMList
 	}
+	et.LowerConsecutiveOfflineLimit = utils.CoalesceString(et.LowerConsecutiveOfflineLimit, other.LowerConsecutiveOfflineLimit)
+	et.UpperConsecutiveOfflineLimit = utils.CoalesceString(et.UpperConsecutiveOfflineLimit, other.UpperConsecutiveOfflineLimit)
 	if len(et.UnknownTag9F69) == 0 {
 		et.UnknownTag9F69 = other.UnknownTag9F69
 	}
@@ -427,6 +438,20 @@ func (f1 GenerateACResponseFormat1) IssuerApplicationData() []byte {
 	return f1[offset:]
 }
 
+type InternalAuthenticateResponse struct {
+	Format1 []byte `tlv:"80"`
+	Format2 struct {
+		SignedDynamicApplicationData []byte `tlv:"9f4b"`
+	} `tlv:"77"`
+}
+
+func (resp InternalAuthenticateResponse) SignedDynamicApplicationData() []byte {
+	if len(resp.Format1) > 0 {
+		return resp.Format1
+	}
+	return resp.Format2.SignedDynamicApplicationData
+}
+
 type CryptogramInformationData byte
 
 func (cid CryptogramInformationData) AAC() bool {
@@ -0,0 +1,217 @@
+package apdu
+
+// TagSource tells which entity provides the data object.
+type TagSource int
+
+const (
+	SourceICC TagSource = iota + 1
+	SourceTerminal
+	SourceIssuer
+)
+
+func (s TagSource) String() string {
+	switch s {
+	case SourceICC:
+		return "ICC"
+	case SourceTerminal:
+		return "Terminal"
+	case SourceIssuer:
+		return "Issuer"
+	default:
+		return "Unknown"
+	}
+}
+
+// TagFormat is the data element format as defined in EMV Book 3, section 4.3.
+type TagFormat string
+
+const (
+	FormatAlphabetic          TagFormat = "a"
+	FormatAlphanumeric        TagFormat = "an"
+	FormatAlphanumericSpecial TagFormat = "ans"
+	FormatBinary              TagFormat = "b"
+	FormatCompressedNumeric   TagFormat = "cn"
+	FormatNumeric             TagFormat = "n"
+)
+
+// TagInfo describes a data object. The lengths are in bytes and a MaxLength
+// of zero means that the length is not constrained by the specifications.
+type TagInfo struct {
+	Tag       Tag
+	Name      string
+	Source    TagSource
+	Format    TagFormat
+	MinLength int
+	MaxLength int
+	Templates []Tag
+}
+
+// ValidLength checks a value length against the constraints of the tag.
+func (info TagInfo) ValidLength(length int) bool {
+	if length < info.MinLength {
+		return false
+	}
+	return info.MaxLength == 0 || length <= info.MaxLength
+}
+
+// LookupTag finds a tag in the EMV and ISO 7816 dictionary.
+func LookupTag(tag Tag) (TagInfo, bool) {
+	info, found := tagDictionary[tag]
+	return info, found
+}
+
+// TagName returns the name of a tag in the dictionary, or an empty string.
+func TagName(tag Tag) string {
+	return tagDictionary[tag].Name
+}
+
+var tagDictionary = func() map[Tag]TagInfo {
+	result := make(map[Tag]TagInfo, len(tagList))
+	for _, info := range tagList {
+		result[info.Tag] = info
+	}
+	return result
+}()
+
+var (
+	inFCI           = []Tag{0xA5}
+	inRecord        = []Tag{0x70, 0x77}
+	inGACResponse   = []Tag{0x77, 0x80}
+	inApplication   = []Tag{0x61}
+	inIssuerScripts = []Tag{0x71, 0x72}
+)
+
+var tagList = []TagInfo{
+	{0x42, "Issuer Identification Number (IIN)", SourceICC, FormatNumeric, 3, 3, []Tag{0xBF0C}},
+	{0x4F, "Application Dedicated File (ADF) Name", SourceICC, FormatBinary, 5, 16, inApplication},
+	{0x50, "Application Label", SourceICC, FormatAlphanumericSpecial, 1, 16, []Tag{0x61, 0xA5}},
+	{0x56, "Track 1 Data", SourceICC, FormatBinary, 0, 76, inRecord},
+	{0x57, "Track 2 Equivalent Data", SourceICC, FormatBinary, 0, 19, inRecord},
+	{0x5A, "Application Primary Account Number (PAN)", SourceICC, FormatCompressedNumeric, 0, 10, inRecord},
+	{0x5F20, "Cardholder Name", SourceICC, FormatAlphanumericSpecial, 2, 26, inRecord},
+	{0x5F24, "Application Expiration Date", SourceICC, FormatNumeric, 3, 3, inRecord},
+	{0x5F25, "Application Effective Date", SourceICC, FormatNumeric, 3, 3, inRecord},
+	{0x5F28, "Issuer Country Code", SourceICC, FormatNumeric, 2, 2, inRecord},
+	{0x5F2A, "Transaction Currency Code", SourceTerminal, FormatNumeric, 2, 2, nil},
+	{0x5F2D, "Language Preference", SourceICC, FormatAlphanumeric, 2, 8, inFCI},
+	{0x5F30, "Service Code", SourceICC, FormatNumeric, 2, 2, inRecord},
+	{0x5F34, "Application PAN Sequence Number", SourceICC, FormatNumeric, 1, 1, inRecord},
+	{0x5F36, "Transaction Currency Exponent", SourceTerminal, FormatNumeric, 1, 1, nil},
+	{0x5F50, "Issuer URL", SourceICC, FormatAlphanumericSpecial, 0, 0, []Tag{0xBF0C}},
+	{0x5F53, "International Bank Account Number (IBAN)", SourceICC, FormatBinary, 0, 34, []Tag{0xBF0C}},
+	{0x5F54, "Bank Identifier Code (BIC)", SourceICC, FormatBinary, 8, 11, []Tag{0xBF0C}},
+	{0x5F55, "Issuer Country Code (alpha2 format)", SourceICC, FormatAlphabetic, 2, 2, []Tag{0xBF0C}},
+	{0x5F56, "Issuer Country Code (alpha3 format)", SourceICC, FormatAlphabetic, 3, 3, []Tag{0xBF0C}},
+	{0x61, "Application Template", SourceICC, FormatBinary, 0, 252, []Tag{0x70}},
+	{0x6F, "File Control Information (FCI) Template", SourceICC, FormatBinary, 0, 252, nil},
+	{0x70, "READ RECORD Response Message Template", SourceICC, FormatBinary, 0, 252, nil},
+	{0x71, "Issuer Script Template 1", SourceIssuer, FormatBinary, 0, 0, nil},
+	{0x72, "Issuer Script Template 2", SourceIssuer, FormatBinary, 0, 0, nil},
+	{0x73, "Directory Discretionary Template", SourceICC, FormatBinary, 0, 252, inApplication},
+	{0x77, "Response Message Template Format 2", SourceICC, FormatBinary, 0, 0, nil},
+	{0x80, "Response Message Template Format 1", SourceICC, FormatBinary, 0, 0, nil},
+	{0x81, "Amount, Authorised (Binary)", SourceTerminal, FormatBinary, 4, 4, nil},
+	{0x82, "Application Interchange Profile", SourceICC, FormatBinary, 2, 2, inGACResponse},
+	{0x83, "Command Template", SourceTerminal, FormatBinary, 0, 0, nil},
+	{0x84, "Dedicated File (DF) Name", SourceICC, FormatBinary, 5, 16, []Tag{0x6F}},
+	{0x86, "Issuer Script Command", SourceIssuer, FormatBinary, 0, 261, inIssuerScripts},
+	{0x87, "Application Priority Indicator", SourceICC, FormatBinary, 1, 1, []Tag{0x61, 0xA5}},
+	{0x88, "Short File Identifier (SFI)", SourceICC, FormatBinary, 1, 1, inFCI},
+	{0x89, "Authorisation Code", SourceIssuer, FormatAlphanumericSpecial, 6, 6, nil},
+	{0x8A, "Authorisation Response Code", SourceIssuer, FormatAlphanumeric, 2, 2, nil},
+	{0x8C, "Card Risk Management Data Object List 1 (CDOL1)", SourceICC, FormatBinary, 0, 252, inRecord},
+	{0x8D, "Card Risk Management Data Object List 2 (CDOL2)", SourceICC, FormatBinary, 0, 252, inRecord},
+	{0x8E, "Cardholder Verification Method (CVM) List", SourceICC, FormatBinary, 10, 252, inRecord},
+	{0x8F, "Certification Authority Public Key Index", SourceICC, FormatBinary, 1, 1, inRecord},
+	{0x90, "Issuer Public Key Certificate", SourceICC, FormatBinary, 0, 0, inRecord},
+	{0x91, "Issuer Authentication Data", SourceIssuer, FormatBinary, 8, 16, nil},
+	{0x92, "Issuer Public Key Remainder", SourceICC, FormatBinary, 0, 0, inRecord},
+	{0x93, "Signed Static Application Data", SourceICC, FormatBinary, 0, 0, inRecord},
+	{0x94, "Application File Locator (AFL)", SourceICC, FormatBinary, 0, 252, inGACResponse},
+	{0x95, "Terminal Verification Results", SourceTerminal, FormatBinary, 5, 5, nil},
+	{0x97, "Transaction Certificate Data Object List (TDOL)", SourceICC, FormatBinary, 0, 252, inRecord},
+	{0x98, "Transaction Certificate (TC) Hash Value", SourceTerminal, FormatBinary, 20, 20, nil},
+	{0x99, "Transaction Personal Identification Number (PIN) Data", SourceTerminal, FormatBinary, 0, 0, nil},
+	{0x9A, "Transaction Date", SourceTerminal, FormatNumeric, 3, 3, nil},
+	{0x9B, "Transaction Status Information", SourceTerminal, FormatBinary, 2, 2, nil},
+	{0x9C, "Transaction Type", SourceTerminal, FormatNumeric, 1, 1, nil},
+	{0x9D, "Directory Definition File (DDF) Name", SourceICC, FormatBinary, 5, 16, inApplication},
+	{0xA5, "File Control Information (FCI) Proprietary Template", SourceICC, FormatBinary, 0, 0, []Tag{0x6F}},
+	{0xBF0C, "File Control Information (FCI) Issuer Discretionary Data", SourceICC, FormatBinary, 0, 222, inFCI},
+	{0x9F01, "Acquirer Identifier", SourceTerminal, FormatNumeric, 6, 6, nil},
+	{0x9F02, "Amount, Authorised (Numeric)", SourceTerminal, FormatNumeric, 6, 6, nil},
+	{0x9F03, "Amount, Other (Numeric)", SourceTerminal, FormatNumeric, 6, 6, nil},
+	{0x9F04, "Amount, Other (Binary)", SourceTerminal, FormatBinary, 4, 4, nil},
+	{0x9F05, "Application Discretionary Data", SourceICC, FormatBinary, 1, 32, inRecord},
+	{0x9F06, "Application Identifier (AID) - terminal", SourceTerminal, FormatBinary, 5, 16, nil},
+	{0x9F07, "Application Usage Control", SourceICC, FormatBinary, 2, 2, inRecord},
+	{0x9F08, "Application Version Number", SourceICC, FormatBinary, 2, 2, inRecord},
+	{0x9F09, "Application Version Number", SourceTerminal, FormatBinary, 2, 2, nil},
+	{0x9F0B, "Cardholder Name Extended", SourceICC, FormatAlphanumericSpecial, 27, 45, inRecord},
+	{0x9F0D, "Issuer Action Code - Default", SourceICC, FormatBinary, 5, 5, inRecord},
+	{0x9F0E, "Issuer Action Code - Denial", SourceICC, FormatBinary, 5, 5, inRecord},
+	{0x9F0F, "Issuer Action Code - Online", SourceICC, FormatBinary, 5, 5, inRecord},
+	{0x9F10, "Issuer Application Data", SourceICC, FormatBinary, 0, 32, inGACResponse},
+	{0x9F11, "Issuer Code Table Index", SourceICC, FormatNumeric, 1, 1, inFCI},
+	{0x9F12, "Application Preferred Name", SourceICC, FormatAlphanumericSpecial, 1, 16, []Tag{0x61, 0xA5}},
+	{0x9F13, "Last Online Application Transaction Counter (ATC) Register", SourceICC, FormatBinary, 2, 2, nil},
+	{0x9F14, "Lower Consecutive Offline Limit", SourceICC, FormatBinary, 1, 1, inRecord},
+	{0x9F15, "Merchant Category Code", SourceTerminal, FormatNumeric, 2, 2, nil},
+	{0x9F16, "Merchant Identifier", SourceTerminal, FormatAlphanumericSpecial, 15, 15, nil},
+	{0x9F17, "Personal Identification Number (PIN) Try Counter", SourceICC, FormatBinary, 1, 1, nil},
+	{0x9F18, "Issuer Script Identifier", SourceIssuer, FormatBinary, 4, 4, inIssuerScripts},
+	{0x9F1A, "Terminal Country Code", SourceTerminal, FormatNumeric, 2, 2, nil},
+	{0x9F1B, "Terminal Floor Limit", SourceTerminal, FormatBinary, 4, 4, nil},
+	{0x9F1C, "Terminal Identification", SourceTerminal, FormatAlphanumeric, 8, 8, nil},
+	{0x9F1D, "Terminal Risk Management Data", SourceTerminal, FormatBinary, 1, 8, nil},
+	{0x9F1E, "Interface Device (IFD) Serial Number", SourceTerminal, FormatAlphanumeric, 8, 8, nil},
+	{0x9F1F, "Track 1 Discretionary Data", SourceICC, FormatAlphanumericSpecial, 0, 0, inRecord},
+	{0x9F20, "Track 2 Discretionary Data", SourceICC, FormatCompressedNumeric, 0, 0, inRecord},
+	{0x9F21, "Transaction Time", SourceTerminal, FormatNumeric, 3, 3, nil},
+	{0x9F22, "Certification Authority Public Key Index", SourceTerminal, FormatBinary, 1, 1, nil},
+	{0x9F23, "Upper Consecutive Offline Limit", SourceICC, FormatBinary, 1, 1, inRecord},
+	{0x9F26, "Application Cryptogram", SourceICC, FormatBinary, 8, 8, inGACResponse},
+	{0x9F27, "Cryptogram Information Data", SourceICC, FormatBinary, 1, 1, inGACResponse},
+	{0x9F2D, "ICC PIN Encipherment Public Key Certificate", SourceICC, FormatBinary, 0, 0, inRecord},
+	{0x9F2E, "ICC PIN Encipherment Public Key Exponent", SourceICC, FormatBinary, 1, 3, inRecord},
+	{0x9F2F, "ICC PIN Encipherment Public Key Remainder", SourceICC, FormatBinary, 0, 0, inRecord},
+	{0x9F32, "Issuer Public Key Exponent", SourceICC, FormatBinary, 1, 3, inRecord},
+	{0x9F33, "Terminal Capabilities", SourceTerminal, FormatBinary, 3, 3, nil},
+	{0x9F34, "Cardholder Verification Method (CVM) Results", SourceTerminal, FormatBinary, 3, 3, nil},
+	{0x9F35, "Terminal Type", SourceTerminal, FormatNumeric, 1, 1, nil},
+	{0x9F36, "Application Transaction Counter (ATC)", SourceICC, FormatBinary, 2, 2, inGACResponse},
+	{0x9F37, "Unpredictable Number", SourceTerminal, FormatBinary, 4, 4, nil},
+	{0x9F38, "Processing Options Data Object List (PDOL)", SourceICC, FormatBinary, 0, 0, inFCI},
+	{0x9F39, "Point-of-Service (POS) Entry Mode", SourceTerminal, FormatNumeric, 1, 1, nil},
+	{0x9F3A, "Amount, Reference Currency", SourceTerminal, FormatBinary, 4, 4, nil},
+	{0x9F3B, "Application Reference Currency", SourceICC, FormatNumeric, 2, 8, inRecord},
+	{0x9F3C, "Transaction Reference Currency Code", SourceTerminal, FormatNumeric, 2, 2, nil},
+	{0x9F3D, "Transaction Reference Currency Exponent", SourceTerminal, FormatNumeric, 1, 1, nil},
+	{0x9F40, "Additional Terminal Capabilities", SourceTerminal, FormatBinary, 5, 5, nil},
+	{0x9F41, "Transaction Sequence Counter", SourceTerminal, FormatNumeric, 2, 4, nil},
+	{0x9F42, "Application Currency Code", SourceICC, FormatNumeric, 2, 2, inRecord},
+	{0x9F43, "Application Reference Currency Exponent", SourceICC, FormatNumeric, 1, 4, inRecord},
+	{0x9F44, "Application Currency Exponent", SourceICC, FormatNumeric, 1, 1, inRecord},
+	{0x9F45, "Data Authentication Code", SourceICC, FormatBinary, 2, 2, nil},
+	{0x9F46, "ICC Public Key Certificate", SourceICC, FormatBinary, 0, 0, inRecord},
+	{0x9F47, "ICC Public Key Exponent", SourceICC, FormatBinary, 1, 3, inRecord},
+	{0x9F48, "ICC Public Key Remainder", SourceICC, FormatBinary, 0, 0, inRecord},
+	{0x9F49, "Dynamic Data Authentication Data Object List (DDOL)", SourceICC, FormatBinary, 0, 252, inRecord},
+	{0x9F4A, "Static Data Authentication Tag List", SourceICC, FormatBinary, 0, 0, inRecord},
+	{0x9F4B, "Signed Dynamic Application Data", SourceICC, FormatBinary, 0, 0, []Tag{0x77}},
+	{0x9F4C, "ICC Dynamic Number", SourceICC, FormatBinary, 2, 8, nil},
+	{0x9F4D, "Log Entry", SourceICC, FormatBinary, 2, 2, []Tag{0xBF0C}},
+	{0x9F4E, "Merchant Name and Location", SourceTerminal, FormatAlphanumericSpecial, 0, 0, nil},
+	{0x9F4F, "Log Format", SourceICC, FormatBinary, 0, 0, nil},
+	{0x9F5B, "Issuer Script Results", SourceTerminal, FormatBinary, 0, 0, nil},
+	{0x9F5D, "Available Offline Spending Amount", SourceICC, FormatNumeric, 6, 6, inGACResponse},
+	{0x9F60, "CVC3 (Track1)", SourceICC, FormatBinary, 2, 2, []Tag{0x77}},
+	{0x9F61, "CVC3 (Track2)", SourceICC, FormatBinary, 2, 2, []Tag{0x77}},
+	{0x9F66, "Terminal Transaction Qualifiers (TTQ)", SourceTerminal, FormatBinary, 4, 4, nil},
+	{0x9F69, "Card Authentication Related Data", SourceICC, FormatBinary, 0, 0, inRecord},
+	{0x9F6A, "Unpredictable Number (Numeric)", SourceTerminal, FormatNumeric, 4, 4, nil},
+	{0x9F6B, "Track 2 Data", SourceICC, FormatBinary, 0, 19, inRecord},
+	{0x9F6C, "Card Transaction Qualifiers (CTQ)", SourceICC, FormatBinary, 2, 2, inGACResponse},
+	{0x9F6E, "Form Factor Indicator", SourceICC, FormatBinary, 0, 0, nil},
+	{0x9F7C, "Customer Exclusive Data", SourceICC, FormatBinary, 0, 32, inGACResponse},
+}
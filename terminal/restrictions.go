@@ -0,0 +1,108 @@
+package terminal
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mniak/apdu"
+)
+
+// TransactionType is the Transaction Type (9C) as defined by the first two digits of ISO 8583 Processing Code.
+type TransactionType byte
+
+const (
+	TransactionTypePurchase             TransactionType = 0x00
+	TransactionTypeCash                 TransactionType = 0x01
+	TransactionTypePurchaseWithCashback TransactionType = 0x09
+	TransactionTypeRefund               TransactionType = 0x20
+)
+
+type ProcessingRestrictionsConfig struct {
+	// ApplicationVersionNumber is the terminal Application Version Number (9F09) as hex.
+	ApplicationVersionNumber string
+	// CountryCode is the Terminal Country Code (9F1A) as hex, like "0076".
+	CountryCode string
+	ATM         bool
+}
+
+type ProcessingRestrictionsInput struct {
+	Type TransactionType
+	// Services tells that a purchase is of services instead of goods.
+	Services bool
+	Date     time.Time
+}
+
+// CheckProcessingRestrictions performs the processing restrictions step (EMV Book 3 §10.4)
+// using the card data and sets the corresponding TVR bits. Checks whose card data is absent
+// are skipped, and malformed card data is returned as an error instead of setting a TVR bit.
+func CheckProcessingRestrictions(config ProcessingRestrictionsConfig, card apdu.EMVProprietaryTemplate, input ProcessingRestrictionsInput, tvr *apdu.TVR) error {
+	if card.VersionNumber1 != "" && config.ApplicationVersionNumber != "" &&
+		!strings.EqualFold(card.VersionNumber1, config.ApplicationVersionNumber) {
+		tvr.Set(apdu.TVRDifferentApplicationVersions)
+	}
+
+	if card.UsageControl != "" {
+		auc, err := card.ApplicationUsageControl()
+		if err != nil {
+			return err
+		}
+		if !usageAllowed(config, auc, card.IssuerCountryCode, input) {
+			tvr.Set(apdu.TVRRequestedServiceNotAllowed)
+		}
+	}
+
+	today := dateOnly(input.Date)
+	if card.EffectiveDate != "" {
+		effective, err := card.ApplicationEffectiveDate()
+		if err != nil {
+			return fmt.Errorf("invalid application effective date: %w", err)
+		}
+		if today.Before(effective) {
+			tvr.Set(apdu.TVRApplicationNotYetEffective)
+		}
+	}
+	if card.ExpirationDate != "" {
+		expiration, err := card.ApplicationExpirationDate()
+		if err != nil {
+			return fmt.Errorf("invalid application expiration date: %w", err)
+		}
+		if today.After(expiration) {
+			tvr.Set(apdu.TVRExpiredApplication)
+		}
+	}
+	return nil
+}
+
+func usageAllowed(config ProcessingRestrictionsConfig, auc apdu.ApplicationUsageControl, issuerCountryCode string, input ProcessingRestrictionsInput) bool {
+	if config.ATM && !auc.ValidAtATMs() {
+		return false
+	}
+	if !config.ATM && !auc.ValidAtTerminalsOtherThanATMs() {
+		return false
+	}
+	if issuerCountryCode == "" {
+		return true
+	}
+
+	domestic := strings.EqualFold(issuerCountryCode, config.CountryCode)
+	validForPurchase := auc.ValidForGoods(domestic)
+	if input.Services {
+		validForPurchase = auc.ValidForServices(domestic)
+	}
+
+	switch input.Type {
+	case TransactionTypeCash:
+		return auc.ValidForCash(domestic)
+	case TransactionTypePurchase:
+		return validForPurchase
+	case TransactionTypePurchaseWithCashback:
+		return validForPurchase && auc.CashbackAllowed(domestic)
+	default:
+		return true
+	}
+}
+
+func dateOnly(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,120 @@
+package terminal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mniak/apdu"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckProcessingRestrictions(t *testing.T) {
+	config := ProcessingRestrictionsConfig{
+		ApplicationVersionNumber: "0002",
+		CountryCode:              "0076",
+	}
+	card := apdu.EMVProprietaryTemplate{
+		VersionNumber1:    "0002",
+		UsageControl:      "E180",
+		IssuerCountryCode: "0076",
+		EffectiveDate:     "230101",
+		ExpirationDate:    "281231",
+	}
+	date := time.Date(2026, 10, 19, 15, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name     string
+		config   func(*ProcessingRestrictionsConfig)
+		card     func(*apdu.EMVProprietaryTemplate)
+		input    ProcessingRestrictionsInput
+		expected []apdu.TVRBit
+	}{
+		{
+			name:  "domestic goods",
+			input: ProcessingRestrictionsInput{Type: TransactionTypePurchase, Date: date},
+		},
+		{
+			name:     "domestic services not allowed",
+			input:    ProcessingRestrictionsInput{Type: TransactionTypePurchase, Services: true, Date: date},
+			expected: []apdu.TVRBit{apdu.TVRRequestedServiceNotAllowed},
+		},
+		{
+			name:   "international cash",
+			config: func(c *ProcessingRestrictionsConfig) { c.CountryCode = "0840" },
+			input:  ProcessingRestrictionsInput{Type: TransactionTypeCash, Date: date},
+		},
+		{
+			name:     "international goods not allowed",
+			config:   func(c *ProcessingRestrictionsConfig) { c.CountryCode = "0840" },
+			input:    ProcessingRestrictionsInput{Type: TransactionTypePurchase, Date: date},
+			expected: []apdu.TVRBit{apdu.TVRRequestedServiceNotAllowed},
+		},
+		{
+			name:  "domestic cashback",
+			input: ProcessingRestrictionsInput{Type: TransactionTypePurchaseWithCashback, Date: date},
+		},
+		{
+			name:     "not valid at ATMs",
+			config:   func(c *ProcessingRestrictionsConfig) { c.ATM = true },
+			input:    ProcessingRestrictionsInput{Type: TransactionTypeCash, Date: date},
+			expected: []apdu.TVRBit{apdu.TVRRequestedServiceNotAllowed},
+		},
+		{
+			name:     "different application versions",
+			card:     func(c *apdu.EMVProprietaryTemplate) { c.VersionNumber1 = "0001" },
+			input:    ProcessingRestrictionsInput{Type: TransactionTypePurchase, Date: date},
+			expected: []apdu.TVRBit{apdu.TVRDifferentApplicationVersions},
+		},
+		{
+			name:     "not yet effective",
+			card:     func(c *apdu.EMVProprietaryTemplate) { c.EffectiveDate = "261020" },
+			input:    ProcessingRestrictionsInput{Type: TransactionTypePurchase, Date: date},
+			expected: []apdu.TVRBit{apdu.TVRApplicationNotYetEffective},
+		},
+		{
+			name:     "expired",
+			card:     func(c *apdu.EMVProprietaryTemplate) { c.ExpirationDate = "261018" },
+			input:    ProcessingRestrictionsInput{Type: TransactionTypePurchase, Date: date},
+			expected: []apdu.TVRBit{apdu.TVRExpiredApplication},
+		},
+		{
+			name:  "expires today",
+			card:  func(c *apdu.EMVProprietaryTemplate) { c.ExpirationDate = "261019" },
+			input: ProcessingRestrictionsInput{Type: TransactionTypePurchase, Date: date},
+		},
+		{
+			name: "missing card data",
+			card: func(c *apdu.EMVProprietaryTemplate) {
+				*c = apdu.EMVProprietaryTemplate{}
+			},
+			input: ProcessingRestrictionsInput{Type: TransactionTypeCash, Date: date},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg, c := config, card
+			if tc.config != nil {
+				tc.config(&cfg)
+			}
+			if tc.card != nil {
+				tc.card(&c)
+			}
+
+			var tvr apdu.TVR
+			err := CheckProcessingRestrictions(cfg, c, tc.input, &tvr)
+			require.NoError(t, err)
+
+			var expected apdu.TVR
+			expected.Set(tc.expected...)
+			assert.Equal(t, expected, tvr)
+		})
+	}
+}
+
+func TestCheckProcessingRestrictions_InvalidDate(t *testing.T) {
+	card := apdu.EMVProprietaryTemplate{ExpirationDate: "261399"}
+	var tvr apdu.TVR
+	err := CheckProcessingRestrictions(ProcessingRestrictionsConfig{}, card, ProcessingRestrictionsInput{}, &tvr)
+	assert.Error(t, err)
+}
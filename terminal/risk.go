@@ -0,0 +1,155 @@
+package terminal
+
+import (
+	"math/big"
+	"math/rand"
+	"strings"
+
+	"github.com/mniak/apdu"
+)
+
+const (
+	TagATC           apdu.Tag = 0x9F36
+	TagLastOnlineATC apdu.Tag = 0x9F13
+)
+
+// ExceptionFile is the terminal hot list of PANs that must not be accepted offline.
+type ExceptionFile interface {
+	Contains(pan string) bool
+}
+
+type _ExceptionFile map[string]struct{}
+
+// NewExceptionFile creates an in-memory exception file with the PANs provided.
+func NewExceptionFile(pans ...string) ExceptionFile {
+	ef := make(_ExceptionFile, len(pans))
+	for _, pan := range pans {
+		ef[normalizePAN(pan)] = struct{}{}
+	}
+	return ef
+}
+
+func (ef _ExceptionFile) Contains(pan string) bool {
+	_, found := ef[normalizePAN(pan)]
+	return found
+}
+
+func normalizePAN(pan string) string {
+	return strings.TrimRight(strings.ToUpper(pan), "F")
+}
+
+type RiskManagementConfig struct {
+	// FloorLimit is the Terminal Floor Limit (9F1B) in minor units.
+	FloorLimit uint64
+	// RandomSelectionThreshold is the amount in minor units below which
+	// transactions are selected with the TargetPercentage.
+	RandomSelectionThreshold uint64
+	TargetPercentage         int
+	MaximumTargetPercentage  int
+	// ExceptionFile is optional. When nil the exception file check is skipped.
+	ExceptionFile ExceptionFile
+}
+
+// RiskManagementInput is the transaction and card data used by terminal risk management.
+type RiskManagementInput struct {
+	// Amount is the Amount, Authorised (9F02) in minor units. Split sales
+	// should already be added to it.
+	Amount uint64
+	PAN    string
+	// LowerConsecutiveOfflineLimit (9F14) and UpperConsecutiveOfflineLimit
+	// (9F23) are the values read from the card, not the hex strings of
+	// EMVProprietaryTemplate, so that any source of card data can be used.
+	// Velocity checking is only performed when both are present.
+	LowerConsecutiveOfflineLimit []byte
+	UpperConsecutiveOfflineLimit []byte
+}
+
+type DataGetter interface {
+	GetData(tag apdu.Tag) ([]byte, error)
+}
+
+type RiskManager struct {
+	config RiskManagementConfig
+	random func(n int) int
+}
+
+func NewRiskManager(config RiskManagementConfig) RiskManager {
+	return RiskManager{
+		config: config,
+		random: rand.Intn,
+	}
+}
+
+// WithRandom replaces the random source. The function must return a number in [0,n).
+func (rm RiskManager) WithRandom(random func(n int) int) RiskManager {
+	rm.random = random
+	return rm
+}
+
+// Perform runs terminal risk management (EMV Book 3 §10.6) and updates the TVR and TSI.
+func (rm RiskManager) Perform(card DataGetter, input RiskManagementInput, tvr *apdu.TVR, tsi *apdu.TSI) {
+	if input.Amount >= rm.config.FloorLimit {
+		tvr.Set(apdu.TVRTransactionExceedsFloorLimit)
+	}
+	if rm.CheckExceptionFile(input.PAN) {
+		tvr.Set(apdu.TVRCardAppearsOnExceptionFile)
+	}
+	if rm.SelectRandomly(input.Amount) {
+		tvr.Set(apdu.TVRTransactionSelectedRandomlyForOnline)
+	}
+	if len(input.LowerConsecutiveOfflineLimit) > 0 && len(input.UpperConsecutiveOfflineLimit) > 0 {
+		checkVelocity(card, input, tvr)
+	}
+	tsi.Set(apdu.TSITerminalRiskManagementPerformed)
+}
+
+func (rm RiskManager) CheckExceptionFile(pan string) bool {
+	return rm.config.ExceptionFile != nil && rm.config.ExceptionFile.Contains(pan)
+}
+
+// SelectRandomly decides whether a transaction below the floor limit is sent online.
+// Between the threshold and the floor limit the target percentage increases
+// linearly up to the maximum target percentage.
+func (rm RiskManager) SelectRandomly(amount uint64) bool {
+	cfg := rm.config
+	if amount >= cfg.FloorLimit {
+		return false
+	}
+	random := rm.random(99) + 1
+	if amount < cfg.RandomSelectionThreshold {
+		return random <= cfg.TargetPercentage
+	}
+	interpolationFactor := float64(amount-cfg.RandomSelectionThreshold) / float64(cfg.FloorLimit-cfg.RandomSelectionThreshold)
+	target := float64(cfg.MaximumTargetPercentage-cfg.TargetPercentage)*interpolationFactor + float64(cfg.TargetPercentage)
+	return float64(random) <= target
+}
+
+func checkVelocity(card DataGetter, input RiskManagementInput, tvr *apdu.TVR) {
+	atcBytes, atcErr := card.GetData(TagATC)
+	lastOnlineATCBytes, lastOnlineErr := card.GetData(TagLastOnlineATC)
+	if atcErr != nil || lastOnlineErr != nil {
+		tvr.Set(apdu.TVRLowerConsecutiveOfflineLimitExceeded)
+		tvr.Set(apdu.TVRUpperConsecutiveOfflineLimitExceeded)
+		return
+	}
+
+	atc := new(big.Int).SetBytes(atcBytes).Uint64()
+	lastOnlineATC := new(big.Int).SetBytes(lastOnlineATCBytes).Uint64()
+	if atc <= lastOnlineATC {
+		tvr.Set(apdu.TVRLowerConsecutiveOfflineLimitExceeded)
+		tvr.Set(apdu.TVRUpperConsecutiveOfflineLimitExceeded)
+	} else {
+		offlineCount := atc - lastOnlineATC
+		lcol := new(big.Int).SetBytes(input.LowerConsecutiveOfflineLimit).Uint64()
+		ucol := new(big.Int).SetBytes(input.UpperConsecutiveOfflineLimit).Uint64()
+		if offlineCount > lcol {
+			tvr.Set(apdu.TVRLowerConsecutiveOfflineLimitExceeded)
+		}
+		if offlineCount > ucol {
+			tvr.Set(apdu.TVRUpperConsecutiveOfflineLimitExceeded)
+		}
+	}
+	if lastOnlineATC == 0 {
+		tvr.Set(apdu.TVRNewCard)
+	}
+}
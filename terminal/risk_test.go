@@ -0,0 +1,144 @@
+package terminal
+
+import (
+	"testing"
+
+	"github.com/mniak/apdu"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeCard map[apdu.Tag][]byte
+
+func (c fakeCard) GetData(tag apdu.Tag) ([]byte, error) {
+	value, ok := c[tag]
+	if !ok {
+		return nil, apdu.ErrReferencedDataOrReferenceDataNotFound
+	}
+	return value, nil
+}
+
+func fixedRandom(value int) func(int) int {
+	return func(int) int { return value - 1 }
+}
+
+func TestRiskManager_FloorLimit(t *testing.T) {
+	rm := NewRiskManager(RiskManagementConfig{FloorLimit: 10000}).WithRandom(fixedRandom(99))
+
+	var tvr apdu.TVR
+	var tsi apdu.TSI
+	rm.Perform(fakeCard{}, RiskManagementInput{Amount: 10000}, &tvr, &tsi)
+	assert.True(t, tvr.Has(apdu.TVRTransactionExceedsFloorLimit))
+	assert.True(t, tsi.Has(apdu.TSITerminalRiskManagementPerformed))
+
+	tvr = apdu.TVR{}
+	rm.Perform(fakeCard{}, RiskManagementInput{Amount: 9999}, &tvr, &tsi)
+	assert.Equal(t, apdu.TVR{}, tvr)
+}
+
+func TestRiskManager_SelectRandomly(t *testing.T) {
+	config := RiskManagementConfig{
+		FloorLimit:               10000,
+		RandomSelectionThreshold: 5000,
+		TargetPercentage:         20,
+		MaximumTargetPercentage:  60,
+	}
+	testCases := []struct {
+		name     string
+		amount   uint64
+		random   int
+		selected bool
+	}{
+		{"below threshold selected", 1000, 20, true},
+		{"below threshold not selected", 1000, 21, false},
+		{"halfway selected", 7500, 40, true},
+		{"halfway not selected", 7500, 41, false},
+		{"above floor limit", 10000, 1, false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rm := NewRiskManager(config).WithRandom(fixedRandom(tc.random))
+			assert.Equal(t, tc.selected, rm.SelectRandomly(tc.amount))
+		})
+	}
+}
+
+func TestRiskManager_Velocity(t *testing.T) {
+	testCases := []struct {
+		name       string
+		card       fakeCard
+		expected   []apdu.TVRBit
+		unexpected []apdu.TVRBit
+	}{
+		{
+			name: "within limits",
+			card: fakeCard{TagATC: {0x00, 0x12}, TagLastOnlineATC: {0x00, 0x10}},
+			unexpected: []apdu.TVRBit{
+				apdu.TVRLowerConsecutiveOfflineLimitExceeded,
+				apdu.TVRUpperConsecutiveOfflineLimitExceeded,
+				apdu.TVRNewCard,
+			},
+		},
+		{
+			name:       "lower limit exceeded",
+			card:       fakeCard{TagATC: {0x00, 0x14}, TagLastOnlineATC: {0x00, 0x10}},
+			expected:   []apdu.TVRBit{apdu.TVRLowerConsecutiveOfflineLimitExceeded},
+			unexpected: []apdu.TVRBit{apdu.TVRUpperConsecutiveOfflineLimitExceeded},
+		},
+		{
+			name: "upper limit exceeded",
+			card: fakeCard{TagATC: {0x00, 0x20}, TagLastOnlineATC: {0x00, 0x10}},
+			expected: []apdu.TVRBit{
+				apdu.TVRLowerConsecutiveOfflineLimitExceeded,
+				apdu.TVRUpperConsecutiveOfflineLimitExceeded,
+			},
+		},
+		{
+			name:     "new card",
+			card:     fakeCard{TagATC: {0x00, 0x01}, TagLastOnlineATC: {0x00, 0x00}},
+			expected: []apdu.TVRBit{apdu.TVRNewCard},
+		},
+		{
+			name: "last online ATC not available",
+			card: fakeCard{TagATC: {0x00, 0x01}},
+			expected: []apdu.TVRBit{
+				apdu.TVRLowerConsecutiveOfflineLimitExceeded,
+				apdu.TVRUpperConsecutiveOfflineLimitExceeded,
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rm := NewRiskManager(RiskManagementConfig{FloorLimit: 10000}).WithRandom(fixedRandom(99))
+			input := RiskManagementInput{
+				Amount:                       100,
+				LowerConsecutiveOfflineLimit: []byte{0x03},
+				UpperConsecutiveOfflineLimit: []byte{0x05},
+			}
+			var tvr apdu.TVR
+			var tsi apdu.TSI
+			rm.Perform(tc.card, input, &tvr, &tsi)
+			for _, bit := range tc.expected {
+				assert.True(t, tvr.Has(bit), "%s should be set", bit)
+			}
+			for _, bit := range tc.unexpected {
+				assert.False(t, tvr.Has(bit), "%s should not be set", bit)
+			}
+		})
+	}
+}
+
+func TestRiskManager_ExceptionFile(t *testing.T) {
+	rm := NewRiskManager(RiskManagementConfig{
+		FloorLimit:    10000,
+		ExceptionFile: NewExceptionFile("5413330089020011"),
+	}).WithRandom(fixedRandom(99))
+
+	var tvr apdu.TVR
+	var tsi apdu.TSI
+	rm.Perform(fakeCard{}, RiskManagementInput{Amount: 100, PAN: "5413330089020011F"}, &tvr, &tsi)
+	assert.True(t, tvr.Has(apdu.TVRCardAppearsOnExceptionFile))
+
+	tvr = apdu.TVR{}
+	rm.Perform(fakeCard{}, RiskManagementInput{Amount: 100, PAN: "5413330089020029"}, &tvr, &tsi)
+	assert.False(t, tvr.Has(apdu.TVRCardAppearsOnExceptionFile))
+}
@@ -0,0 +1,119 @@
+package apdu
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/mniak/apdu/internal/ber"
+)
+
+// PrettyTLV annotates BER-TLV data with the tag names from the dictionary
+// and the values decoded according to their format.
+func PrettyTLV(data []byte) (string, error) {
+	tlvs, err := ber.Parse(data)
+	if err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	if err := writePrettyTLV(&sb, tlvs, ""); err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(sb.String(), "\n"), nil
+}
+
+func writePrettyTLV(sb *strings.Builder, tlvs []ber.TLV, indentation string) error {
+	for _, t := range tlvs {
+		tag := t.Tag
+		name := TagName(tag)
+		if name == "" {
+			name = "Unknown"
+		}
+		if t.Tag.Constructed() {
+			fmt.Fprintf(sb, "%s%s %s\n", indentation, tag, name)
+			children, err := t.Children()
+			if err != nil {
+				return fmt.Errorf("failed to parse %s: %w", tag, err)
+			}
+			if err := writePrettyTLV(sb, children, indentation+"  "); err != nil {
+				return err
+			}
+			continue
+		}
+		fmt.Fprintf(sb, "%s%s %s: %s\n", indentation, tag, name, FormatTagValue(tag, t.Value))
+		for _, detail := range describeTagValue(tag, t.Value) {
+			fmt.Fprintf(sb, "%s  - %s\n", indentation, detail)
+		}
+	}
+	return nil
+}
+
+// FormatTagValue returns the value as text when the tag format is alphanumeric,
+// without the padding when compressed numeric, and in hex otherwise.
+func FormatTagValue(tag Tag, value []byte) string {
+	info, _ := LookupTag(tag)
+	switch info.Format {
+	case FormatAlphabetic, FormatAlphanumeric, FormatAlphanumericSpecial:
+		if isPrintable(value) {
+			return fmt.Sprintf("%q", value)
+		}
+	case FormatCompressedNumeric:
+		return strings.TrimRight(fmt.Sprintf("%X", value), "F")
+	}
+	return fmt.Sprintf("%X", value)
+}
+
+func isPrintable(value []byte) bool {
+	for _, b := range value {
+		if b > unicode.MaxASCII || !unicode.IsPrint(rune(b)) {
+			return false
+		}
+	}
+	return true
+}
+
+// describeTagValue explains the bits of the values of known bit fields.
+func describeTagValue(tag Tag, value []byte) []string {
+	switch tag {
+	case 0x82:
+		return stringers(AIP(value).Bits())
+	case 0x95:
+		return stringers(ParseTVR(value).Bits())
+	case 0x9B:
+		return stringers(ParseTSI(value).Bits())
+	case 0x9F07:
+		auc, err := ParseApplicationUsageControl(value)
+		if err != nil {
+			return nil
+		}
+		return stringers(auc.Bits())
+	case 0x9F27:
+		if len(value) != 1 {
+			return nil
+		}
+		cid := CryptogramInformationData(value[0])
+		details := []string{cid.CryptogramType().String()}
+		if cid.AdviceRequired() {
+			details = append(details, "Advice required")
+		}
+		return append(details, cid.ReasonCode().String())
+	case 0x9F33:
+		return stringers(ParseTerminalCapabilities(value).Bits())
+	case 0x9F40:
+		return stringers(ParseAdditionalTerminalCapabilities(value).Bits())
+	case 0x9F66:
+		return stringers(ParseTerminalTransactionQualifiers(value).Bits())
+	case 0x9F6C:
+		return stringers(ParseCardTransactionQualifiers(value).Bits())
+	default:
+		return nil
+	}
+}
+
+func stringers[T fmt.Stringer](items []T) []string {
+	result := make([]string, len(items))
+	for i, item := range items {
+		result[i] = item.String()
+	}
+	return result
+}
@@ -0,0 +1,72 @@
+package apdu
+
+import (
+	"testing"
+
+	"github.com/mniak/apdu/internal/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrettyTLV(t *testing.T) {
+	data := test.MustParseHex(t, "6F21840AA0000000041010AABBCCA513500A4D415354455243415244870101DF410100")
+
+	pretty, err := PrettyTLV(data)
+	require.NoError(t, err)
+
+	expected := `6F File Control Information (FCI) Template
+  84 Dedicated File (DF) Name: A0000000041010AABBCC
+  A5 File Control Information (FCI) Proprietary Template
+    50 Application Label: "MASTERCARD"
+    87 Application Priority Indicator: 01
+    DF41 Unknown: 00`
+	assert.Equal(t, expected, pretty)
+}
+
+func TestPrettyTLV_BitFields(t *testing.T) {
+	data := test.MustParseHex(t, "77139F2701809F360200018202198057045413FFFF")
+
+	pretty, err := PrettyTLV(data)
+	require.NoError(t, err)
+
+	expected := `77 Response Message Template Format 2
+  9F27 Cryptogram Information Data: 80
+    - ARQC
+    - No information given
+  9F36 Application Transaction Counter (ATC): 0001
+  82 Application Interchange Profile: 1980
+    - Cardholder verification is supported
+    - Terminal risk management is to be performed
+    - CDA supported
+    - EMV mode is supported
+  57 Track 2 Equivalent Data: 5413FFFF`
+	assert.Equal(t, expected, pretty)
+}
+
+func TestPrettyTLV_Invalid(t *testing.T) {
+	_, err := PrettyTLV(test.MustParseHex(t, "6F05840A"))
+	assert.Error(t, err)
+}
+
+func TestTagDictionary(t *testing.T) {
+	for _, info := range tagList {
+		assert.NotEmpty(t, info.Name, info.Tag)
+		assert.NotZero(t, info.Source, info.Tag)
+		assert.NotEmpty(t, info.Format, info.Tag)
+		if info.MaxLength > 0 {
+			assert.LessOrEqual(t, info.MinLength, info.MaxLength, info.Tag)
+		}
+		for _, template := range info.Templates {
+			_, found := LookupTag(template)
+			assert.True(t, found, "template %s of %s", template, info.Tag)
+		}
+	}
+	assert.Len(t, tagDictionary, len(tagList), "duplicate tags")
+
+	info, found := LookupTag(0x5A)
+	require.True(t, found)
+	assert.Equal(t, FormatCompressedNumeric, info.Format)
+	assert.True(t, info.ValidLength(8))
+	assert.False(t, info.ValidLength(11))
+	assert.Equal(t, "5413330089020011", FormatTagValue(0x5A, test.MustParseHex(t, "5413330089020011")))
+}
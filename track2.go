@@ -0,0 +1,270 @@
+package apdu
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidTrack2 = errors.New("invalid track 2 equivalent data")
+
+// PAN is a Primary Account Number without the F padding.
+type PAN string
+
+// ParsePAN reads a PAN from its hex representation, removing the F padding.
+func ParsePAN(value string) (PAN, error) {
+	pan := strings.TrimRight(strings.ToUpper(value), "F")
+	if len(pan) == 0 || len(pan) > 19 {
+		return "", fmt.Errorf("PAN should have from 1 to 19 digits but has %d", len(pan))
+	}
+	for _, r := range pan {
+		if r < '0' || r > '9' {
+			return "", fmt.Errorf("PAN %q is not numeric", value)
+		}
+	}
+	return PAN(pan), nil
+}
+
+// Valid checks the Luhn check digit.
+func (pan PAN) Valid() bool {
+	if len(pan) < 2 {
+		return false
+	}
+	sum := 0
+	double := false
+	for i := len(pan) - 1; i >= 0; i-- {
+		digit := int(pan[i] - '0')
+		if digit < 0 || digit > 9 {
+			return false
+		}
+		if double {
+			digit *= 2
+			if digit > 9 {
+				digit -= 9
+			}
+		}
+		sum += digit
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+// IIN returns the Issuer Identification Number, which are the first 6 digits.
+func (pan PAN) IIN() string {
+	return pan.prefix(6)
+}
+
+// BIN returns the Bank Identification Number with the length requested, usually 6 or 8.
+func (pan PAN) BIN(length int) string {
+	return pan.prefix(length)
+}
+
+func (pan PAN) prefix(length int) string {
+	if len(pan) < length {
+		return string(pan)
+	}
+	return string(pan[:length])
+}
+
+// Masked keeps the first 6 and the last 4 digits, replacing the others with '*'.
+func (pan PAN) Masked() string {
+	if len(pan) <= 10 {
+		return strings.Repeat("*", len(pan))
+	}
+	return string(pan[:6]) + strings.Repeat("*", len(pan)-10) + string(pan[len(pan)-4:])
+}
+
+// ServiceCode is the three-digit service code of the magnetic stripe.
+type ServiceCode string
+
+func (sc ServiceCode) Valid() bool {
+	if len(sc) != 3 {
+		return false
+	}
+	for _, r := range sc {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func (sc ServiceCode) digit(n int) byte {
+	if !sc.Valid() {
+		return 0
+	}
+	return sc[n]
+}
+
+// Interchange explains the first digit.
+func (sc ServiceCode) Interchange() string {
+	switch sc.digit(0) {
+	case '1':
+		return "International interchange OK"
+	case '2':
+		return "International interchange, use IC (chip) where feasible"
+	case '5':
+		return "National interchange only except under bilateral agreement"
+	case '6':
+		return "National interchange only except under bilateral agreement, use IC (chip) where feasible"
+	case '7':
+		return "No interchange except under bilateral agreement (closed loop)"
+	case '9':
+		return "Test"
+	default:
+		return "Unknown"
+	}
+}
+
+// ICCAvailable tells whether the first digit indicates a chip card.
+func (sc ServiceCode) ICCAvailable() bool {
+	d := sc.digit(0)
+	return d == '2' || d == '6'
+}
+
+// Authorization explains the second digit.
+func (sc ServiceCode) Authorization() string {
+	switch sc.digit(1) {
+	case '0':
+		return "Normal"
+	case '2':
+		return "Contact issuer via online means"
+	case '4':
+		return "Contact issuer via online means except under bilateral agreement"
+	default:
+		return "Unknown"
+	}
+}
+
+// AllowedServices explains the third digit.
+func (sc ServiceCode) AllowedServices() string {
+	switch sc.digit(2) {
+	case '0':
+		return "No restrictions, PIN required"
+	case '1':
+		return "No restrictions"
+	case '2':
+		return "Goods and services only"
+	case '3':
+		return "ATM only, PIN required"
+	case '4':
+		return "Cash only"
+	case '5':
+		return "Goods and services only, PIN required"
+	case '6':
+		return "No restrictions, prompt for PIN if PED present"
+	case '7':
+		return "Goods and services only, prompt for PIN if PED present"
+	default:
+		return "Unknown"
+	}
+}
+
+// PINRequired tells whether the third digit requires the PIN.
+func (sc ServiceCode) PINRequired() bool {
+	d := sc.digit(2)
+	return d == '0' || d == '3' || d == '5'
+}
+
+func (sc ServiceCode) GoString() string {
+	return fmt.Sprintf("%s [\n  - %s\n  - %s\n  - %s\n]", string(sc), sc.Interchange(), sc.Authorization(), sc.AllowedServices())
+}
+
+// Track2 is the decoded Track 2 Equivalent Data (57).
+type Track2 struct {
+	PAN PAN
+	// ExpirationDate is in the format YYMM.
+	ExpirationDate    string
+	ServiceCode       ServiceCode
+	DiscretionaryData string
+}
+
+// ParseTrack2 decodes the value of the tag 57.
+func ParseTrack2(data []byte) (Track2, error) {
+	digits := strings.TrimRight(strings.ToUpper(hex.EncodeToString(data)), "F")
+	pan, rest, found := strings.Cut(digits, "D")
+	if !found {
+		return Track2{}, fmt.Errorf("%w: field separator not found", ErrInvalidTrack2)
+	}
+	parsedPAN, err := ParsePAN(pan)
+	if err != nil {
+		return Track2{}, fmt.Errorf("%w: %w", ErrInvalidTrack2, err)
+	}
+	if len(rest) < 7 {
+		return Track2{}, fmt.Errorf("%w: too short", ErrInvalidTrack2)
+	}
+	t2 := Track2{
+		PAN:               parsedPAN,
+		ExpirationDate:    rest[:4],
+		ServiceCode:       ServiceCode(rest[4:7]),
+		DiscretionaryData: rest[7:],
+	}
+	if _, err := t2.Expiry(); err != nil {
+		return Track2{}, fmt.Errorf("%w: %w", ErrInvalidTrack2, err)
+	}
+	if !t2.ServiceCode.Valid() {
+		return Track2{}, fmt.Errorf("%w: invalid service code %q", ErrInvalidTrack2, t2.ServiceCode)
+	}
+	return t2, nil
+}
+
+// Expiry returns the last day of the month of the expiration date.
+func (t2 Track2) Expiry() (time.Time, error) {
+	date, err := ParseDate(t2.ExpirationDate + "01")
+	if err != nil {
+		return time.Time{}, err
+	}
+	return date.AddDate(0, 1, -1), nil
+}
+
+// ParseDate parses a date in the EMV format YYMMDD, where YY from 00 to 49
+// means 20YY and from 50 to 99 means 19YY. It is in this package so that the
+// date accessors of EMVProprietaryTemplate and Track2 can share it.
+func ParseDate(value string) (time.Time, error) {
+	if len(value) != 6 {
+		return time.Time{}, fmt.Errorf("date %q should have 6 digits", value)
+	}
+	var parts [3]int
+	for i := range parts {
+		n, err := strconv.Atoi(value[i*2 : i*2+2])
+		if err != nil {
+			return time.Time{}, fmt.Errorf("date %q is not numeric", value)
+		}
+		parts[i] = n
+	}
+	year := 2000 + parts[0]
+	if parts[0] >= 50 {
+		year = 1900 + parts[0]
+	}
+	date := time.Date(year, time.Month(parts[1]), parts[2], 0, 0, 0, 0, time.UTC)
+	// time.Date normalizes days past the end of the month, like 31 February
+	// becoming 2 or 3 March, so the date must round-trip to be valid.
+	if date.Year() != year || date.Month() != time.Month(parts[1]) || date.Day() != parts[2] {
+		return time.Time{}, fmt.Errorf("date %q is out of range", value)
+	}
+	return date, nil
+}
+
+func (et EMVProprietaryTemplate) Track2() (Track2, error) {
+	return ParseTrack2(et.Track2EquivalentData)
+}
+
+func (et EMVProprietaryTemplate) PrimaryAccountNumber() (PAN, error) {
+	return ParsePAN(et.PAN)
+}
+
+func (et EMVProprietaryTemplate) ApplicationExpirationDate() (time.Time, error) {
+	return ParseDate(et.ExpirationDate)
+}
+
+func (et EMVProprietaryTemplate) ApplicationEffectiveDate() (time.Time, error) {
+	return ParseDate(et.EffectiveDate)
+}
+
+// ApplicationServiceCode returns the Service Code (5F30) without the leading zero.
+func (et EMVProprietaryTemplate) ApplicationServiceCode() ServiceCode {
+	return ServiceCode(strings.TrimPrefix(et.ServiceCode, "0"))
+}
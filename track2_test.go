@@ -0,0 +1,85 @@
+package apdu
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mniak/apdu/internal/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTrack2(t *testing.T) {
+	data := test.MustParseHex(t, "5413330089020011D2512201000012345F")
+
+	t2, err := ParseTrack2(data)
+	require.NoError(t, err)
+
+	assert.Equal(t, PAN("5413330089020011"), t2.PAN)
+	assert.Equal(t, "2512", t2.ExpirationDate)
+	assert.Equal(t, ServiceCode("201"), t2.ServiceCode)
+	assert.Equal(t, "000012345", t2.DiscretionaryData)
+
+	expiry, err := t2.Expiry()
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2025, 12, 31, 0, 0, 0, 0, time.UTC), expiry)
+}
+
+func TestParseTrack2_Invalid(t *testing.T) {
+	for _, data := range []string{
+		"5413330089020011",
+		"5413330089020011D2512F",
+		"5413330089020011D2513201",
+		"D2512201",
+	} {
+		_, err := ParseTrack2(test.MustParseHex(t, data))
+		assert.ErrorIs(t, err, ErrInvalidTrack2, data)
+	}
+}
+
+func TestPAN(t *testing.T) {
+	pan, err := ParsePAN("5413330089020011FFF")
+	require.NoError(t, err)
+
+	assert.True(t, pan.Valid())
+	assert.False(t, PAN("5413330089020012").Valid())
+	assert.Equal(t, "541333", pan.IIN())
+	assert.Equal(t, "54133300", pan.BIN(8))
+	assert.Equal(t, "541333******0011", pan.Masked())
+
+	_, err = ParsePAN("54133300A9020011")
+	assert.Error(t, err)
+}
+
+func TestServiceCode(t *testing.T) {
+	sc := EMVProprietaryTemplate{ServiceCode: "0201"}.ApplicationServiceCode()
+
+	assert.Equal(t, ServiceCode("201"), sc)
+	assert.True(t, sc.ICCAvailable())
+	assert.False(t, sc.PINRequired())
+	assert.Equal(t, "International interchange, use IC (chip) where feasible", sc.Interchange())
+	assert.Equal(t, "Normal", sc.Authorization())
+	assert.Equal(t, "No restrictions", sc.AllowedServices())
+
+	assert.True(t, ServiceCode("120").PINRequired())
+	assert.Equal(t, "Unknown", ServiceCode("12").Interchange())
+}
+
+func TestParseDate(t *testing.T) {
+	date, err := ParseDate("491231")
+	require.NoError(t, err)
+	assert.Equal(t, 2049, date.Year())
+
+	date, err = ParseDate("500101")
+	require.NoError(t, err)
+	assert.Equal(t, 1950, date.Year())
+
+	date, err = ParseDate("240229")
+	require.NoError(t, err)
+	assert.Equal(t, time.February, date.Month())
+
+	for _, value := range []string{"251301", "250100", "240231", "230229"} {
+		_, err = ParseDate(value)
+		assert.Error(t, err, value)
+	}
+}
@@ -34,6 +34,11 @@ func (t Trailer) message() string {
 	case 0x6F00:
 		return "command not supported and no precise diagnosis given"
 
+	case 0x6300:
+		return "verification failed"
+	case 0x6985:
+		return "conditions of use not satisfied"
+
 	case 0x6A00:
 		return "no information given"
 	case 0x6A80:
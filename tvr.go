@@ -0,0 +1,248 @@
+package apdu
+
+import (
+	"fmt"
+	"strings"
+)
+
+// TVR are the Terminal Verification Results (95).
+type TVR [5]byte
+
+// TVRBit identifies a bit of the TVR by the byte number (1 to 5) in the high
+// byte and the bit mask in the low byte.
+type TVRBit uint16
+
+// Byte 1
+const (
+	TVROfflineDataAuthenticationNotPerformed TVRBit = 0x0180
+	TVRSDAFailed                             TVRBit = 0x0140
+	TVRICCDataMissing                        TVRBit = 0x0120
+	TVRCardAppearsOnExceptionFile            TVRBit = 0x0110
+	TVRDDAFailed                             TVRBit = 0x0108
+	TVRCDAFailed                             TVRBit = 0x0104
+	TVRSDASelected                           TVRBit = 0x0102
+)
+
+// Byte 2
+const (
+	TVRDifferentApplicationVersions TVRBit = 0x0280
+	TVRExpiredApplication           TVRBit = 0x0240
+	TVRApplicationNotYetEffective   TVRBit = 0x0220
+	TVRRequestedServiceNotAllowed   TVRBit = 0x0210
+	TVRNewCard                      TVRBit = 0x0208
+)
+
+// Byte 3
+const (
+	TVRCardholderVerificationNotSuccessful TVRBit = 0x0380
+	TVRUnrecognisedCVM                     TVRBit = 0x0340
+	TVRPINTryLimitExceeded                 TVRBit = 0x0320
+	TVRPINPadNotPresentOrNotWorking        TVRBit = 0x0310
+	TVRPINNotEntered                       TVRBit = 0x0308
+	TVROnlinePINEntered                    TVRBit = 0x0304
+)
+
+// Byte 4
+const (
+	TVRTransactionExceedsFloorLimit         TVRBit = 0x0480
+	TVRLowerConsecutiveOfflineLimitExceeded TVRBit = 0x0440
+	TVRUpperConsecutiveOfflineLimitExceeded TVRBit = 0x0420
+	TVRTransactionSelectedRandomlyForOnline TVRBit = 0x0410
+	TVRMerchantForcedTransactionOnline      TVRBit = 0x0408
+)
+
+// Byte 5
+const (
+	TVRDefaultTDOLUsed                             TVRBit = 0x0580
+	TVRIssuerAuthenticationFailed                  TVRBit = 0x0540
+	TVRScriptProcessingFailedBeforeFinalGenerateAC TVRBit = 0x0520
+	TVRScriptProcessingFailedAfterFinalGenerateAC  TVRBit = 0x0510
+)
+
+var tvrBitNames = []struct {
+	bit  TVRBit
+	name string
+}{
+	{TVROfflineDataAuthenticationNotPerformed, "Offline data authentication was not performed"},
+	{TVRSDAFailed, "SDA failed"},
+	{TVRICCDataMissing, "ICC data missing"},
+	{TVRCardAppearsOnExceptionFile, "Card appears on terminal exception file"},
+	{TVRDDAFailed, "DDA failed"},
+	{TVRCDAFailed, "CDA failed"},
+	{TVRSDASelected, "SDA selected"},
+	{TVRDifferentApplicationVersions, "ICC and terminal have different application versions"},
+	{TVRExpiredApplication, "Expired application"},
+	{TVRApplicationNotYetEffective, "Application not yet effective"},
+	{TVRRequestedServiceNotAllowed, "Requested service not allowed for card product"},
+	{TVRNewCard, "New card"},
+	{TVRCardholderVerificationNotSuccessful, "Cardholder verification was not successful"},
+	{TVRUnrecognisedCVM, "Unrecognised CVM"},
+	{TVRPINTryLimitExceeded, "PIN Try Limit exceeded"},
+	{TVRPINPadNotPresentOrNotWorking, "PIN entry required and PIN pad not present or not working"},
+	{TVRPINNotEntered, "PIN entry required, PIN pad present, but PIN was not entered"},
+	{TVROnlinePINEntered, "Online PIN entered"},
+	{TVRTransactionExceedsFloorLimit, "Transaction exceeds floor limit"},
+	{TVRLowerConsecutiveOfflineLimitExceeded, "Lower consecutive offline limit exceeded"},
+	{TVRUpperConsecutiveOfflineLimitExceeded, "Upper consecutive offline limit exceeded"},
+	{TVRTransactionSelectedRandomlyForOnline, "Transaction selected randomly for online processing"},
+	{TVRMerchantForcedTransactionOnline, "Merchant forced transaction online"},
+	{TVRDefaultTDOLUsed, "Default TDOL used"},
+	{TVRIssuerAuthenticationFailed, "Issuer authentication failed"},
+	{TVRScriptProcessingFailedBeforeFinalGenerateAC, "Script processing failed before final GENERATE AC"},
+	{TVRScriptProcessingFailedAfterFinalGenerateAC, "Script processing failed after final GENERATE AC"},
+}
+
+func (b TVRBit) index() int {
+	return int(b>>8) - 1
+}
+
+func (b TVRBit) mask() byte {
+	return byte(b)
+}
+
+func (b TVRBit) String() string {
+	for _, n := range tvrBitNames {
+		if n.bit == b {
+			return n.name
+		}
+	}
+	return fmt.Sprintf("TVR byte %d mask %02X", b.index()+1, b.mask())
+}
+
+func (tvr *TVR) Set(bits ...TVRBit) {
+	for _, b := range bits {
+		tvr[b.index()] |= b.mask()
+	}
+}
+
+func (tvr *TVR) Clear(bits ...TVRBit) {
+	for _, b := range bits {
+		tvr[b.index()] &^= b.mask()
+	}
+}
+
+func (tvr TVR) Has(bit TVRBit) bool {
+	return tvr[bit.index()]&bit.mask() == bit.mask()
+}
+
+// Bits returns the known bits that are set.
+func (tvr TVR) Bits() []TVRBit {
+	var result []TVRBit
+	for _, n := range tvrBitNames {
+		if tvr.Has(n.bit) {
+			result = append(result, n.bit)
+		}
+	}
+	return result
+}
+
+func (tvr TVR) String() string {
+	return fmt.Sprintf("%02X", tvr[:])
+}
+
+func (tvr TVR) GoString() string {
+	return describeBits(tvr.String(), tvr.Bits())
+}
+
+// ParseTVR reads the TVR from the value of the tag 95. Missing bytes are
+// considered zero.
+func ParseTVR(data []byte) TVR {
+	var tvr TVR
+	copy(tvr[:], data)
+	return tvr
+}
+
+// TSI is the Transaction Status Information (9B).
+type TSI [2]byte
+
+// TSIBit identifies a bit of the TSI by the byte number (1 or 2) in the high
+// byte and the bit mask in the low byte.
+type TSIBit uint16
+
+const (
+	TSIOfflineDataAuthenticationPerformed TSIBit = 0x0180
+	TSICardholderVerificationPerformed    TSIBit = 0x0140
+	TSICardRiskManagementPerformed        TSIBit = 0x0120
+	TSIIssuerAuthenticationPerformed      TSIBit = 0x0110
+	TSITerminalRiskManagementPerformed    TSIBit = 0x0108
+	TSIScriptProcessingPerformed          TSIBit = 0x0104
+)
+
+var tsiBitNames = []struct {
+	bit  TSIBit
+	name string
+}{
+	{TSIOfflineDataAuthenticationPerformed, "Offline data authentication was performed"},
+	{TSICardholderVerificationPerformed, "Cardholder verification was performed"},
+	{TSICardRiskManagementPerformed, "Card risk management was performed"},
+	{TSIIssuerAuthenticationPerformed, "Issuer authentication was performed"},
+	{TSITerminalRiskManagementPerformed, "Terminal risk management was performed"},
+	{TSIScriptProcessingPerformed, "Script processing was performed"},
+}
+
+func (b TSIBit) index() int {
+	return int(b>>8) - 1
+}
+
+func (b TSIBit) mask() byte {
+	return byte(b)
+}
+
+func (b TSIBit) String() string {
+	for _, n := range tsiBitNames {
+		if n.bit == b {
+			return n.name
+		}
+	}
+	return fmt.Sprintf("TSI byte %d mask %02X", b.index()+1, b.mask())
+}
+
+func (tsi *TSI) Set(bits ...TSIBit) {
+	for _, b := range bits {
+		tsi[b.index()] |= b.mask()
+	}
+}
+
+func (tsi TSI) Has(bit TSIBit) bool {
+	return tsi[bit.index()]&bit.mask() == bit.mask()
+}
+
+func (tsi TSI) Bits() []TSIBit {
+	var result []TSIBit
+	for _, n := range tsiBitNames {
+		if tsi.Has(n.bit) {
+			result = append(result, n.bit)
+		}
+	}
+	return result
+}
+
+func (tsi TSI) String() string {
+	return fmt.Sprintf("%02X", tsi[:])
+}
+
+func (tsi TSI) GoString() string {
+	return describeBits(tsi.String(), tsi.Bits())
+}
+
+// ParseTSI reads the TSI from the value of the tag 9B. Missing bytes are
+// considered zero.
+func ParseTSI(data []byte) TSI {
+	var tsi TSI
+	copy(tsi[:], data)
+	return tsi
+}
+
+func describeBits[T fmt.Stringer](value string, bits []T) string {
+	if len(bits) == 0 {
+		return value + " []"
+	}
+	var sb strings.Builder
+	sb.WriteString(value)
+	sb.WriteString(" [\n")
+	for _, b := range bits {
+		fmt.Fprintf(&sb, "  - %s\n", b)
+	}
+	sb.WriteString("]")
+	return sb.String()
+}